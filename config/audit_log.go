@@ -0,0 +1,185 @@
+// Copyright 2026 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package config
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"math/rand/v2"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// AuditLogPolicy controls which requests WithAuditLogger logs and at which
+// level.
+type AuditLogPolicy struct {
+	// Level is the level of the successful requests.
+	Level slog.Level
+	// FailureLevel is the level of the requests which fail or get a 5xx
+	// response.
+	FailureLevel slog.Level
+	// SampleRate is the fraction of the successful requests which are
+	// logged. All of them are logged if zero, while failures are always
+	// logged.
+	SampleRate float64
+	// LogCompletion also logs the logged requests once their response body
+	// is fully read or closed, with the size of the body and the total
+	// duration.
+	LogCompletion bool
+}
+
+// WithAuditLogger logs every request made by the client when its response
+// headers are received or it fails: its method, its URL with the password
+// redacted, the response status, the duration, the size of the request body
+// and the authentication mechanism, which is request_credentials for the
+// requests overriding the credentials with WithRequestCredentials. Headers,
+// and thus credentials, are never logged.
+func WithAuditLogger(logger *slog.Logger, policy AuditLogPolicy) HTTPClientOption {
+	return httpClientOptionFunc(func(opts *httpClientOptions) {
+		opts.auditLogger = logger
+		opts.auditLogPolicy = policy
+	})
+}
+
+// authMechanism returns the names of the authentication settings of c, or
+// "none". If requestCredentials is true, the credentials are overridden by
+// the request.
+func (c *HTTPClientConfig) authMechanism(requestCredentials bool) string {
+	var mechanisms []string
+	switch {
+	case requestCredentials:
+		mechanisms = append(mechanisms, "request_credentials")
+	case c.BasicAuth != nil:
+		mechanisms = append(mechanisms, "basic_auth")
+	case c.DigestAuth != nil:
+		mechanisms = append(mechanisms, "digest_auth")
+	case c.OAuth2 != nil:
+		mechanisms = append(mechanisms, "oauth2")
+	case c.Authorization != nil:
+		mechanisms = append(mechanisms, "authorization")
+	case len(c.BearerToken) > 0 || len(c.BearerTokenFile) > 0:
+		mechanisms = append(mechanisms, "bearer_token")
+	}
+	if c.HMACSigning != nil {
+		mechanisms = append(mechanisms, "hmac_signing")
+	}
+	if len(c.TLSConfig.CertFile) > 0 || len(c.TLSConfig.Cert) > 0 || len(c.TLSConfig.CertRef) > 0 || len(c.TLSConfig.ClientCertificates) > 0 {
+		mechanisms = append(mechanisms, "tls_client_certificate")
+	}
+	if len(mechanisms) == 0 {
+		return "none"
+	}
+	return strings.Join(mechanisms, "+")
+}
+
+type auditLogRoundTripper struct {
+	logger *slog.Logger
+	policy AuditLogPolicy
+	auth   string
+	// overrideAuth is the authentication mechanism of the requests
+	// overriding the credentials, or empty if they cannot.
+	overrideAuth string
+	next         http.RoundTripper
+}
+
+func newAuditLogRoundTripper(logger *slog.Logger, policy AuditLogPolicy, cfg *HTTPClientConfig, next http.RoundTripper) http.RoundTripper {
+	rt := &auditLogRoundTripper{logger: logger, policy: policy, auth: cfg.authMechanism(false), next: next}
+	if cfg.RequestOverrides != nil && cfg.RequestOverrides.Credentials {
+		rt.overrideAuth = cfg.authMechanism(true)
+	}
+	return rt
+}
+
+func (rt *auditLogRoundTripper) RoundTrip(req *http.Request) (*http.Response, error) {
+	start := time.Now()
+	resp, err := rt.next.RoundTrip(req)
+	if err != nil {
+		rt.log(req.Context(), rt.policy.FailureLevel, "HTTP request", req, start,
+			slog.String("err", err.Error()),
+		)
+		return nil, err
+	}
+
+	level := rt.policy.Level
+	if resp.StatusCode >= http.StatusInternalServerError {
+		level = rt.policy.FailureLevel
+	} else if rt.policy.SampleRate > 0 && rand.Float64() >= rt.policy.SampleRate {
+		return resp, nil
+	}
+	if !rt.logger.Enabled(req.Context(), level) {
+		return resp, nil
+	}
+	// The request is logged as soon as the headers are received, so that
+	// it is logged even if the body is never consumed.
+	rt.log(req.Context(), level, "HTTP request", req, start,
+		slog.Int("status", resp.StatusCode),
+	)
+	if rt.policy.LogCompletion {
+		resp.Body = &auditLogBody{ReadCloser: resp.Body, done: func(n int64) {
+			rt.log(req.Context(), level, "HTTP response completed", req, start,
+				slog.Int("status", resp.StatusCode),
+				slog.Int64("response_bytes", n),
+			)
+		}}
+	}
+	return resp, nil
+}
+
+func (rt *auditLogRoundTripper) log(ctx context.Context, level slog.Level, msg string, req *http.Request, start time.Time, attrs ...slog.Attr) {
+	auth := rt.auth
+	if rt.overrideAuth != "" && requestOverridesFromContext(ctx).credentials != nil {
+		auth = rt.overrideAuth
+	}
+	attrs = append([]slog.Attr{
+		slog.String("method", req.Method),
+		slog.String("url", req.URL.Redacted()),
+		slog.String("auth", auth),
+		slog.Duration("duration", time.Since(start)),
+		slog.Int64("request_bytes", max(req.ContentLength, 0)),
+	}, attrs...)
+	rt.logger.LogAttrs(ctx, level, msg, attrs...)
+}
+
+func (rt *auditLogRoundTripper) CloseIdleConnections() {
+	if ci, ok := rt.next.(closeIdler); ok {
+		ci.CloseIdleConnections()
+	}
+}
+
+// auditLogBody counts the bytes read from a response body and calls done
+// once, when the body is fully read or closed.
+type auditLogBody struct {
+	io.ReadCloser
+	n    int64
+	once sync.Once
+	done func(n int64)
+}
+
+func (b *auditLogBody) Read(p []byte) (int, error) {
+	n, err := b.ReadCloser.Read(p)
+	b.n += int64(n)
+	if err == io.EOF {
+		b.once.Do(func() { b.done(b.n) })
+	}
+	return n, err
+}
+
+func (b *auditLogBody) Close() error {
+	err := b.ReadCloser.Close()
+	b.once.Do(func() { b.done(b.n) })
+	return err
+}
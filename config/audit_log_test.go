@@ -0,0 +1,166 @@
+// Copyright 2026 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package config
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+// auditLogEntries decodes the JSON log lines of buf.
+func auditLogEntries(t *testing.T, buf *bytes.Buffer) []map[string]any {
+	var entries []map[string]any
+	for _, line := range strings.Split(strings.TrimSpace(buf.String()), "\n") {
+		if line == "" {
+			continue
+		}
+		var entry map[string]any
+		require.NoError(t, json.Unmarshal([]byte(line), &entry))
+		delete(entry, "time")
+		delete(entry, "duration")
+		entries = append(entries, entry)
+	}
+	buf.Reset()
+	return entries
+}
+
+func TestAuditLog(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/fail" {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		_, _ = io.WriteString(w, "hello")
+	}))
+	defer ts.Close()
+
+	var buf bytes.Buffer
+	logger := slog.New(slog.NewJSONHandler(&buf, &slog.HandlerOptions{Level: slog.LevelDebug}))
+	cfg := DefaultHTTPClientConfig
+	cfg.BasicAuth = &BasicAuth{Username: "user", Password: "password"}
+	client, err := NewClientFromConfig(cfg, "test", WithAuditLogger(logger, AuditLogPolicy{
+		Level:        slog.LevelDebug,
+		FailureLevel: slog.LevelWarn,
+	}))
+	require.NoError(t, err)
+
+	resp, err := client.Post(strings.Replace(ts.URL, "http://", "http://u:url-password@", 1)+"/ok", "text/plain", strings.NewReader("body"))
+	require.NoError(t, err)
+	_, err = io.ReadAll(resp.Body)
+	require.NoError(t, err)
+	resp.Body.Close()
+
+	resp, err = client.Get(ts.URL + "/fail")
+	require.NoError(t, err)
+	resp.Body.Close()
+
+	_, err = client.Get("http://127.0.0.1:0/")
+	require.Error(t, err)
+
+	require.NotContains(t, buf.String(), "password")
+	entries := auditLogEntries(t, &buf)
+	require.Len(t, entries, 3)
+	require.Equal(t, map[string]any{
+		"level":         "DEBUG",
+		"msg":           "HTTP request",
+		"method":        "POST",
+		"url":           strings.Replace(ts.URL, "http://", "http://u:xxxxx@", 1) + "/ok",
+		"auth":          "basic_auth",
+		"request_bytes": float64(4),
+		"status":        float64(200),
+	}, entries[0])
+	require.Equal(t, "WARN", entries[1]["level"])
+	require.Equal(t, float64(503), entries[1]["status"])
+	require.Equal(t, "WARN", entries[2]["level"])
+	require.Contains(t, entries[2]["err"], "connect")
+}
+
+func TestAuditLogSampling(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/fail" {
+			w.WriteHeader(http.StatusInternalServerError)
+		}
+	}))
+	defer ts.Close()
+
+	var buf bytes.Buffer
+	logger := slog.New(slog.NewJSONHandler(&buf, nil))
+	client, err := NewClientFromConfig(DefaultHTTPClientConfig, "test", WithAuditLogger(logger, AuditLogPolicy{
+		FailureLevel: slog.LevelError,
+		SampleRate:   1e-12,
+	}))
+	require.NoError(t, err)
+
+	for _, path := range []string{"/", "/", "/fail"} {
+		resp, err := client.Get(ts.URL + path)
+		require.NoError(t, err)
+		resp.Body.Close()
+	}
+	entries := auditLogEntries(t, &buf)
+	require.Len(t, entries, 1)
+	require.Equal(t, "ERROR", entries[0]["level"])
+	require.Equal(t, "none", entries[0]["auth"])
+}
+
+func TestAuditLogCompletion(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		_, _ = io.WriteString(w, "hello")
+	}))
+	defer ts.Close()
+
+	var buf bytes.Buffer
+	logger := slog.New(slog.NewJSONHandler(&buf, nil))
+	cfg := DefaultHTTPClientConfig
+	cfg.RequestOverrides = &RequestOverridesConfig{Credentials: true}
+	client, err := NewClientFromConfig(cfg, "test", WithAuditLogger(logger, AuditLogPolicy{LogCompletion: true}))
+	require.NoError(t, err)
+
+	// The request is logged before its body is read.
+	resp, err := client.Get(ts.URL)
+	require.NoError(t, err)
+	entries := auditLogEntries(t, &buf)
+	require.Len(t, entries, 1)
+	require.Equal(t, "HTTP request", entries[0]["msg"])
+	require.Equal(t, float64(200), entries[0]["status"])
+	require.Equal(t, "none", entries[0]["auth"])
+
+	_, err = io.ReadAll(resp.Body)
+	require.NoError(t, err)
+	resp.Body.Close()
+	entries = auditLogEntries(t, &buf)
+	require.Len(t, entries, 1)
+	require.Equal(t, "HTTP response completed", entries[0]["msg"])
+	require.Equal(t, float64(5), entries[0]["response_bytes"])
+
+	// The credentials overridden by the request are reported.
+	req, err := http.NewRequestWithContext(WithRequestCredentials(context.Background(), "", "token"), http.MethodGet, ts.URL, nil)
+	require.NoError(t, err)
+	resp, err = client.Do(req)
+	require.NoError(t, err)
+	resp.Body.Close()
+	require.NotContains(t, buf.String(), "token")
+	entries = auditLogEntries(t, &buf)
+	require.Len(t, entries, 2)
+	require.Equal(t, "request_credentials", entries[0]["auth"])
+	require.Equal(t, "request_credentials", entries[1]["auth"])
+}
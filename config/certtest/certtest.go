@@ -0,0 +1,416 @@
+// Copyright 2026 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package certtest generates certificate authorities, certificates and keys
+// for tests, along with ready-to-use TLS configurations and servers.
+package certtest
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"math/big"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/prometheus/common/config"
+)
+
+// DefaultValidity is the validity period of the generated certificates when
+// Options.NotAfter is not set.
+const DefaultValidity = 24 * time.Hour
+
+// KeyType is the type of the generated private keys.
+type KeyType int
+
+const (
+	// ECDSA generates ECDSA P-256 keys.
+	ECDSA KeyType = iota
+	// RSA generates RSA keys of Options.RSABits bits.
+	RSA
+	// Ed25519 generates Ed25519 keys.
+	Ed25519
+)
+
+// Options describes a certificate to generate. The zero value generates an
+// ECDSA certificate valid for DefaultValidity.
+type Options struct {
+	CommonName string
+	KeyType    KeyType
+	// RSABits is the size of RSA keys. Default is 2048.
+	RSABits int
+
+	DNSNames       []string
+	IPAddresses    []net.IP
+	URIs           []*url.URL
+	EmailAddresses []string
+
+	// NotBefore defaults to one minute ago, to tolerate clock skews.
+	NotBefore time.Time
+	// NotAfter defaults to NotBefore plus DefaultValidity. Set it in the past
+	// to generate an expired certificate.
+	NotAfter time.Time
+
+	// ExtKeyUsage defaults to server and client authentication for leaf
+	// certificates.
+	ExtKeyUsage []x509.ExtKeyUsage
+}
+
+// Certificate is a generated certificate with its private key.
+type Certificate struct {
+	Cert *x509.Certificate
+	Key  crypto.Signer
+	// Chain holds the intermediate certificates between Cert and the root,
+	// starting with the issuer of Cert.
+	Chain []*x509.Certificate
+}
+
+// CertPEM returns the PEM encoded certificate.
+func (c *Certificate) CertPEM() []byte {
+	return encodeCertificates(c.Cert)
+}
+
+// ChainPEM returns the PEM encoded certificate followed by its intermediates.
+func (c *Certificate) ChainPEM() []byte {
+	return encodeCertificates(append([]*x509.Certificate{c.Cert}, c.Chain...)...)
+}
+
+// KeyPEM returns the PEM encoded PKCS #8 private key.
+func (c *Certificate) KeyPEM() []byte {
+	b, err := x509.MarshalPKCS8PrivateKey(c.Key)
+	if err != nil {
+		// All the generated keys can be marshalled.
+		panic(err)
+	}
+	return pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: b})
+}
+
+// TLSCertificate returns the certificate, its chain and key as a
+// tls.Certificate.
+func (c *Certificate) TLSCertificate() tls.Certificate {
+	tc := tls.Certificate{
+		PrivateKey: c.Key,
+		Leaf:       c.Cert,
+	}
+	tc.Certificate = append(tc.Certificate, c.Cert.Raw)
+	for _, cert := range c.Chain {
+		tc.Certificate = append(tc.Certificate, cert.Raw)
+	}
+	return tc
+}
+
+// WriteFiles writes the certificate and its chain to path.crt and the key to
+// path.key.
+func (c *Certificate) WriteFiles(path string) error {
+	if err := os.WriteFile(path+".crt", c.ChainPEM(), 0o644); err != nil {
+		return err
+	}
+	return os.WriteFile(path+".key", c.KeyPEM(), 0o600)
+}
+
+// CA is a generated certificate authority.
+type CA struct {
+	Certificate
+
+	root    *x509.Certificate
+	mtx     sync.Mutex
+	revoked []x509.RevocationListEntry
+	crlNum  int64
+}
+
+// NewRootCA returns a self-signed certificate authority.
+func NewRootCA(opts Options) (*CA, error) {
+	return newCA(opts, nil)
+}
+
+// NewIntermediateCA returns a certificate authority signed by ca.
+func (ca *CA) NewIntermediateCA(opts Options) (*CA, error) {
+	return newCA(opts, ca)
+}
+
+func newCA(opts Options, parent *CA) (*CA, error) {
+	if opts.CommonName == "" {
+		opts.CommonName = "Prometheus Test CA"
+	}
+	tmpl := template(opts)
+	tmpl.Subject.OrganizationalUnit = []string{"Prometheus Certificate Authority"}
+	tmpl.IsCA = true
+	tmpl.KeyUsage = x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign | x509.KeyUsageCRLSign
+	if opts.ExtKeyUsage == nil {
+		tmpl.ExtKeyUsage = []x509.ExtKeyUsage{x509.ExtKeyUsageAny}
+	}
+	cert, err := create(tmpl, opts, parent)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create CA certificate: %w", err)
+	}
+	ca := &CA{Certificate: *cert, root: cert.Cert}
+	if parent != nil {
+		ca.root = parent.root
+	}
+	return ca, nil
+}
+
+// Issue returns a leaf certificate signed by ca. When no DNS name, IP address,
+// URI or email address is set, the certificate is valid for localhost,
+// 127.0.0.1 and ::1.
+func (ca *CA) Issue(opts Options) (*Certificate, error) {
+	return issue(opts, ca)
+}
+
+// NewSelfSigned returns a self-signed leaf certificate.
+func NewSelfSigned(opts Options) (*Certificate, error) {
+	return issue(opts, nil)
+}
+
+func issue(opts Options, ca *CA) (*Certificate, error) {
+	if opts.CommonName == "" {
+		opts.CommonName = "localhost"
+	}
+	if len(opts.DNSNames) == 0 && len(opts.IPAddresses) == 0 && len(opts.URIs) == 0 && len(opts.EmailAddresses) == 0 {
+		opts.DNSNames = []string{"localhost"}
+		opts.IPAddresses = []net.IP{net.IPv4(127, 0, 0, 1), net.IPv6loopback}
+	}
+	tmpl := template(opts)
+	tmpl.KeyUsage = x509.KeyUsageDigitalSignature
+	if opts.KeyType == RSA {
+		tmpl.KeyUsage |= x509.KeyUsageKeyEncipherment
+	}
+	if opts.ExtKeyUsage == nil {
+		tmpl.ExtKeyUsage = []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth}
+	}
+	cert, err := create(tmpl, opts, ca)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create certificate: %w", err)
+	}
+	return cert, nil
+}
+
+var (
+	serialMtx    sync.Mutex
+	serialNumber *big.Int
+)
+
+// nextSerialNumber returns unique serial numbers, starting from a random one.
+func nextSerialNumber() (*big.Int, error) {
+	serialMtx.Lock()
+	defer serialMtx.Unlock()
+	if serialNumber == nil {
+		var err error
+		serialNumber, err = rand.Int(rand.Reader, new(big.Int).Lsh(big.NewInt(1), 128))
+		if err != nil {
+			return nil, fmt.Errorf("failed to generate serial number: %w", err)
+		}
+	}
+	serialNumber.Add(serialNumber, big.NewInt(1))
+	return new(big.Int).Set(serialNumber), nil
+}
+
+func template(opts Options) *x509.Certificate {
+	notBefore := opts.NotBefore
+	if notBefore.IsZero() {
+		notBefore = time.Now().Add(-time.Minute)
+	}
+	notAfter := opts.NotAfter
+	if notAfter.IsZero() {
+		notAfter = notBefore.Add(DefaultValidity)
+	}
+	return &x509.Certificate{
+		Subject: pkix.Name{
+			Country:      []string{"US"},
+			Organization: []string{"Prometheus"},
+			CommonName:   opts.CommonName,
+		},
+		DNSNames:              opts.DNSNames,
+		IPAddresses:           opts.IPAddresses,
+		URIs:                  opts.URIs,
+		EmailAddresses:        opts.EmailAddresses,
+		NotBefore:             notBefore,
+		NotAfter:              notAfter,
+		ExtKeyUsage:           opts.ExtKeyUsage,
+		BasicConstraintsValid: true,
+	}
+}
+
+func generateKey(opts Options) (crypto.Signer, error) {
+	switch opts.KeyType {
+	case ECDSA:
+		return ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	case RSA:
+		bits := opts.RSABits
+		if bits == 0 {
+			bits = 2048
+		}
+		return rsa.GenerateKey(rand.Reader, bits)
+	case Ed25519:
+		_, key, err := ed25519.GenerateKey(rand.Reader)
+		return key, err
+	}
+	return nil, fmt.Errorf("unknown key type %d", opts.KeyType)
+}
+
+// create generates a key and signs tmpl with the key of parent, or with the
+// generated key if parent is nil.
+func create(tmpl *x509.Certificate, opts Options, parent *CA) (*Certificate, error) {
+	key, err := generateKey(opts)
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate private key: %w", err)
+	}
+	if tmpl.SerialNumber, err = nextSerialNumber(); err != nil {
+		return nil, err
+	}
+
+	parentCert, parentKey := tmpl, key
+	var chain []*x509.Certificate
+	if parent != nil {
+		parentCert, parentKey = parent.Cert, parent.Key
+		// The root is not part of the chain.
+		if parent.Cert != parent.root {
+			chain = append([]*x509.Certificate{parent.Cert}, parent.Chain...)
+		}
+	}
+
+	b, err := x509.CreateCertificate(rand.Reader, tmpl, parentCert, key.Public(), parentKey)
+	if err != nil {
+		return nil, err
+	}
+	cert, err := x509.ParseCertificate(b)
+	if err != nil {
+		return nil, err
+	}
+	return &Certificate{Cert: cert, Key: key, Chain: chain}, nil
+}
+
+// Root returns the root certificate of ca.
+func (ca *CA) Root() *x509.Certificate {
+	return ca.root
+}
+
+// RootPEM returns the PEM encoded root certificate of ca, to be used as trust
+// anchor.
+func (ca *CA) RootPEM() []byte {
+	return encodeCertificates(ca.Root())
+}
+
+// Pool returns a pool containing the root certificate of ca.
+func (ca *CA) Pool() *x509.CertPool {
+	pool := x509.NewCertPool()
+	pool.AddCert(ca.Root())
+	return pool
+}
+
+// Revoke adds cert to the certificate revocation list of ca.
+func (ca *CA) Revoke(cert *Certificate) {
+	ca.mtx.Lock()
+	defer ca.mtx.Unlock()
+	ca.revoked = append(ca.revoked, x509.RevocationListEntry{
+		SerialNumber:   cert.Cert.SerialNumber,
+		RevocationTime: time.Now(),
+	})
+}
+
+// CRL returns a PEM encoded certificate revocation list of the certificates
+// revoked by ca.
+func (ca *CA) CRL() ([]byte, error) {
+	ca.mtx.Lock()
+	defer ca.mtx.Unlock()
+	ca.crlNum++
+	now := time.Now()
+	b, err := x509.CreateRevocationList(rand.Reader, &x509.RevocationList{
+		RevokedCertificateEntries: ca.revoked,
+		Number:                    big.NewInt(ca.crlNum),
+		ThisUpdate:                now.Add(-time.Minute),
+		NextUpdate:                now.Add(DefaultValidity),
+	}, ca.Cert, ca.Key)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create CRL: %w", err)
+	}
+	return pem.EncodeToMemory(&pem.Block{Type: "X509 CRL", Bytes: b}), nil
+}
+
+// IsRevoked returns whether cert has been revoked by ca.
+func (ca *CA) IsRevoked(cert *x509.Certificate) bool {
+	ca.mtx.Lock()
+	defer ca.mtx.Unlock()
+	for _, r := range ca.revoked {
+		if r.SerialNumber.Cmp(cert.SerialNumber) == 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// ClientTLSConfig returns a config.TLSConfig trusting ca and, if client is not
+// nil, presenting it as client certificate.
+func (ca *CA) ClientTLSConfig(client *Certificate) config.TLSConfig {
+	cfg := config.TLSConfig{CA: string(ca.RootPEM())}
+	if client != nil {
+		cfg.Cert = string(client.ChainPEM())
+		cfg.Key = config.Secret(client.KeyPEM())
+	}
+	return cfg
+}
+
+// ServerTLSConfig returns a *tls.Config presenting server. If clientCA is not
+// nil, client certificates signed by it are required and certificates revoked
+// by it are rejected.
+func ServerTLSConfig(server *Certificate, clientCA *CA) *tls.Config {
+	cfg := &tls.Config{
+		Certificates: []tls.Certificate{server.TLSCertificate()},
+	}
+	if clientCA != nil {
+		cfg.ClientAuth = tls.RequireAndVerifyClientCert
+		cfg.ClientCAs = clientCA.Pool()
+		cfg.VerifyPeerCertificate = func(_ [][]byte, chains [][]*x509.Certificate) error {
+			for _, chain := range chains {
+				if len(chain) > 0 && clientCA.IsRevoked(chain[0]) {
+					return errors.New("certtest: client certificate revoked")
+				}
+			}
+			return nil
+		}
+	}
+	return cfg
+}
+
+// NewServer starts and returns an HTTPS server presenting server, and
+// requiring client certificates signed by clientCA if it is not nil. The
+// caller must call Close when finished.
+func NewServer(handler http.Handler, server *Certificate, clientCA *CA) *httptest.Server {
+	ts := httptest.NewUnstartedServer(handler)
+	ts.TLS = ServerTLSConfig(server, clientCA)
+	ts.StartTLS()
+	return ts
+}
+
+func encodeCertificates(certs ...*x509.Certificate) []byte {
+	var b bytes.Buffer
+	for _, cert := range certs {
+		pem.Encode(&b, &pem.Block{Type: "CERTIFICATE", Bytes: cert.Raw})
+	}
+	return b.Bytes()
+}
@@ -0,0 +1,152 @@
+// Copyright 2026 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package certtest
+
+import (
+	"crypto/x509"
+	"encoding/pem"
+	"net/http"
+	"net/url"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/prometheus/common/config"
+)
+
+func TestKeyTypes(t *testing.T) {
+	for _, keyType := range []KeyType{ECDSA, RSA, Ed25519} {
+		ca, err := NewRootCA(Options{KeyType: keyType})
+		require.NoError(t, err)
+		cert, err := ca.Issue(Options{KeyType: keyType})
+		require.NoError(t, err)
+
+		_, err = cert.Cert.Verify(x509.VerifyOptions{Roots: ca.Pool(), DNSName: "localhost"})
+		require.NoError(t, err)
+
+		// The PEM encoded pair is usable.
+		_, err = x509.ParsePKCS8PrivateKey(decodePEM(t, cert.KeyPEM(), "PRIVATE KEY"))
+		require.NoError(t, err)
+	}
+}
+
+func TestIntermediateChain(t *testing.T) {
+	root, err := NewRootCA(Options{CommonName: "root"})
+	require.NoError(t, err)
+	inter1, err := root.NewIntermediateCA(Options{CommonName: "inter1"})
+	require.NoError(t, err)
+	inter2, err := inter1.NewIntermediateCA(Options{CommonName: "inter2"})
+	require.NoError(t, err)
+
+	uri, _ := url.Parse("spiffe://example.org/service")
+	cert, err := inter2.Issue(Options{DNSNames: []string{"example.org"}, URIs: []*url.URL{uri}})
+	require.NoError(t, err)
+
+	require.Equal(t, root.Cert, inter2.Root())
+	require.Equal(t, []*x509.Certificate{inter2.Cert, inter1.Cert}, cert.Chain)
+	require.Equal(t, []*url.URL{uri}, cert.Cert.URIs)
+
+	intermediates := x509.NewCertPool()
+	for _, c := range cert.Chain {
+		intermediates.AddCert(c)
+	}
+	_, err = cert.Cert.Verify(x509.VerifyOptions{Roots: inter2.Pool(), Intermediates: intermediates, DNSName: "example.org"})
+	require.NoError(t, err)
+}
+
+func TestExpired(t *testing.T) {
+	ca, err := NewRootCA(Options{})
+	require.NoError(t, err)
+	cert, err := ca.Issue(Options{NotBefore: time.Now().Add(-2 * time.Hour), NotAfter: time.Now().Add(-time.Hour)})
+	require.NoError(t, err)
+
+	_, err = cert.Cert.Verify(x509.VerifyOptions{Roots: ca.Pool()})
+	var invalid x509.CertificateInvalidError
+	require.ErrorAs(t, err, &invalid)
+	require.Equal(t, x509.Expired, invalid.Reason)
+}
+
+func TestCRL(t *testing.T) {
+	ca, err := NewRootCA(Options{})
+	require.NoError(t, err)
+	cert, err := ca.Issue(Options{})
+	require.NoError(t, err)
+	ca.Revoke(cert)
+
+	b, err := ca.CRL()
+	require.NoError(t, err)
+	crl, err := x509.ParseRevocationList(decodePEM(t, b, "X509 CRL"))
+	require.NoError(t, err)
+	require.NoError(t, crl.CheckSignatureFrom(ca.Cert))
+	require.Len(t, crl.RevokedCertificateEntries, 1)
+	require.Equal(t, cert.Cert.SerialNumber, crl.RevokedCertificateEntries[0].SerialNumber)
+}
+
+func TestServer(t *testing.T) {
+	ca, err := NewRootCA(Options{})
+	require.NoError(t, err)
+	inter, err := ca.NewIntermediateCA(Options{})
+	require.NoError(t, err)
+	server, err := inter.Issue(Options{})
+	require.NoError(t, err)
+	client, err := inter.Issue(Options{ExtKeyUsage: []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth}})
+	require.NoError(t, err)
+	revoked, err := inter.Issue(Options{})
+	require.NoError(t, err)
+	inter.Revoke(revoked)
+
+	ts := NewServer(http.HandlerFunc(func(http.ResponseWriter, *http.Request) {}), server, inter)
+	defer ts.Close()
+
+	get := func(cert *Certificate) error {
+		c, err := config.NewClientFromConfig(config.HTTPClientConfig{TLSConfig: ca.ClientTLSConfig(cert)}, "test")
+		require.NoError(t, err)
+		resp, err := c.Get(ts.URL)
+		if err == nil {
+			resp.Body.Close()
+		}
+		return err
+	}
+	require.NoError(t, get(client))
+	require.Error(t, get(nil))
+	require.Error(t, get(revoked))
+}
+
+func TestWriteFiles(t *testing.T) {
+	ca, err := NewRootCA(Options{})
+	require.NoError(t, err)
+	inter, err := ca.NewIntermediateCA(Options{})
+	require.NoError(t, err)
+	cert, err := inter.Issue(Options{})
+	require.NoError(t, err)
+
+	path := filepath.Join(t.TempDir(), "cert")
+	require.NoError(t, cert.WriteFiles(path))
+
+	cfg := config.TLSConfig{CA: string(ca.RootPEM()), CertFile: path + ".crt", KeyFile: path + ".key"}
+	tlsConfig, err := config.NewTLSConfig(&cfg)
+	require.NoError(t, err)
+	tc, err := tlsConfig.GetClientCertificate(nil)
+	require.NoError(t, err)
+	require.Len(t, tc.Certificate, 2)
+}
+
+func decodePEM(t *testing.T, b []byte, typ string) []byte {
+	block, _ := pem.Decode(b)
+	require.NotNil(t, block)
+	require.Equal(t, typ, block.Type)
+	return block.Bytes
+}
@@ -0,0 +1,49 @@
+// Copyright 2026 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Command curlconfig prints the curl command equivalent to an HTTP client
+// configuration file, to debug scrape failures.
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+
+	kingpin "github.com/alecthomas/kingpin/v2"
+
+	"github.com/prometheus/common/config"
+)
+
+func main() {
+	app := kingpin.New("curlconfig", "Prints the curl command equivalent to an HTTP client configuration.")
+	configFile := app.Flag("config.file", "HTTP client configuration file.").Required().String()
+	showSecrets := app.Flag("show-secrets", "Include the secrets in the command instead of <secret>.").Bool()
+	target := app.Arg("url", "URL of the request.").Required().String()
+	kingpin.MustParse(app.Parse(os.Args[1:]))
+
+	cfg, _, err := config.LoadHTTPConfigFile(*configFile)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "Error loading the configuration:", err)
+		os.Exit(1)
+	}
+	cmd, err := config.NewCurlCommand(context.Background(), *cfg, *target, config.CurlOptions{ShowSecrets: *showSecrets})
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "Error rendering the curl command:", err)
+		os.Exit(1)
+	}
+	for _, note := range cmd.Notes {
+		fmt.Fprintln(os.Stderr, "# "+note)
+	}
+	fmt.Println(cmd.String())
+}
@@ -0,0 +1,94 @@
+// Copyright 2026 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Command diagnoseconfig runs connectivity checks against a target with an
+// HTTP client configuration file, to debug scrape failures.
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	kingpin "github.com/alecthomas/kingpin/v2"
+
+	"github.com/prometheus/common/config"
+)
+
+func main() {
+	app := kingpin.New("diagnoseconfig", "Runs connectivity checks against a target with an HTTP client configuration.")
+	configFile := app.Flag("config.file", "HTTP client configuration file.").Required().String()
+	timeout := app.Flag("timeout", "Timeout of all the checks.").Default("30s").Duration()
+	target := app.Arg("url", "URL of the target.").Required().String()
+	kingpin.MustParse(app.Parse(os.Args[1:]))
+
+	cfg, _, err := config.LoadHTTPConfigFile(*configFile)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "Error loading the configuration:", err)
+		os.Exit(1)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), *timeout)
+	defer cancel()
+	report, err := config.Diagnose(ctx, *cfg, *target)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "Error running the checks:", err)
+		os.Exit(1)
+	}
+	printReport(report)
+	if report.Err() != nil {
+		os.Exit(1)
+	}
+}
+
+func printReport(r *config.DiagnosticReport) {
+	fmt.Println("URL:", r.URL)
+	if r.ProxyURL != "" {
+		fmt.Println("Proxy:", r.ProxyURL)
+	}
+	if len(r.Addresses) > 0 {
+		fmt.Println("Addresses:", strings.Join(r.Addresses, ", "))
+	}
+	if r.TLS != nil {
+		fmt.Printf("TLS: %s, %s, server name %s", r.TLS.Version, r.TLS.CipherSuite, r.TLS.ServerName)
+		if r.TLS.NegotiatedProtocol != "" {
+			fmt.Printf(", protocol %s", r.TLS.NegotiatedProtocol)
+		}
+		fmt.Println()
+		for i, c := range r.TLS.Chain {
+			fmt.Printf("  %d: subject %q, issuer %q, valid %s to %s", i, c.Subject, c.Issuer, c.NotBefore.Format(time.RFC3339), c.NotAfter.Format(time.RFC3339))
+			if len(c.DNSNames) > 0 {
+				fmt.Printf(", DNS names %s", strings.Join(c.DNSNames, ", "))
+			}
+			fmt.Println()
+		}
+		if r.TLS.VerificationError != "" {
+			fmt.Println("  verification error:", r.TLS.VerificationError)
+		}
+	}
+	if r.StatusCode != 0 {
+		fmt.Println("Status code:", r.StatusCode)
+	}
+	fmt.Println()
+	for _, c := range r.Checks {
+		switch {
+		case c.Err != nil:
+			fmt.Printf("%-7s FAIL %s: %v\n", c.Name, c.Duration, c.Err)
+		case c.Skipped != "":
+			fmt.Printf("%-7s SKIP %s\n", c.Name, c.Skipped)
+		default:
+			fmt.Printf("%-7s OK   %s\n", c.Name, c.Duration)
+		}
+	}
+}
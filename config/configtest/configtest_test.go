@@ -0,0 +1,186 @@
+// Copyright 2026 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package configtest
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/prometheus/common/config"
+)
+
+// newProtectedServer returns a server answering 200 to the requests
+// authorized by ts.
+func newProtectedServer(t *testing.T, ts *TokenServer) *httptest.Server {
+	s := httptest.NewServer(ts.Authorize(http.HandlerFunc(func(http.ResponseWriter, *http.Request) {})))
+	t.Cleanup(s.Close)
+	return s
+}
+
+func get(t *testing.T, client *http.Client, url string) int {
+	t.Helper()
+	resp, err := client.Get(url)
+	require.NoError(t, err)
+	resp.Body.Close()
+	return resp.StatusCode
+}
+
+func TestTokenServerClientCredentials(t *testing.T) {
+	ts := NewTokenServer()
+	defer ts.Close()
+	ts.AddClient("id", "s1")
+	target := newProtectedServer(t, ts)
+
+	sm := NewSecretManager(map[string]string{"secret": "s1"})
+	cfg := config.DefaultHTTPClientConfig
+	cfg.OAuth2 = &config.OAuth2{
+		ClientID:        "id",
+		ClientSecretRef: "secret",
+		TokenURL:        ts.TokenURL(),
+		Scopes:          []string{"a", "b"},
+	}
+	client, err := config.NewClientFromConfig(cfg, "test", config.WithSecretManager(sm))
+	require.NoError(t, err)
+
+	require.Equal(t, http.StatusOK, get(t, client, target.URL))
+	require.Equal(t, http.StatusOK, get(t, client, target.URL))
+	reqs := ts.Requests()
+	require.Len(t, reqs, 1)
+	require.Equal(t, GrantTypeClientCredentials, reqs[0].GrantType)
+	require.Equal(t, "id", reqs[0].ClientID)
+	require.Equal(t, []string{"a", "b"}, reqs[0].Scopes)
+	require.True(t, ts.Valid(reqs[0].Token))
+
+	// Rotating the secret fetches a new token with it.
+	ts.AddClient("id", "s2")
+	sm.Set("secret", "s2")
+	require.Equal(t, http.StatusOK, get(t, client, target.URL))
+	require.Len(t, ts.Requests(), 2)
+	require.Equal(t, 3, sm.Fetches("secret"))
+
+	// An unknown secret fails the token request.
+	sm.Set("secret", "wrong")
+	_, err = client.Get(target.URL)
+	require.ErrorContains(t, err, "invalid_client")
+
+	sm.SetError("secret", errors.New("unavailable"))
+	_, err = client.Get(target.URL)
+	require.ErrorContains(t, err, "unavailable")
+}
+
+func TestTokenServerExpiry(t *testing.T) {
+	ts := NewTokenServer()
+	defer ts.Close()
+	target := newProtectedServer(t, ts)
+
+	cfg := config.DefaultHTTPClientConfig
+	cfg.OAuth2 = &config.OAuth2{ClientID: "id", ClientSecret: "secret", TokenURL: ts.TokenURL()}
+	client, err := config.NewClientFromConfig(cfg, "test")
+	require.NoError(t, err)
+
+	// Tokens expiring within the refresh margin of x/oauth2 are fetched again
+	// on every request.
+	ts.SetExpiresIn(time.Second)
+	require.Equal(t, http.StatusOK, get(t, client, target.URL))
+	require.Equal(t, http.StatusOK, get(t, client, target.URL))
+	require.Len(t, ts.Requests(), 2)
+
+	// The token requests can be failed once the client has detected how to
+	// authenticate, which x/oauth2 does by retrying its first token request.
+	ts.FailNext(http.StatusServiceUnavailable, "temporarily_unavailable")
+	_, err = client.Get(target.URL)
+	require.ErrorContains(t, err, "temporarily_unavailable")
+	require.Equal(t, http.StatusOK, get(t, client, target.URL))
+	require.Len(t, ts.Requests(), 4)
+
+	// A revoked token is refused.
+	ts.SetExpiresIn(time.Hour)
+	require.Equal(t, http.StatusOK, get(t, client, target.URL))
+	ts.RevokeTokens()
+	require.Equal(t, http.StatusUnauthorized, get(t, client, target.URL))
+}
+
+func TestTokenServerJWTBearer(t *testing.T) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+	keyPEM := pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(key)})
+
+	ts := NewTokenServer()
+	defer ts.Close()
+	target := newProtectedServer(t, ts)
+
+	cfg := config.DefaultHTTPClientConfig
+	cfg.OAuth2 = &config.OAuth2{
+		ClientID:             "id",
+		TokenURL:             ts.TokenURL(),
+		GrantType:            GrantTypeJWTBearer,
+		ClientCertificateKey: config.Secret(keyPEM),
+		Iss:                  "issuer",
+		Audience:             "audience",
+	}
+	client, err := config.NewClientFromConfig(cfg, "test")
+	require.NoError(t, err)
+
+	// Every request fetches a new token.
+	ts.SetExpiresIn(time.Second)
+
+	// The assertions of unknown issuers are refused, unless the test opts in.
+	_, err = client.Get(target.URL)
+	require.ErrorContains(t, err, `unknown issuer \"issuer\"`)
+	ts.AcceptUnverifiedAssertions()
+	require.Equal(t, http.StatusOK, get(t, client, target.URL))
+
+	// The assertion is verified once the key of its issuer is known.
+	other, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+	ts.AddIssuer("issuer", &other.PublicKey)
+	_, err = client.Get(target.URL)
+	require.ErrorContains(t, err, "invalid_grant")
+
+	ts.AddIssuer("issuer", &key.PublicKey)
+	require.Equal(t, http.StatusOK, get(t, client, target.URL))
+	reqs := ts.Requests()
+	require.Len(t, reqs, 4)
+	require.Equal(t, GrantTypeJWTBearer, reqs[3].GrantType)
+	require.Equal(t, "id", reqs[3].ClientID)
+	require.Equal(t, "issuer", reqs[3].Claims["iss"])
+}
+
+func TestSecretManagerRotate(t *testing.T) {
+	sm := NewSecretManager(nil)
+	_, err := sm.Fetch(context.Background(), "s")
+	require.EqualError(t, err, `secret "s" not found`)
+
+	sm.Rotate("s", "a", "b")
+	for _, expected := range []string{"a", "b", "b"} {
+		v, err := sm.Fetch(context.Background(), "s")
+		require.NoError(t, err)
+		require.Equal(t, expected, v)
+	}
+	require.Equal(t, 4, sm.Fetches("s"))
+
+	sm.Delete("s")
+	_, err = sm.Fetch(context.Background(), "s")
+	require.Error(t, err)
+}
@@ -0,0 +1,286 @@
+// Copyright 2026 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package configtest provides fakes of the services used by the HTTP clients
+// built from the config package, to test their credential paths
+// deterministically.
+package configtest
+
+import (
+	"crypto"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+const (
+	// GrantTypeClientCredentials is the grant type of the client credentials
+	// flow.
+	GrantTypeClientCredentials = "client_credentials"
+	// GrantTypeJWTBearer is the grant type of the JWT bearer flow.
+	GrantTypeJWTBearer = "urn:ietf:params:oauth:grant-type:jwt-bearer"
+)
+
+// TokenRequest is a request received by a TokenServer.
+type TokenRequest struct {
+	GrantType string
+	ClientID  string
+	Scopes    []string
+	// Claims are the claims of the JWT bearer assertion.
+	Claims jwt.MapClaims
+	// Params are all the form parameters of the request.
+	Params map[string][]string
+	// Token is the access token issued, if any.
+	Token string
+}
+
+// TokenServer is a fake OAuth2 authorization server issuing access tokens
+// with the client credentials and JWT bearer grants.
+type TokenServer struct {
+	*httptest.Server
+
+	mtx        sync.Mutex
+	expiresIn  time.Duration
+	clients    map[string]string
+	issuers    map[string]crypto.PublicKey
+	unverified bool
+	failures   []tokenFailure
+	requests   []TokenRequest
+	tokens     map[string]time.Time
+	issued     int
+}
+
+type tokenFailure struct {
+	status    int
+	errorCode string
+}
+
+// NewTokenServer starts a token server issuing tokens which expire after an
+// hour. It accepts any client until clients are added with AddClient, and
+// only the JWT bearer assertions of the issuers added with AddIssuer.
+func NewTokenServer() *TokenServer {
+	s := &TokenServer{
+		expiresIn: time.Hour,
+		clients:   map[string]string{},
+		issuers:   map[string]crypto.PublicKey{},
+		tokens:    map[string]time.Time{},
+	}
+	s.Server = httptest.NewServer(http.HandlerFunc(s.serveToken))
+	return s
+}
+
+// TokenURL returns the URL of the token endpoint.
+func (s *TokenServer) TokenURL() string {
+	return s.URL + "/token"
+}
+
+// AddClient restricts the client credentials grant to the added clients.
+func (s *TokenServer) AddClient(id, secret string) {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+	s.clients[id] = secret
+}
+
+// AddIssuer registers the key verifying the JWT bearer assertions of the
+// given issuer.
+func (s *TokenServer) AddIssuer(issuer string, key crypto.PublicKey) {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+	s.issuers[issuer] = key
+}
+
+// AcceptUnverifiedAssertions makes the server accept the JWT bearer
+// assertions of unknown issuers without verifying their signature. The
+// assertions of the issuers added with AddIssuer are still verified.
+func (s *TokenServer) AcceptUnverifiedAssertions() {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+	s.unverified = true
+}
+
+// SetExpiresIn sets the lifetime of the tokens issued next. A zero lifetime
+// issues tokens without expiry.
+func (s *TokenServer) SetExpiresIn(d time.Duration) {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+	s.expiresIn = d
+}
+
+// FailNext makes the next token request fail with the given HTTP status and
+// OAuth2 error code, such as "invalid_client". Successive calls queue
+// failures. Note that x/oauth2 retries the first token request of a client
+// with another client authentication method.
+func (s *TokenServer) FailNext(status int, errorCode string) {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+	s.failures = append(s.failures, tokenFailure{status: status, errorCode: errorCode})
+}
+
+// RevokeTokens revokes all the issued tokens, which Authorize refuses from
+// then on.
+func (s *TokenServer) RevokeTokens() {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+	clear(s.tokens)
+}
+
+// Requests returns the token requests received so far.
+func (s *TokenServer) Requests() []TokenRequest {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+	return append([]TokenRequest(nil), s.requests...)
+}
+
+// Valid reports whether the token was issued by the server and is neither
+// revoked nor expired.
+func (s *TokenServer) Valid(token string) bool {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+	expiry, ok := s.tokens[token]
+	return ok && (expiry.IsZero() || time.Now().Before(expiry))
+}
+
+// Authorize returns a handler serving the requests of next which carry a
+// valid token, and answering 401 to the others.
+func (s *TokenServer) Authorize(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if !ok || !s.Valid(token) {
+			w.Header().Set("WWW-Authenticate", `Bearer error="invalid_token"`)
+			http.Error(w, "invalid token", http.StatusUnauthorized)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+func (s *TokenServer) serveToken(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if err := r.ParseForm(); err != nil {
+		writeTokenError(w, http.StatusBadRequest, "invalid_request", err.Error())
+		return
+	}
+
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+
+	req := TokenRequest{
+		GrantType: r.PostForm.Get("grant_type"),
+		ClientID:  r.PostForm.Get("client_id"),
+		Params:    r.PostForm,
+	}
+	if scope := r.PostForm.Get("scope"); scope != "" {
+		req.Scopes = strings.Fields(scope)
+	}
+	defer func() {
+		s.requests = append(s.requests, req)
+	}()
+
+	if len(s.failures) > 0 {
+		f := s.failures[0]
+		s.failures = s.failures[1:]
+		writeTokenError(w, f.status, f.errorCode, "injected failure")
+		return
+	}
+
+	switch req.GrantType {
+	case GrantTypeClientCredentials:
+		secret := r.PostForm.Get("client_secret")
+		if id, pass, ok := r.BasicAuth(); ok {
+			req.ClientID, secret = id, pass
+		}
+		if expected, ok := s.clients[req.ClientID]; len(s.clients) > 0 && (!ok || expected != secret) {
+			writeTokenError(w, http.StatusUnauthorized, "invalid_client", "unknown client or wrong secret")
+			return
+		}
+	case GrantTypeJWTBearer:
+		claims, err := s.parseAssertion(r.PostForm.Get("assertion"))
+		if err != nil {
+			writeTokenError(w, http.StatusBadRequest, "invalid_grant", err.Error())
+			return
+		}
+		req.Claims = claims
+		if sub, err := claims.GetSubject(); err == nil && req.ClientID == "" {
+			req.ClientID = sub
+		}
+	default:
+		writeTokenError(w, http.StatusBadRequest, "unsupported_grant_type", fmt.Sprintf("unsupported grant type %q", req.GrantType))
+		return
+	}
+
+	s.issued++
+	req.Token = "token-" + strconv.Itoa(s.issued)
+	var expiry time.Time
+	resp := map[string]any{
+		"access_token": req.Token,
+		"token_type":   "Bearer",
+	}
+	if s.expiresIn > 0 {
+		expiry = time.Now().Add(s.expiresIn)
+		resp["expires_in"] = int(s.expiresIn.Seconds())
+	}
+	s.tokens[req.Token] = expiry
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(resp)
+}
+
+// parseAssertion parses the JWT bearer assertion and verifies it with the key
+// of its issuer. The assertions of unknown issuers are only accepted
+// unverified after AcceptUnverifiedAssertions.
+func (s *TokenServer) parseAssertion(assertion string) (jwt.MapClaims, error) {
+	if assertion == "" {
+		return nil, fmt.Errorf("missing assertion")
+	}
+	claims := jwt.MapClaims{}
+	if _, _, err := jwt.NewParser().ParseUnverified(assertion, claims); err != nil {
+		return nil, err
+	}
+	iss, err := claims.GetIssuer()
+	if err != nil {
+		return nil, err
+	}
+	key, ok := s.issuers[iss]
+	if !ok {
+		if !s.unverified {
+			return nil, fmt.Errorf("unknown issuer %q", iss)
+		}
+		return claims, nil
+	}
+	claims = jwt.MapClaims{}
+	if _, err := jwt.ParseWithClaims(assertion, claims, func(*jwt.Token) (any, error) {
+		return key, nil
+	}); err != nil {
+		return nil, err
+	}
+	return claims, nil
+}
+
+func writeTokenError(w http.ResponseWriter, status int, code, description string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(map[string]string{
+		"error":             code,
+		"error_description": description,
+	})
+}
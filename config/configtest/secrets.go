@@ -0,0 +1,103 @@
+// Copyright 2026 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package configtest
+
+import (
+	"context"
+	"fmt"
+	"sync"
+
+	"github.com/prometheus/common/config"
+)
+
+var _ config.SecretManager = (*SecretManager)(nil)
+
+// SecretManager is an in-memory config.SecretManager whose secrets can be
+// rotated and made to fail.
+type SecretManager struct {
+	mtx     sync.Mutex
+	secrets map[string][]string
+	errs    map[string]error
+	fetches map[string]int
+}
+
+// NewSecretManager returns a SecretManager holding the given secrets.
+func NewSecretManager(secrets map[string]string) *SecretManager {
+	m := &SecretManager{
+		secrets: map[string][]string{},
+		errs:    map[string]error{},
+		fetches: map[string]int{},
+	}
+	for ref, value := range secrets {
+		m.secrets[ref] = []string{value}
+	}
+	return m
+}
+
+// Fetch implements config.SecretManager.
+func (m *SecretManager) Fetch(_ context.Context, ref string) (string, error) {
+	m.mtx.Lock()
+	defer m.mtx.Unlock()
+	m.fetches[ref]++
+	if err, ok := m.errs[ref]; ok {
+		return "", err
+	}
+	values, ok := m.secrets[ref]
+	if !ok {
+		return "", fmt.Errorf("secret %q not found", ref)
+	}
+	if len(values) > 1 {
+		m.secrets[ref] = values[1:]
+	}
+	return values[0], nil
+}
+
+// Set sets the value of the secret, which is returned from the next fetch.
+func (m *SecretManager) Set(ref, value string) {
+	m.Rotate(ref, value)
+}
+
+// Rotate makes the next fetches of the secret return the given values in
+// turn, the last one being kept for the later fetches.
+func (m *SecretManager) Rotate(ref string, values ...string) {
+	if len(values) == 0 {
+		return
+	}
+	m.mtx.Lock()
+	defer m.mtx.Unlock()
+	m.secrets[ref] = append([]string(nil), values...)
+	delete(m.errs, ref)
+}
+
+// Delete removes the secret.
+func (m *SecretManager) Delete(ref string) {
+	m.mtx.Lock()
+	defer m.mtx.Unlock()
+	delete(m.secrets, ref)
+}
+
+// SetError makes the fetches of the secret fail with err, until the secret
+// is set again.
+func (m *SecretManager) SetError(ref string, err error) {
+	m.mtx.Lock()
+	defer m.mtx.Unlock()
+	m.errs[ref] = err
+}
+
+// Fetches returns the number of fetches of the secret.
+func (m *SecretManager) Fetches(ref string) int {
+	m.mtx.Lock()
+	defer m.mtx.Unlock()
+	return m.fetches[ref]
+}
@@ -0,0 +1,351 @@
+// Copyright 2026 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package config
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"net"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/prometheus/common/model"
+)
+
+// CurlOptions configures NewCurlCommand.
+type CurlOptions struct {
+	// ShowSecrets includes the secrets in the command. Otherwise they are
+	// replaced by <secret>.
+	ShowSecrets bool
+	// SecretManager resolves the secret refs when ShowSecrets is set.
+	SecretManager SecretManager
+}
+
+// CurlCommand is a curl invocation equivalent to an HTTPClientConfig.
+type CurlCommand struct {
+	// Args are the arguments of the command, starting with "curl".
+	Args []string
+	// Notes describe the settings which curl cannot reproduce.
+	Notes []string
+}
+
+// String returns the command quoted for a POSIX shell.
+func (c *CurlCommand) String() string {
+	quoted := make([]string, 0, len(c.Args))
+	for _, arg := range c.Args {
+		quoted = append(quoted, shellQuote(arg))
+	}
+	return strings.Join(quoted, " ")
+}
+
+// NewCurlCommand renders the request of cfg to target as a curl command, to
+// reproduce scrape failures outside of Prometheus.
+func NewCurlCommand(ctx context.Context, cfg HTTPClientConfig, target string, opts CurlOptions) (*CurlCommand, error) {
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+	u, err := url.Parse(target)
+	if err != nil {
+		return nil, err
+	}
+	b := &curlBuilder{ctx: ctx, opts: opts, cmd: &CurlCommand{Args: []string{"curl"}}}
+
+	b.tls(&cfg.TLSConfig, u)
+	b.proxy(&cfg.ProxyConfig)
+	b.dialer(cfg.Dialer)
+	b.transport(cfg.Transport)
+	if cfg.Hedging != nil {
+		b.note("hedging cannot be expressed, curl sends a single request")
+	}
+	if !cfg.EnableHTTP2 {
+		b.add("--http1.1")
+	}
+	if cfg.FollowRedirects {
+		b.add("--location")
+		b.redirects(cfg.RedirectPolicy)
+	}
+	if cfg.MaxResponseBodyBytes > 0 {
+		b.add("--max-filesize", strconv.FormatInt(cfg.MaxResponseBodyBytes, 10))
+	}
+	if cfg.HTTPHeaders != nil {
+		names := make([]string, 0, len(cfg.HTTPHeaders.Headers))
+		for name := range cfg.HTTPHeaders.Headers {
+			names = append(names, name)
+		}
+		slices.Sort(names)
+		for _, name := range names {
+			b.header(name, cfg.HTTPHeaders.Headers[name])
+		}
+	}
+	b.auth(&cfg)
+
+	b.add(u.String())
+	if b.err != nil {
+		return nil, b.err
+	}
+	return b.cmd, nil
+}
+
+type curlBuilder struct {
+	ctx  context.Context
+	opts CurlOptions
+	cmd  *CurlCommand
+	err  error
+}
+
+func (b *curlBuilder) add(args ...string) {
+	b.cmd.Args = append(b.cmd.Args, args...)
+}
+
+func (b *curlBuilder) note(format string, args ...any) {
+	b.cmd.Notes = append(b.cmd.Notes, fmt.Sprintf(format, args...))
+}
+
+// secret returns the value of the secret, or <secret> if secrets are hidden.
+func (b *curlBuilder) secret(text Secret, file, ref string) string {
+	if !b.opts.ShowSecrets {
+		return secretToken
+	}
+	return b.fetch(text, file, ref)
+}
+
+// fetch returns the value of a setting which is not secret, but might be read
+// from a file or the secret manager.
+func (b *curlBuilder) fetch(text Secret, file, ref string) string {
+	if ref != "" && b.opts.SecretManager == nil {
+		b.note("secret ref %q cannot be resolved without a secret manager", ref)
+		return "<" + ref + ">"
+	}
+	s, err := toSecret(b.opts.SecretManager, text, file, ref)
+	if err != nil || s == nil {
+		return ""
+	}
+	v, err := s.Fetch(b.ctx)
+	if err != nil && b.err == nil {
+		b.err = err
+	}
+	return v
+}
+
+func (b *curlBuilder) tls(cfg *TLSConfig, u *url.URL) {
+	switch {
+	case cfg.CAFile != "":
+		b.add("--cacert", cfg.CAFile)
+	case cfg.CADir != "":
+		// OpenSSL builds of curl expect the file names to be hashed, as
+		// done by c_rehash.
+		b.add("--capath", cfg.CADir)
+	case cfg.CA != "" || cfg.CARef != "":
+		b.note("inline and ref CA certs cannot be passed to curl, write them to a file for --cacert")
+	}
+	if cfg.CAAppendSystemRoots {
+		b.note("ca_append_system_roots cannot be expressed, curl trusts either the system or the given CA certs")
+	}
+	switch {
+	case cfg.CertFile != "" && cfg.KeyFile != "":
+		b.add("--cert", cfg.CertFile, "--key", cfg.KeyFile)
+	case cfg.usingClientCert() || cfg.usingClientKey():
+		b.note("inline and ref client certs and keys cannot be passed to curl, write them to files for --cert and --key")
+	}
+	if len(cfg.ClientCertificates) > 0 {
+		b.note("client_certificates cannot be expressed, pass the expected pair with --cert and --key")
+	}
+	if cfg.InsecureSkipVerify {
+		b.add("--insecure")
+	}
+	if v, ok := curlTLSVersions[cfg.MinVersion]; ok {
+		b.add("--tlsv" + v)
+	}
+	if v, ok := curlTLSVersions[cfg.MaxVersion]; ok {
+		b.add("--tls-max", v)
+	}
+
+	if cfg.ServerName != "" && cfg.ServerName != u.Hostname() {
+		// curl derives the SNI and the verified name from the URL, so the
+		// URL targets the server name while --connect-to dials the original
+		// host and the Host header keeps its value.
+		port := u.Port()
+		if port == "" {
+			port = "80"
+			if u.Scheme == "https" {
+				port = "443"
+			}
+		}
+		b.add("--connect-to", net.JoinHostPort(cfg.ServerName, port)+":"+net.JoinHostPort(u.Hostname(), port))
+		b.add("--header", "Host: "+u.Host)
+		if u.Port() == "" {
+			u.Host = cfg.ServerName
+		} else {
+			u.Host = net.JoinHostPort(cfg.ServerName, port)
+		}
+	}
+}
+
+func (b *curlBuilder) redirects(p *RedirectPolicy) {
+	if p == nil {
+		return
+	}
+	maxHops := p.MaxHops
+	if maxHops == 0 {
+		maxHops = DefaultRedirectPolicy.MaxHops
+	}
+	b.add("--max-redirs", strconv.Itoa(maxHops))
+	if p.RefuseHTTPSDowngrade {
+		b.add("--proto-redir", "=https")
+	}
+	if len(p.AllowedHosts) > 0 {
+		b.note("redirect_policy allowed_hosts cannot be expressed, curl follows redirects to any host")
+	}
+}
+
+var curlTLSVersions = map[TLSVersion]string{
+	tls.VersionTLS10: "1.0",
+	tls.VersionTLS11: "1.1",
+	tls.VersionTLS12: "1.2",
+	tls.VersionTLS13: "1.3",
+}
+
+func (b *curlBuilder) proxy(cfg *ProxyConfig) {
+	if cfg.ProxyFromEnvironment {
+		b.note("proxy_from_environment is the default behaviour of curl, which reads the same environment variables")
+	}
+	if cfg.ProxyURL.URL != nil && cfg.ProxyURL.String() != "" {
+		if b.opts.ShowSecrets {
+			b.add("--proxy", cfg.ProxyURL.String())
+		} else {
+			b.add("--proxy", cfg.ProxyURL.Redacted())
+		}
+	}
+	if cfg.NoProxy != "" {
+		b.add("--noproxy", cfg.NoProxy)
+	}
+	names := make([]string, 0, len(cfg.ProxyConnectHeader))
+	for name := range cfg.ProxyConnectHeader {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+	for _, name := range names {
+		for _, v := range cfg.ProxyConnectHeader[name] {
+			b.add("--proxy-header", name+": "+b.secret(v, "", ""))
+		}
+	}
+}
+
+func (b *curlBuilder) dialer(cfg *DialerConfig) {
+	if cfg == nil {
+		return
+	}
+	switch cfg.IPFamily {
+	case IPFamilyIPv4:
+		b.add("--ipv4")
+	case IPFamilyIPv6:
+		b.add("--ipv6")
+	}
+	if cfg.Resolver != "" {
+		// Requires a curl built with c-ares.
+		addr, _ := resolverAddr(cfg.Resolver)
+		b.add("--dns-servers", addr)
+	}
+	if cfg.DNSCache != nil {
+		b.note("dialer dns_cache cannot be expressed, curl resolves the host on every run")
+	}
+}
+
+func (b *curlBuilder) transport(cfg *TransportConfig) {
+	if cfg == nil {
+		return
+	}
+	if cfg.DialTimeout > 0 {
+		b.add("--connect-timeout", curlSeconds(time.Duration(cfg.DialTimeout)))
+	}
+	if cfg.KeepAlive > 0 {
+		b.add("--keepalive-time", strconv.Itoa(max(int(time.Duration(cfg.KeepAlive).Seconds()), 1)))
+	}
+	if cfg.ExpectContinueTimeout != nil {
+		b.add("--expect100-timeout", curlSeconds(time.Duration(*cfg.ExpectContinueTimeout)))
+	}
+	var ignored []string
+	for _, s := range []struct {
+		name string
+		d    *model.Duration
+	}{
+		{"tls_handshake_timeout", &cfg.TLSHandshakeTimeout},
+		{"response_header_timeout", &cfg.ResponseHeaderTimeout},
+		{"http2_read_idle_timeout", cfg.HTTP2ReadIdleTimeout},
+		{"http2_ping_timeout", &cfg.HTTP2PingTimeout},
+	} {
+		if s.d != nil && *s.d > 0 {
+			ignored = append(ignored, s.name)
+		}
+	}
+	if len(ignored) > 0 {
+		b.note("transport %s cannot be expressed, bound the whole request with --max-time instead", strings.Join(ignored, ", "))
+	}
+}
+
+// curlSeconds formats d as the decimal number of seconds expected by curl.
+func curlSeconds(d time.Duration) string {
+	return strconv.FormatFloat(d.Seconds(), 'f', -1, 64)
+}
+
+func (b *curlBuilder) header(name string, h Header) {
+	for _, v := range h.Values {
+		b.add("--header", name+": "+v)
+	}
+	for _, v := range h.Secrets {
+		b.add("--header", name+": "+b.secret(v, "", ""))
+	}
+	for _, f := range h.Files {
+		b.add("--header", name+": "+b.secret("", f, ""))
+	}
+	for _, r := range h.Refs {
+		b.add("--header", name+": "+b.secret("", "", r))
+	}
+}
+
+func (b *curlBuilder) auth(cfg *HTTPClientConfig) {
+	switch {
+	case cfg.BasicAuth != nil:
+		a := cfg.BasicAuth
+		b.add("--user", b.fetch(Secret(a.Username), a.UsernameFile, a.UsernameRef)+":"+b.secret(a.Password, a.PasswordFile, a.PasswordRef))
+	case cfg.DigestAuth != nil:
+		a := cfg.DigestAuth
+		b.add("--digest", "--user", b.fetch(Secret(a.Username), a.UsernameFile, a.UsernameRef)+":"+b.secret(a.Password, a.PasswordFile, a.PasswordRef))
+	case cfg.Authorization != nil:
+		a := cfg.Authorization
+		b.add("--header", "Authorization: "+a.Type+" "+b.secret(a.Credentials, a.CredentialsFile, a.CredentialsRef))
+	case cfg.OAuth2 != nil:
+		b.note("oauth2 cannot be expressed, fetch a token from %s and pass it with --header 'Authorization: Bearer <token>'", cfg.OAuth2.TokenURL)
+	}
+	if cfg.HMACSigning != nil {
+		b.note("hmac_signing cannot be expressed, the signature depends on the time of the request")
+	}
+	if cfg.RequestOverrides != nil {
+		b.note("request_overrides are set per request and not reproduced")
+	}
+}
+
+// shellQuote quotes s for a POSIX shell if needed.
+func shellQuote(s string) string {
+	if s != "" && strings.IndexFunc(s, func(r rune) bool {
+		return !(r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || strings.ContainsRune("-_./:=@%+,", r))
+	}) < 0 {
+		return s
+	}
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
@@ -0,0 +1,373 @@
+// Copyright 2026 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package config
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"time"
+)
+
+// DiagnosticReport is the outcome of the checks run by Diagnose.
+type DiagnosticReport struct {
+	// URL is the target, with its password redacted.
+	URL string
+	// Checks are the checks in the order they ran. The checks stop at the
+	// first failure.
+	Checks []DiagnosticCheck
+	// ProxyURL is the proxy used for the target, with its password redacted.
+	ProxyURL string
+	// Addresses are the resolved addresses of the target, or of the proxy.
+	Addresses []string
+	// TLS describes the TLS handshake with the target.
+	TLS *TLSDiagnostic
+	// StatusCode is the HTTP status code of the target.
+	StatusCode int
+}
+
+// Err returns the error of the failed check, if any.
+func (r *DiagnosticReport) Err() error {
+	for _, c := range r.Checks {
+		if c.Err != nil {
+			return fmt.Errorf("%s: %w", c.Name, c.Err)
+		}
+	}
+	return nil
+}
+
+// DiagnosticCheck is a single stage of the diagnostics.
+type DiagnosticCheck struct {
+	// Name is one of proxy, dns, tcp, tls, oauth2 and http.
+	Name     string
+	Duration time.Duration
+	// Err is the reason of the failure.
+	Err error
+	// Skipped is the reason why the check did not apply, if it did not.
+	Skipped string
+}
+
+// TLSDiagnostic describes a TLS handshake.
+type TLSDiagnostic struct {
+	ServerName  string
+	Version     string
+	CipherSuite string
+	// NegotiatedProtocol is the protocol negotiated with ALPN.
+	NegotiatedProtocol string
+	// Chain is the certificate chain presented by the server, leaf first.
+	Chain []CertificateDiagnostic
+	// VerificationError is the reason why the chain is not trusted.
+	VerificationError string
+}
+
+// CertificateDiagnostic describes a certificate presented by a server.
+type CertificateDiagnostic struct {
+	Subject   string
+	Issuer    string
+	DNSNames  []string
+	NotBefore time.Time
+	NotAfter  time.Time
+}
+
+// Diagnose runs staged connectivity checks against target with cfg: proxy
+// resolution, DNS, TCP connect, TLS handshake, OAuth2 token fetch and the
+// final HTTP request. The failure of a check is reported in the returned
+// report, while an error is only returned if cfg or target are invalid.
+func Diagnose(ctx context.Context, cfg HTTPClientConfig, target string, optFuncs ...HTTPClientOption) (*DiagnosticReport, error) {
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+	u, err := url.Parse(target)
+	if err != nil {
+		return nil, err
+	}
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return nil, fmt.Errorf("unsupported scheme %q", u.Scheme)
+	}
+	opts := defaultHTTPClientOptions
+	for _, opt := range optFuncs {
+		opt.applyToHTTPClientOptions(&opts)
+	}
+
+	d := &diagnoser{
+		ctx:      ctx,
+		cfg:      &cfg,
+		url:      u,
+		opts:     opts,
+		optFuncs: optFuncs,
+		report:   &DiagnosticReport{URL: u.Redacted()},
+	}
+	_ = d.run("proxy", d.proxy) &&
+		d.run("dns", d.resolve) &&
+		d.run("tcp", d.connect) &&
+		d.run("tls", d.handshake) &&
+		d.run("oauth2", d.fetchToken) &&
+		d.run("http", d.request)
+	if d.conn != nil {
+		d.conn.Close()
+	}
+	return d.report, nil
+}
+
+// errSkipped is returned by a check which does not apply, with the reason in
+// diagnoser.skipped.
+var errSkipped = errors.New("skipped")
+
+type diagnoser struct {
+	ctx      context.Context
+	cfg      *HTTPClientConfig
+	url      *url.URL
+	opts     httpClientOptions
+	optFuncs []HTTPClientOption
+	report   *DiagnosticReport
+
+	proxyURL *url.URL
+	conn     net.Conn
+	skipped  string
+}
+
+// run runs the check and reports whether the next checks should run.
+func (d *diagnoser) run(name string, check func() error) bool {
+	start := time.Now()
+	err := check()
+	c := DiagnosticCheck{Name: name, Duration: time.Since(start)}
+	switch {
+	case errors.Is(err, errSkipped):
+		c.Duration = 0
+		c.Skipped = d.skipped
+	case err != nil:
+		c.Err = err
+	}
+	d.report.Checks = append(d.report.Checks, c)
+	return c.Err == nil
+}
+
+func (d *diagnoser) skip(reason string) error {
+	d.skipped = reason
+	return errSkipped
+}
+
+func (d *diagnoser) proxy() error {
+	proxyFn := d.cfg.Proxy()
+	if proxyFn == nil {
+		return d.skip("no proxy configured")
+	}
+	req := (&http.Request{URL: d.url, Header: http.Header{}}).WithContext(d.ctx)
+	proxyURL, err := proxyFn(req)
+	if err != nil {
+		return err
+	}
+	if proxyURL == nil {
+		return d.skip("no proxy used for " + d.url.Host)
+	}
+	d.proxyURL = proxyURL
+	d.report.ProxyURL = proxyURL.Redacted()
+	return nil
+}
+
+// address returns the host and port of the first hop, which is the proxy if
+// one is used.
+func (d *diagnoser) address() (host, port string) {
+	u := d.url
+	if d.proxyURL != nil {
+		u = d.proxyURL
+	}
+	port = u.Port()
+	if port == "" {
+		port = "80"
+		if u.Scheme == "https" {
+			port = "443"
+		}
+	}
+	return u.Hostname(), port
+}
+
+// resolve resolves the first hop like the dialer of the client would, with
+// the resolver, address family and cache of the dialer config.
+func (d *diagnoser) resolve() error {
+	host, _ := d.address()
+	if net.ParseIP(host) != nil {
+		d.report.Addresses = []string{host}
+		return nil
+	}
+	if d.cfg.Dialer == nil {
+		addrs, err := net.DefaultResolver.LookupHost(d.ctx, host)
+		if err != nil {
+			return err
+		}
+		d.report.Addresses = addrs
+		return nil
+	}
+	addrs, _, err := newLookupFunc(d.cfg.Dialer)(d.ctx, host)
+	if err != nil {
+		return err
+	}
+	if len(addrs) == 0 {
+		return fmt.Errorf("no %s address found for %s", d.cfg.Dialer.family(), host)
+	}
+	for _, addr := range addrs {
+		d.report.Addresses = append(d.report.Addresses, addr.String())
+	}
+	return nil
+}
+
+// connect dials the resolved addresses with the dialer of the client, so
+// that the dial timeout and the address family apply.
+func (d *diagnoser) connect() error {
+	dial, err := newDialContextFunc(d.cfg, d.opts)
+	if err != nil {
+		return err
+	}
+	if dial == nil {
+		dial = (&net.Dialer{}).DialContext
+	}
+	_, port := d.address()
+	var errs []error
+	for _, addr := range d.report.Addresses {
+		conn, err := dial(d.ctx, "tcp", net.JoinHostPort(addr, port))
+		if err == nil {
+			d.conn = conn
+			return nil
+		}
+		errs = append(errs, err)
+	}
+	return errors.Join(errs...)
+}
+
+func (d *diagnoser) handshake() error {
+	if d.url.Scheme != "https" {
+		return d.skip("plain HTTP target")
+	}
+	if d.proxyURL != nil {
+		return d.skip("the connection is tunnelled through the proxy, see the http check")
+	}
+	tlsConfig, err := d.opts.newTLSConfigFunc(d.ctx, &d.cfg.TLSConfig, WithSecretManager(d.opts.secretManager))
+	if err != nil {
+		return err
+	}
+	tlsConfig = tlsConfig.Clone()
+	if tlsConfig.ServerName == "" {
+		tlsConfig.ServerName = d.url.Hostname()
+	}
+	if d.cfg.EnableHTTP2 {
+		tlsConfig.NextProtos = []string{"h2", "http/1.1"}
+	}
+	// The chain is verified after the handshake, so that it is reported
+	// even if it is not trusted.
+	verify := !tlsConfig.InsecureSkipVerify
+	tlsConfig.InsecureSkipVerify = true
+
+	conn := tls.Client(d.conn, tlsConfig)
+	if err := conn.HandshakeContext(d.ctx); err != nil {
+		return err
+	}
+	state := conn.ConnectionState()
+	diag := &TLSDiagnostic{
+		ServerName:         tlsConfig.ServerName,
+		Version:            tls.VersionName(state.Version),
+		CipherSuite:        tls.CipherSuiteName(state.CipherSuite),
+		NegotiatedProtocol: state.NegotiatedProtocol,
+	}
+	for _, cert := range state.PeerCertificates {
+		diag.Chain = append(diag.Chain, CertificateDiagnostic{
+			Subject:   cert.Subject.String(),
+			Issuer:    cert.Issuer.String(),
+			DNSNames:  cert.DNSNames,
+			NotBefore: cert.NotBefore,
+			NotAfter:  cert.NotAfter,
+		})
+	}
+	d.report.TLS = diag
+	d.conn = conn
+
+	if !verify || len(state.PeerCertificates) == 0 {
+		return nil
+	}
+	intermediates := x509.NewCertPool()
+	for _, cert := range state.PeerCertificates[1:] {
+		intermediates.AddCert(cert)
+	}
+	_, err = state.PeerCertificates[0].Verify(x509.VerifyOptions{
+		Roots:         tlsConfig.RootCAs,
+		DNSName:       tlsConfig.ServerName,
+		Intermediates: intermediates,
+	})
+	if err != nil {
+		diag.VerificationError = err.Error()
+		return err
+	}
+	return nil
+}
+
+// tokenProbeRoundTripper answers the requests authorized by the OAuth2
+// RoundTripper without sending them.
+type tokenProbeRoundTripper struct{}
+
+func (tokenProbeRoundTripper) RoundTrip(req *http.Request) (*http.Response, error) {
+	return &http.Response{
+		StatusCode: http.StatusNoContent,
+		Body:       io.NopCloser(http.NoBody),
+		Request:    req,
+	}, nil
+}
+
+func (d *diagnoser) fetchToken() error {
+	if d.cfg.OAuth2 == nil {
+		return d.skip("no oauth2 configured")
+	}
+	credential, err := d.cfg.OAuth2.credential(d.opts.secretManager)
+	if err != nil {
+		return err
+	}
+	rt := NewOAuth2RoundTripper(credential, d.cfg.OAuth2, tokenProbeRoundTripper{}, d.optFuncs...)
+	req, err := http.NewRequestWithContext(d.ctx, http.MethodGet, d.url.String(), nil)
+	if err != nil {
+		return err
+	}
+	resp, err := rt.RoundTrip(req)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	return nil
+}
+
+func (d *diagnoser) request() error {
+	client, err := NewClientFromConfig(*d.cfg, "diagnostics", d.optFuncs...)
+	if err != nil {
+		return err
+	}
+	defer client.CloseIdleConnections()
+	req, err := http.NewRequestWithContext(d.ctx, http.MethodGet, d.url.String(), nil)
+	if err != nil {
+		return err
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	_, _ = io.Copy(io.Discard, resp.Body)
+	d.report.StatusCode = resp.StatusCode
+	if resp.StatusCode >= http.StatusBadRequest {
+		return fmt.Errorf("server returned HTTP status %s", resp.Status)
+	}
+	return nil
+}
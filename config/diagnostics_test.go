@@ -0,0 +1,178 @@
+// Copyright 2026 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package config
+
+import (
+	"context"
+	"crypto/tls"
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/prometheus/common/model"
+)
+
+// checkResults summarizes the checks of r as name to "ok", "skip" or the
+// error message.
+func checkResults(r *DiagnosticReport) map[string]string {
+	results := map[string]string{}
+	for _, c := range r.Checks {
+		switch {
+		case c.Err != nil:
+			results[c.Name] = c.Err.Error()
+		case c.Skipped != "":
+			results[c.Name] = "skip"
+		default:
+			results[c.Name] = "ok"
+		}
+	}
+	return results
+}
+
+func TestDiagnoseTLS(t *testing.T) {
+	ts := httptest.NewUnstartedServer(http.HandlerFunc(func(http.ResponseWriter, *http.Request) {}))
+	cert, err := tls.LoadX509KeyPair(ServerCertificatePath, ServerKeyPath)
+	require.NoError(t, err)
+	ts.TLS = &tls.Config{Certificates: []tls.Certificate{cert}}
+	ts.Config.ErrorLog = log.New(io.Discard, "", 0)
+	ts.StartTLS()
+	defer ts.Close()
+
+	cfg := DefaultHTTPClientConfig
+	cfg.TLSConfig = TLSConfig{CAFile: TLSCAChainPath, ServerName: "localhost"}
+	r, err := Diagnose(context.Background(), cfg, ts.URL)
+	require.NoError(t, err)
+	require.NoError(t, r.Err())
+	require.Equal(t, map[string]string{
+		"proxy":  "skip",
+		"dns":    "ok",
+		"tcp":    "ok",
+		"tls":    "ok",
+		"oauth2": "skip",
+		"http":   "ok",
+	}, checkResults(r))
+	require.Equal(t, []string{"127.0.0.1"}, r.Addresses)
+	require.Equal(t, "localhost", r.TLS.ServerName)
+	require.Equal(t, "TLS 1.3", r.TLS.Version)
+	require.Len(t, r.TLS.Chain, 1)
+	require.Equal(t, []string{"localhost"}, r.TLS.Chain[0].DNSNames)
+	require.Empty(t, r.TLS.VerificationError)
+	require.Equal(t, http.StatusOK, r.StatusCode)
+
+	// Without the CA, the chain is still reported.
+	cfg.TLSConfig = TLSConfig{ServerName: "localhost"}
+	r, err = Diagnose(context.Background(), cfg, ts.URL)
+	require.NoError(t, err)
+	require.ErrorContains(t, r.Err(), "tls: x509: certificate signed by unknown authority")
+	require.Len(t, r.Checks, 4)
+	require.Len(t, r.TLS.Chain, 1)
+	require.Contains(t, r.TLS.VerificationError, "certificate signed by unknown authority")
+}
+
+func TestDiagnoseHTTPStatus(t *testing.T) {
+	ts := httptest.NewServer(http.NotFoundHandler())
+	defer ts.Close()
+
+	r, err := Diagnose(context.Background(), DefaultHTTPClientConfig, ts.URL)
+	require.NoError(t, err)
+	require.Equal(t, map[string]string{
+		"proxy":  "skip",
+		"dns":    "ok",
+		"tcp":    "ok",
+		"tls":    "skip",
+		"oauth2": "skip",
+		"http":   "server returned HTTP status 404 Not Found",
+	}, checkResults(r))
+	require.Equal(t, http.StatusNotFound, r.StatusCode)
+}
+
+func TestDiagnoseOAuth2(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/token" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+	}))
+	defer ts.Close()
+
+	cfg := DefaultHTTPClientConfig
+	cfg.OAuth2 = &OAuth2{ClientID: "id", ClientSecret: "secret", TokenURL: ts.URL + "/token"}
+	r, err := Diagnose(context.Background(), cfg, ts.URL)
+	require.NoError(t, err)
+	require.ErrorContains(t, r.Err(), "oauth2: ")
+	require.Len(t, r.Checks, 5)
+	require.Zero(t, r.StatusCode)
+}
+
+func TestDiagnoseProxy(t *testing.T) {
+	proxy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		require.Equal(t, "http://target.invalid/metrics", r.URL.String())
+	}))
+	defer proxy.Close()
+
+	cfg := DefaultHTTPClientConfig
+	proxyURL, err := url.Parse(proxy.URL)
+	require.NoError(t, err)
+	cfg.ProxyURL = URL{proxyURL}
+	r, err := Diagnose(context.Background(), cfg, "http://target.invalid/metrics")
+	require.NoError(t, err)
+	require.NoError(t, r.Err())
+	require.Equal(t, proxy.URL, r.ProxyURL)
+	require.Equal(t, []string{"127.0.0.1"}, r.Addresses)
+	require.Equal(t, http.StatusOK, r.StatusCode)
+}
+
+func TestDiagnoseDialer(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(http.ResponseWriter, *http.Request) {}))
+	defer ts.Close()
+	u, err := url.Parse(ts.URL)
+	require.NoError(t, err)
+	dns := newTestDNSServer(t, map[string][]netip.Addr{
+		"target.example.": {netip.MustParseAddr("127.0.0.1"), netip.MustParseAddr("::1")},
+	}, 60)
+
+	// The dial timeout of the transport applies to the custom dial function.
+	var deadlines []bool
+	dial := func(ctx context.Context, network, addr string) (net.Conn, error) {
+		_, ok := ctx.Deadline()
+		deadlines = append(deadlines, ok)
+		return (&net.Dialer{}).DialContext(ctx, network, addr)
+	}
+
+	cfg := DefaultHTTPClientConfig
+	cfg.Dialer = &DialerConfig{IPFamily: IPFamilyIPv4, Resolver: dns.addr()}
+	cfg.Transport = &TransportConfig{DialTimeout: model.Duration(time.Second)}
+	r, err := Diagnose(context.Background(), cfg, "http://target.example:"+u.Port(), WithDialContextFunc(dial))
+	require.NoError(t, err)
+	require.NoError(t, r.Err())
+	// The IPv6 address is left out by the ip_family of the dialer.
+	require.Equal(t, []string{"127.0.0.1"}, r.Addresses)
+	require.NotEmpty(t, deadlines)
+	for _, ok := range deadlines {
+		require.True(t, ok)
+	}
+
+	cfg.Dialer = &DialerConfig{IPFamily: IPFamilyIPv6, Resolver: dns.addr()}
+	r, err = Diagnose(context.Background(), cfg, "http://127.0.0.1:"+u.Port())
+	require.NoError(t, err)
+	require.EqualError(t, r.Err(), "tcp: address 127.0.0.1 is not allowed by ip_family ipv6")
+}
@@ -0,0 +1,673 @@
+// Copyright 2026 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package config
+
+import (
+	"container/list"
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand/v2"
+	"net"
+	"net/netip"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/net/dns/dnsmessage"
+
+	"github.com/prometheus/common/model"
+)
+
+// IPFamily selects the address families used when dialing.
+type IPFamily string
+
+const (
+	// IPFamilyDual dials IPv4 and IPv6 addresses, trying IPv4 addresses first.
+	IPFamilyDual IPFamily = "dual"
+	// IPFamilyIPv4 only dials IPv4 addresses.
+	IPFamilyIPv4 IPFamily = "ipv4"
+	// IPFamilyIPv6 only dials IPv6 addresses.
+	IPFamilyIPv6 IPFamily = "ipv6"
+)
+
+// DefaultDNSCacheConfig is the default DNS cache configuration.
+var DefaultDNSCacheConfig = DNSCacheConfig{
+	MaxEntries: 10000,
+	DefaultTTL: model.Duration(30 * time.Second),
+}
+
+// dnsQueryTimeout bounds a single query to the configured resolver.
+const dnsQueryTimeout = 5 * time.Second
+
+// DialerConfig configures how an HTTP client resolves and dials the hosts it
+// connects to.
+type DialerConfig struct {
+	// IPFamily restricts the address families used to connect to the targets.
+	// Valid values are "ipv4", "ipv6" and "dual". Defaults to "dual".
+	IPFamily IPFamily `yaml:"ip_family,omitempty" json:"ip_family,omitempty"`
+	// Resolver is the address of the DNS server used to resolve the targets,
+	// in the form "ip" or "ip:port". Names are resolved as fully qualified,
+	// without applying search domains. If empty, the system resolver is used.
+	Resolver string `yaml:"resolver,omitempty" json:"resolver,omitempty"`
+	// DNSCache enables an in-process cache of resolved addresses.
+	DNSCache *DNSCacheConfig `yaml:"dns_cache,omitempty" json:"dns_cache,omitempty"`
+}
+
+// DNSCacheConfig configures the in-process DNS cache.
+type DNSCacheConfig struct {
+	// MaxEntries is the maximum number of hosts kept in the cache. The least
+	// recently used entries are evicted first.
+	MaxEntries int `yaml:"max_entries,omitempty" json:"max_entries,omitempty"`
+	// DefaultTTL is the time entries are cached for when the TTL of the
+	// records is unknown, which is the case with the system resolver.
+	DefaultTTL model.Duration `yaml:"default_ttl,omitempty" json:"default_ttl,omitempty"`
+	// MaxTTL optionally caps the TTL of the records returned by the resolver.
+	MaxTTL model.Duration `yaml:"max_ttl,omitempty" json:"max_ttl,omitempty"`
+	// StaleWhileRevalidate is the time an expired entry keeps being served
+	// while it is refreshed in the background.
+	StaleWhileRevalidate model.Duration `yaml:"stale_while_revalidate,omitempty" json:"stale_while_revalidate,omitempty"`
+}
+
+// Validate validates the DialerConfig.
+func (c *DialerConfig) Validate() error {
+	if c == nil {
+		return nil
+	}
+	switch c.IPFamily {
+	case "", IPFamilyDual, IPFamilyIPv4, IPFamilyIPv6:
+	default:
+		return fmt.Errorf("invalid dialer ip_family %q, must be one of ipv4, ipv6 or dual", c.IPFamily)
+	}
+	if c.Resolver != "" {
+		if _, err := resolverAddr(c.Resolver); err != nil {
+			return err
+		}
+	}
+	if c.DNSCache != nil {
+		if c.DNSCache.MaxEntries < 0 {
+			return errors.New("dialer dns_cache max_entries must not be negative")
+		}
+		if c.DNSCache.DefaultTTL < 0 || c.DNSCache.MaxTTL < 0 || c.DNSCache.StaleWhileRevalidate < 0 {
+			return errors.New("dialer dns_cache durations must not be negative")
+		}
+	}
+	return nil
+}
+
+// resolverAddr returns the resolver address with the default DNS port added
+// if needed.
+func resolverAddr(s string) (string, error) {
+	if addr, err := netip.ParseAddr(s); err == nil {
+		return netip.AddrPortFrom(addr, 53).String(), nil
+	}
+	addrPort, err := netip.ParseAddrPort(s)
+	if err != nil {
+		return "", fmt.Errorf("invalid dialer resolver %q, must be an IP address with an optional port", s)
+	}
+	return addrPort.String(), nil
+}
+
+// NewDialContextFuncFromConfig returns a DialContextFunc that resolves hosts
+// according to the given DialerConfig and dials the resolved addresses with
+// next. If next is nil, a net.Dialer is used.
+func NewDialContextFuncFromConfig(cfg *DialerConfig, next DialContextFunc) (DialContextFunc, error) {
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+	if next == nil {
+		next = (&net.Dialer{}).DialContext
+	}
+
+	d := &resolvingDialer{
+		family:        cfg.family(),
+		lookup:        newLookupFunc(cfg),
+		next:          next,
+		fallbackDelay: fallbackDelay,
+	}
+	return d.DialContext, nil
+}
+
+// family returns the configured address family, defaulting to dual stack.
+func (c *DialerConfig) family() IPFamily {
+	if c.IPFamily == "" {
+		return IPFamilyDual
+	}
+	return c.IPFamily
+}
+
+// newLookupFunc returns the lookupFunc configured by cfg.
+func newLookupFunc(cfg *DialerConfig) lookupFunc {
+	var lookup lookupFunc
+	if cfg.Resolver != "" {
+		addr, _ := resolverAddr(cfg.Resolver)
+		lookup = newDNSClientLookup(addr, cfg.family())
+	} else {
+		lookup = newSystemLookup(cfg.family())
+	}
+	if cfg.DNSCache != nil {
+		lookup = newDNSCache(cfg.DNSCache, lookup).lookup
+	}
+	return lookup
+}
+
+// lookupFunc returns the addresses of host and the time they may be cached
+// for. A zero TTL means that the TTL is unknown.
+type lookupFunc func(ctx context.Context, host string) ([]netip.Addr, time.Duration, error)
+
+// fallbackDelay is the time to wait before dialing the addresses of the other
+// family in dual stack mode, as in net.Dialer.
+const fallbackDelay = 300 * time.Millisecond
+
+type resolvingDialer struct {
+	family IPFamily
+	lookup lookupFunc
+	next   DialContextFunc
+	// fallbackDelay is fallbackDelay, except in tests.
+	fallbackDelay time.Duration
+}
+
+// DialContext resolves the host of addr and dials the resolved addresses in
+// turn until a connection is established. Like net.Dialer, the deadline of
+// ctx is shared by the addresses, and in dual stack mode the addresses of the
+// other family are dialed in parallel after a short delay (RFC 6555).
+func (d *resolvingDialer) DialContext(ctx context.Context, network, addr string) (net.Conn, error) {
+	host, port, err := net.SplitHostPort(addr)
+	if err != nil {
+		return nil, err
+	}
+	network = d.network(network)
+
+	if ip, err := netip.ParseAddr(host); err == nil {
+		if !d.allowed(ip) {
+			return nil, fmt.Errorf("address %s is not allowed by ip_family %s", host, d.family)
+		}
+		return d.next(ctx, network, addr)
+	}
+
+	addrs, _, err := d.lookup(ctx, host)
+	if err != nil {
+		return nil, err
+	}
+	primaries, fallbacks := addrs, []netip.Addr(nil)
+	if d.family == IPFamilyDual && len(addrs) > 0 {
+		primaries, fallbacks = nil, nil
+		for _, ip := range addrs {
+			if ip.Is4() == addrs[0].Is4() {
+				primaries = append(primaries, ip)
+			} else {
+				fallbacks = append(fallbacks, ip)
+			}
+		}
+	}
+	if len(fallbacks) == 0 {
+		return d.dialSerial(ctx, network, port, primaries)
+	}
+	return d.dialParallel(ctx, network, port, primaries, fallbacks)
+}
+
+// dialSerial dials addrs in turn, giving each address a share of the
+// remaining time until the deadline of ctx.
+func (d *resolvingDialer) dialSerial(ctx context.Context, network, port string, addrs []netip.Addr) (net.Conn, error) {
+	var errs []error
+	for i, ip := range addrs {
+		dialCtx, cancel := ctx, context.CancelFunc(func() {})
+		if deadline, ok := ctx.Deadline(); ok {
+			partial, err := partialDeadline(time.Now(), deadline, len(addrs)-i)
+			if err != nil {
+				errs = append(errs, err)
+				break
+			}
+			dialCtx, cancel = context.WithDeadline(ctx, partial)
+		}
+		conn, err := d.next(dialCtx, network, net.JoinHostPort(ip.String(), port))
+		cancel()
+		if err == nil {
+			return conn, nil
+		}
+		errs = append(errs, err)
+		if ctx.Err() != nil {
+			break
+		}
+	}
+	return nil, errors.Join(errs...)
+}
+
+// dialParallel races the serial dials of primaries and fallbacks, the latter
+// starting after fallbackDelay or as soon as the primaries have failed.
+func (d *resolvingDialer) dialParallel(ctx context.Context, network, port string, primaries, fallbacks []netip.Addr) (net.Conn, error) {
+	type dialResult struct {
+		conn    net.Conn
+		err     error
+		primary bool
+		done    bool
+	}
+	returned := make(chan struct{})
+	defer close(returned)
+	results := make(chan dialResult)
+	race := func(ctx context.Context, primary bool) {
+		addrs := primaries
+		if !primary {
+			addrs = fallbacks
+		}
+		conn, err := d.dialSerial(ctx, network, port, addrs)
+		select {
+		case results <- dialResult{conn: conn, err: err, primary: primary, done: true}:
+		case <-returned:
+			if conn != nil {
+				conn.Close()
+			}
+		}
+	}
+
+	primaryCtx, primaryCancel := context.WithCancel(ctx)
+	defer primaryCancel()
+	go race(primaryCtx, true)
+	fallbackCtx, fallbackCancel := context.WithCancel(ctx)
+	defer fallbackCancel()
+	fallbackTimer := time.NewTimer(d.fallbackDelay)
+	defer fallbackTimer.Stop()
+
+	var primary, fallback dialResult
+	for {
+		select {
+		case <-fallbackTimer.C:
+			go race(fallbackCtx, false)
+		case res := <-results:
+			if res.err == nil {
+				return res.conn, nil
+			}
+			if res.primary {
+				primary = res
+			} else {
+				fallback = res
+			}
+			if primary.done && fallback.done {
+				return nil, errors.Join(primary.err, fallback.err)
+			}
+			if res.primary && fallbackTimer.Stop() {
+				// The fallbacks are dialed right away once the primaries
+				// have failed.
+				fallbackTimer.Reset(0)
+			}
+		}
+	}
+}
+
+// partialDeadline returns the deadline of a dial to one of addrsRemaining
+// addresses, as net.Dialer does: the remaining time is split evenly, with a
+// minimum of two seconds.
+func partialDeadline(now, deadline time.Time, addrsRemaining int) (time.Time, error) {
+	timeRemaining := deadline.Sub(now)
+	if timeRemaining <= 0 {
+		return time.Time{}, context.DeadlineExceeded
+	}
+	timeout := timeRemaining / time.Duration(addrsRemaining)
+	const saneMinimum = 2 * time.Second
+	if timeout < saneMinimum {
+		timeout = min(timeRemaining, saneMinimum)
+	}
+	return now.Add(timeout), nil
+}
+
+// network restricts network to the configured address family.
+func (d *resolvingDialer) network(network string) string {
+	if network != "tcp" && network != "udp" {
+		return network
+	}
+	switch d.family {
+	case IPFamilyIPv4:
+		return network + "4"
+	case IPFamilyIPv6:
+		return network + "6"
+	}
+	return network
+}
+
+func (d *resolvingDialer) allowed(ip netip.Addr) bool {
+	switch d.family {
+	case IPFamilyIPv4:
+		return ip.Unmap().Is4()
+	case IPFamilyIPv6:
+		return ip.Is6() && !ip.Is4In6()
+	}
+	return true
+}
+
+// newSystemLookup returns a lookupFunc using the system resolver.
+func newSystemLookup(family IPFamily) lookupFunc {
+	network := "ip"
+	switch family {
+	case IPFamilyIPv4:
+		network = "ip4"
+	case IPFamilyIPv6:
+		network = "ip6"
+	}
+	return func(ctx context.Context, host string) ([]netip.Addr, time.Duration, error) {
+		addrs, err := net.DefaultResolver.LookupNetIP(ctx, network, host)
+		if err != nil {
+			return nil, 0, err
+		}
+		for i := range addrs {
+			addrs[i] = addrs[i].Unmap()
+		}
+		return sortByFamily(addrs), 0, nil
+	}
+}
+
+// sortByFamily moves IPv4 addresses in front of IPv6 addresses, keeping the
+// order within each family.
+func sortByFamily(addrs []netip.Addr) []netip.Addr {
+	sorted := make([]netip.Addr, 0, len(addrs))
+	for _, a := range addrs {
+		if a.Is4() {
+			sorted = append(sorted, a)
+		}
+	}
+	for _, a := range addrs {
+		if !a.Is4() {
+			sorted = append(sorted, a)
+		}
+	}
+	return sorted
+}
+
+// newDNSClientLookup returns a lookupFunc querying the DNS server at addr
+// directly, which makes the TTL of the records available.
+func newDNSClientLookup(addr string, family IPFamily) lookupFunc {
+	var types []dnsmessage.Type
+	switch family {
+	case IPFamilyIPv4:
+		types = []dnsmessage.Type{dnsmessage.TypeA}
+	case IPFamilyIPv6:
+		types = []dnsmessage.Type{dnsmessage.TypeAAAA}
+	default:
+		types = []dnsmessage.Type{dnsmessage.TypeA, dnsmessage.TypeAAAA}
+	}
+	return func(ctx context.Context, host string) ([]netip.Addr, time.Duration, error) {
+		var (
+			addrs  []netip.Addr
+			minTTL time.Duration
+			errs   []error
+		)
+		for _, t := range types {
+			a, ttl, err := dnsQuery(ctx, addr, host, t)
+			if err != nil {
+				errs = append(errs, err)
+				continue
+			}
+			if len(a) > 0 && (minTTL == 0 || ttl < minTTL) {
+				minTTL = ttl
+			}
+			addrs = append(addrs, a...)
+		}
+		if len(addrs) == 0 {
+			if len(errs) > 0 {
+				return nil, 0, errors.Join(errs...)
+			}
+			return nil, 0, &net.DNSError{Err: "no such host", Name: host, Server: addr, IsNotFound: true}
+		}
+		return addrs, minTTL, nil
+	}
+}
+
+// dnsQuery sends a single question to the DNS server at addr over UDP,
+// retrying over TCP if the response is truncated.
+func dnsQuery(ctx context.Context, addr, host string, t dnsmessage.Type) ([]netip.Addr, time.Duration, error) {
+	if !strings.HasSuffix(host, ".") {
+		host += "."
+	}
+	name, err := dnsmessage.NewName(host)
+	if err != nil {
+		return nil, 0, &net.DNSError{Err: err.Error(), Name: host, Server: addr}
+	}
+	id := uint16(rand.Uint32())
+	query := dnsmessage.Message{
+		Header: dnsmessage.Header{ID: id, RecursionDesired: true},
+		Questions: []dnsmessage.Question{{
+			Name:  name,
+			Type:  t,
+			Class: dnsmessage.ClassINET,
+		}},
+	}
+	packed, err := query.Pack()
+	if err != nil {
+		return nil, 0, err
+	}
+
+	if _, ok := ctx.Deadline(); !ok {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, dnsQueryTimeout)
+		defer cancel()
+	}
+
+	resp, err := dnsExchange(ctx, "udp", addr, packed)
+	if err == nil && resp.Truncated {
+		resp, err = dnsExchange(ctx, "tcp", addr, packed)
+	}
+	if err != nil {
+		return nil, 0, &net.DNSError{Err: err.Error(), Name: host, Server: addr, IsTemporary: true}
+	}
+	if resp.ID != id {
+		return nil, 0, &net.DNSError{Err: "mismatched response ID", Name: host, Server: addr}
+	}
+	switch resp.RCode {
+	case dnsmessage.RCodeSuccess:
+	case dnsmessage.RCodeNameError:
+		return nil, 0, &net.DNSError{Err: "no such host", Name: host, Server: addr, IsNotFound: true}
+	default:
+		return nil, 0, &net.DNSError{Err: "server returned " + resp.RCode.String(), Name: host, Server: addr, IsTemporary: resp.RCode == dnsmessage.RCodeServerFailure}
+	}
+
+	var (
+		addrs  []netip.Addr
+		minTTL uint32
+	)
+	for _, ans := range resp.Answers {
+		var ip netip.Addr
+		switch body := ans.Body.(type) {
+		case *dnsmessage.AResource:
+			ip = netip.AddrFrom4(body.A)
+		case *dnsmessage.AAAAResource:
+			ip = netip.AddrFrom16(body.AAAA)
+		default:
+			continue
+		}
+		if len(addrs) == 0 || ans.Header.TTL < minTTL {
+			minTTL = ans.Header.TTL
+		}
+		addrs = append(addrs, ip)
+	}
+	return addrs, time.Duration(minTTL) * time.Second, nil
+}
+
+// dnsExchange sends a packed DNS message to addr and returns the response.
+func dnsExchange(ctx context.Context, network, addr string, packed []byte) (*dnsmessage.Message, error) {
+	var d net.Dialer
+	conn, err := d.DialContext(ctx, network, addr)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+	if deadline, ok := ctx.Deadline(); ok {
+		if err := conn.SetDeadline(deadline); err != nil {
+			return nil, err
+		}
+	}
+
+	var buf []byte
+	if network == "tcp" {
+		msg := binary.BigEndian.AppendUint16(nil, uint16(len(packed)))
+		if _, err := conn.Write(append(msg, packed...)); err != nil {
+			return nil, err
+		}
+		var length [2]byte
+		if _, err := io.ReadFull(conn, length[:]); err != nil {
+			return nil, err
+		}
+		buf = make([]byte, binary.BigEndian.Uint16(length[:]))
+		if _, err := io.ReadFull(conn, buf); err != nil {
+			return nil, err
+		}
+	} else {
+		if _, err := conn.Write(packed); err != nil {
+			return nil, err
+		}
+		buf = make([]byte, 65535)
+		n, err := conn.Read(buf)
+		if err != nil {
+			return nil, err
+		}
+		buf = buf[:n]
+	}
+
+	var resp dnsmessage.Message
+	if err := resp.Unpack(buf); err != nil {
+		return nil, err
+	}
+	return &resp, nil
+}
+
+// dnsCache caches the results of a lookupFunc.
+type dnsCache struct {
+	cfg  DNSCacheConfig
+	next lookupFunc
+	now  func() time.Time
+
+	mtx      sync.Mutex
+	entries  map[string]*list.Element
+	lru      *list.List
+	inflight map[string]*dnsCall
+}
+
+type dnsCacheEntry struct {
+	host       string
+	addrs      []netip.Addr
+	expires    time.Time
+	refreshing bool
+}
+
+// dnsCall is a lookup in progress, shared between concurrent callers.
+type dnsCall struct {
+	done  chan struct{}
+	addrs []netip.Addr
+	err   error
+}
+
+func newDNSCache(cfg *DNSCacheConfig, next lookupFunc) *dnsCache {
+	c := &dnsCache{
+		cfg:      *cfg,
+		next:     next,
+		now:      time.Now,
+		entries:  map[string]*list.Element{},
+		lru:      list.New(),
+		inflight: map[string]*dnsCall{},
+	}
+	orDefault(&c.cfg.MaxEntries, DefaultDNSCacheConfig.MaxEntries)
+	orDefault(&c.cfg.DefaultTTL, DefaultDNSCacheConfig.DefaultTTL)
+	return c
+}
+
+func (c *dnsCache) lookup(ctx context.Context, host string) ([]netip.Addr, time.Duration, error) {
+	host = strings.ToLower(host)
+	now := c.now()
+
+	c.mtx.Lock()
+	if el, ok := c.entries[host]; ok {
+		e := el.Value.(*dnsCacheEntry)
+		c.lru.MoveToFront(el)
+		if now.Before(e.expires) {
+			c.mtx.Unlock()
+			return e.addrs, e.expires.Sub(now), nil
+		}
+		if now.Before(e.expires.Add(time.Duration(c.cfg.StaleWhileRevalidate))) {
+			if !e.refreshing {
+				e.refreshing = true
+				go c.refresh(host)
+			}
+			c.mtx.Unlock()
+			return e.addrs, 0, nil
+		}
+	}
+	call, ok := c.inflight[host]
+	if !ok {
+		call = &dnsCall{done: make(chan struct{})}
+		c.inflight[host] = call
+		go c.resolve(context.WithoutCancel(ctx), host, call)
+	}
+	c.mtx.Unlock()
+
+	select {
+	case <-call.done:
+		return call.addrs, 0, call.err
+	case <-ctx.Done():
+		return nil, 0, ctx.Err()
+	}
+}
+
+// refresh resolves host in the background to renew a stale entry.
+func (c *dnsCache) refresh(host string) {
+	ctx, cancel := context.WithTimeout(context.Background(), dnsQueryTimeout)
+	defer cancel()
+	c.mtx.Lock()
+	call, ok := c.inflight[host]
+	if !ok {
+		call = &dnsCall{done: make(chan struct{})}
+		c.inflight[host] = call
+	}
+	c.mtx.Unlock()
+	if !ok {
+		c.resolve(ctx, host, call)
+	}
+}
+
+// resolve performs the lookup of host, stores the result in the cache and
+// releases the callers waiting for it.
+func (c *dnsCache) resolve(ctx context.Context, host string, call *dnsCall) {
+	addrs, ttl, err := c.next(ctx, host)
+
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+	delete(c.inflight, host)
+	call.addrs, call.err = addrs, err
+	defer close(call.done)
+
+	if err != nil {
+		// Keep serving the stale entry, if any, until it has fully expired.
+		if el, ok := c.entries[host]; ok {
+			el.Value.(*dnsCacheEntry).refreshing = false
+		}
+		return
+	}
+	if ttl == 0 {
+		ttl = time.Duration(c.cfg.DefaultTTL)
+	}
+	if c.cfg.MaxTTL > 0 && ttl > time.Duration(c.cfg.MaxTTL) {
+		ttl = time.Duration(c.cfg.MaxTTL)
+	}
+	e := &dnsCacheEntry{host: host, addrs: addrs, expires: c.now().Add(ttl)}
+	if el, ok := c.entries[host]; ok {
+		el.Value = e
+		c.lru.MoveToFront(el)
+		return
+	}
+	c.entries[host] = c.lru.PushFront(e)
+	for c.lru.Len() > c.cfg.MaxEntries {
+		oldest := c.lru.Back()
+		c.lru.Remove(oldest)
+		delete(c.entries, oldest.Value.(*dnsCacheEntry).host)
+	}
+}
@@ -0,0 +1,329 @@
+// Copyright 2026 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package config
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"net/url"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"golang.org/x/net/dns/dnsmessage"
+
+	"github.com/prometheus/common/model"
+)
+
+// testDNSServer is a minimal UDP DNS server answering A and AAAA queries from
+// a static set of records.
+type testDNSServer struct {
+	conn    net.PacketConn
+	records map[string][]netip.Addr
+	ttl     uint32
+	queries atomic.Int32
+}
+
+func newTestDNSServer(t *testing.T, records map[string][]netip.Addr, ttl uint32) *testDNSServer {
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	require.NoError(t, err)
+	s := &testDNSServer{conn: conn, records: records, ttl: ttl}
+	t.Cleanup(func() { conn.Close() })
+	go s.serve()
+	return s
+}
+
+func (s *testDNSServer) addr() string {
+	return s.conn.LocalAddr().String()
+}
+
+func (s *testDNSServer) serve() {
+	buf := make([]byte, 512)
+	for {
+		n, addr, err := s.conn.ReadFrom(buf)
+		if err != nil {
+			return
+		}
+		var req dnsmessage.Message
+		if err := req.Unpack(buf[:n]); err != nil || len(req.Questions) != 1 {
+			continue
+		}
+		s.queries.Add(1)
+		q := req.Questions[0]
+		resp := dnsmessage.Message{
+			Header:    dnsmessage.Header{ID: req.ID, Response: true, RecursionAvailable: true},
+			Questions: req.Questions,
+		}
+		addrs, ok := s.records[q.Name.String()]
+		if !ok {
+			resp.RCode = dnsmessage.RCodeNameError
+		}
+		for _, a := range addrs {
+			h := dnsmessage.ResourceHeader{Name: q.Name, Type: q.Type, Class: dnsmessage.ClassINET, TTL: s.ttl}
+			switch {
+			case q.Type == dnsmessage.TypeA && a.Is4():
+				resp.Answers = append(resp.Answers, dnsmessage.Resource{Header: h, Body: &dnsmessage.AResource{A: a.As4()}})
+			case q.Type == dnsmessage.TypeAAAA && a.Is6():
+				resp.Answers = append(resp.Answers, dnsmessage.Resource{Header: h, Body: &dnsmessage.AAAAResource{AAAA: a.As16()}})
+			}
+		}
+		packed, err := resp.Pack()
+		if err != nil {
+			continue
+		}
+		_, _ = s.conn.WriteTo(packed, addr)
+	}
+}
+
+func TestDialerWithResolverAndCache(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		fmt.Fprint(w, ExpectedMessage)
+	}))
+	defer ts.Close()
+	u, err := url.Parse(ts.URL)
+	require.NoError(t, err)
+
+	dns := newTestDNSServer(t, map[string][]netip.Addr{
+		"target.example.": {netip.MustParseAddr("127.0.0.1")},
+	}, 60)
+
+	cfg, err := LoadHTTPConfig(fmt.Sprintf(`
+dialer:
+  ip_family: ipv4
+  resolver: %s
+  dns_cache:
+    max_entries: 10
+`, dns.addr()))
+	require.NoError(t, err)
+
+	client, err := NewClientFromConfig(*cfg, "test", WithKeepAlivesDisabled())
+	require.NoError(t, err)
+
+	for range 3 {
+		resp, err := client.Get("http://target.example:" + u.Port())
+		require.NoError(t, err)
+		resp.Body.Close()
+		require.Equal(t, http.StatusOK, resp.StatusCode)
+	}
+	// A single A query is expected thanks to the cache.
+	require.Equal(t, int32(1), dns.queries.Load())
+
+	_, err = client.Get("http://unknown.example:" + u.Port())
+	require.ErrorContains(t, err, "no such host")
+}
+
+func TestDialerIPFamily(t *testing.T) {
+	var dialed []string
+	next := func(_ context.Context, network, addr string) (net.Conn, error) {
+		dialed = append(dialed, network+" "+addr)
+		return nil, fmt.Errorf("refused %s", addr)
+	}
+	dns := newTestDNSServer(t, map[string][]netip.Addr{
+		"dual.example.": {netip.MustParseAddr("192.0.2.1"), netip.MustParseAddr("2001:db8::1")},
+	}, 60)
+
+	for _, tc := range []struct {
+		family   IPFamily
+		expected []string
+	}{
+		{
+			family:   IPFamilyDual,
+			expected: []string{"tcp 192.0.2.1:80", "tcp [2001:db8::1]:80"},
+		},
+		{
+			family:   IPFamilyIPv4,
+			expected: []string{"tcp4 192.0.2.1:80"},
+		},
+		{
+			family:   IPFamilyIPv6,
+			expected: []string{"tcp6 [2001:db8::1]:80"},
+		},
+	} {
+		t.Run(string(tc.family), func(t *testing.T) {
+			dialed = nil
+			dial, err := NewDialContextFuncFromConfig(&DialerConfig{IPFamily: tc.family, Resolver: dns.addr()}, next)
+			require.NoError(t, err)
+			_, err = dial(context.Background(), "tcp", "dual.example:80")
+			require.Error(t, err)
+			require.Equal(t, tc.expected, dialed)
+		})
+	}
+
+	dial, err := NewDialContextFuncFromConfig(&DialerConfig{IPFamily: IPFamilyIPv6}, next)
+	require.NoError(t, err)
+	_, err = dial(context.Background(), "tcp", "127.0.0.1:80")
+	require.EqualError(t, err, "address 127.0.0.1 is not allowed by ip_family ipv6")
+}
+
+// blackholeDial simulates unroutable addresses, whose dials only end with
+// their context, while the other addresses connect right away.
+func blackholeDial(unroutable string) DialContextFunc {
+	return func(ctx context.Context, _, addr string) (net.Conn, error) {
+		if host, _, _ := net.SplitHostPort(addr); host == unroutable {
+			<-ctx.Done()
+			return nil, ctx.Err()
+		}
+		client, server := net.Pipe()
+		server.Close()
+		return client, nil
+	}
+}
+
+func TestDialerUnroutableAddress(t *testing.T) {
+	dns := newTestDNSServer(t, map[string][]netip.Addr{
+		"target.example.": {netip.MustParseAddr("192.0.2.1"), netip.MustParseAddr("127.0.0.1")},
+	}, 60)
+	dial, err := NewDialContextFuncFromConfig(&DialerConfig{IPFamily: IPFamilyIPv4, Resolver: dns.addr()}, blackholeDial("192.0.2.1"))
+	require.NoError(t, err)
+
+	// The first address only gets its share of the deadline, so that the
+	// second one is dialed in time.
+	ctx, cancel := context.WithTimeout(context.Background(), 4*time.Second)
+	defer cancel()
+	start := time.Now()
+	conn, err := dial(ctx, "tcp", "target.example:80")
+	require.NoError(t, err)
+	conn.Close()
+	require.Less(t, time.Since(start), 3*time.Second)
+}
+
+func TestDialerDualStackFallback(t *testing.T) {
+	dns := newTestDNSServer(t, map[string][]netip.Addr{
+		"dual.example.": {netip.MustParseAddr("192.0.2.1"), netip.MustParseAddr("2001:db8::1")},
+	}, 60)
+	dial, err := NewDialContextFuncFromConfig(&DialerConfig{Resolver: dns.addr()}, blackholeDial("192.0.2.1"))
+	require.NoError(t, err)
+
+	// Without a deadline, the IPv4 address would be dialed forever, so the
+	// IPv6 address is dialed in parallel after a short delay.
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+	start := time.Now()
+	conn, err := dial(ctx, "tcp", "dual.example:80")
+	require.NoError(t, err)
+	conn.Close()
+	require.Less(t, time.Since(start), 5*time.Second)
+}
+
+func TestPartialDeadline(t *testing.T) {
+	now := time.Now()
+	for _, tc := range []struct {
+		remaining time.Duration
+		addrs     int
+		expected  time.Duration
+	}{
+		{remaining: 30 * time.Second, addrs: 3, expected: 10 * time.Second},
+		// Each address gets at least two seconds.
+		{remaining: 5 * time.Second, addrs: 5, expected: 2 * time.Second},
+		{remaining: time.Second, addrs: 2, expected: time.Second},
+	} {
+		deadline, err := partialDeadline(now, now.Add(tc.remaining), tc.addrs)
+		require.NoError(t, err)
+		require.Equal(t, tc.expected, deadline.Sub(now))
+	}
+	_, err := partialDeadline(now, now, 1)
+	require.ErrorIs(t, err, context.DeadlineExceeded)
+}
+
+func TestDNSCacheStaleWhileRevalidate(t *testing.T) {
+	var (
+		calls atomic.Int32
+		addr  atomic.Value
+	)
+	addr.Store(netip.MustParseAddr("192.0.2.1"))
+	lookup := func(context.Context, string) ([]netip.Addr, time.Duration, error) {
+		calls.Add(1)
+		return []netip.Addr{addr.Load().(netip.Addr)}, time.Minute, nil
+	}
+
+	var now atomic.Int64
+	now.Store(time.Now().UnixNano())
+	c := newDNSCache(&DNSCacheConfig{StaleWhileRevalidate: model.Duration(time.Minute)}, lookup)
+	c.now = func() time.Time { return time.Unix(0, now.Load()) }
+
+	addrs, _, err := c.lookup(context.Background(), "host")
+	require.NoError(t, err)
+	require.Equal(t, []netip.Addr{netip.MustParseAddr("192.0.2.1")}, addrs)
+	require.Equal(t, int32(1), calls.Load())
+
+	// The entry is stale: it is served while being refreshed in the background.
+	addr.Store(netip.MustParseAddr("192.0.2.2"))
+	now.Add(int64(90 * time.Second))
+	addrs, _, err = c.lookup(context.Background(), "host")
+	require.NoError(t, err)
+	require.Equal(t, []netip.Addr{netip.MustParseAddr("192.0.2.1")}, addrs)
+	require.Eventually(t, func() bool {
+		addrs, _, _ := c.lookup(context.Background(), "host")
+		return addrs[0] == netip.MustParseAddr("192.0.2.2")
+	}, 5*time.Second, 10*time.Millisecond)
+	require.Equal(t, int32(2), calls.Load())
+
+	// Past the stale window, the lookup is synchronous.
+	addr.Store(netip.MustParseAddr("192.0.2.3"))
+	now.Add(int64(5 * time.Minute))
+	addrs, _, err = c.lookup(context.Background(), "host")
+	require.NoError(t, err)
+	require.Equal(t, []netip.Addr{netip.MustParseAddr("192.0.2.3")}, addrs)
+}
+
+func TestDNSCacheEviction(t *testing.T) {
+	var calls atomic.Int32
+	lookup := func(context.Context, string) ([]netip.Addr, time.Duration, error) {
+		calls.Add(1)
+		return []netip.Addr{netip.MustParseAddr("192.0.2.1")}, 0, nil
+	}
+	c := newDNSCache(&DNSCacheConfig{MaxEntries: 2}, lookup)
+
+	for _, host := range []string{"a", "b", "a", "c", "a", "b"} {
+		_, _, err := c.lookup(context.Background(), host)
+		require.NoError(t, err)
+	}
+	// "b" was evicted by "c" as "a" was more recently used.
+	require.Equal(t, int32(4), calls.Load())
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+	require.Equal(t, 2, c.lru.Len())
+}
+
+func TestDialerConfigValidate(t *testing.T) {
+	for _, tc := range []struct {
+		yaml string
+		err  string
+	}{
+		{
+			yaml: "dialer:\n  ip_family: ipv5\n",
+			err:  `invalid dialer ip_family "ipv5", must be one of ipv4, ipv6 or dual`,
+		},
+		{
+			yaml: "dialer:\n  resolver: dns.example:53\n",
+			err:  `invalid dialer resolver "dns.example:53", must be an IP address with an optional port`,
+		},
+		{
+			yaml: "dialer:\n  dns_cache:\n    max_entries: -1\n",
+			err:  "dialer dns_cache max_entries must not be negative",
+		},
+	} {
+		_, err := LoadHTTPConfig(tc.yaml)
+		require.EqualError(t, err, tc.err)
+	}
+
+	cfg, err := LoadHTTPConfig("dialer:\n  resolver: 10.0.0.2\n")
+	require.NoError(t, err)
+	require.Equal(t, "10.0.0.2", cfg.Dialer.Resolver)
+}
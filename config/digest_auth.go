@@ -0,0 +1,332 @@
+// Copyright 2026 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package config
+
+import (
+	"crypto/md5"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"hash"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+	"sync"
+)
+
+// DigestAuth contains HTTP Digest authentication credentials (RFC 7616).
+type DigestAuth struct {
+	Username     string `yaml:"username,omitempty" json:"username,omitempty"`
+	UsernameFile string `yaml:"username_file,omitempty" json:"username_file,omitempty"`
+	// UsernameRef is the name of the secret within the secret manager to use as the username.
+	UsernameRef  string `yaml:"username_ref,omitempty" json:"username_ref,omitempty"`
+	Password     Secret `yaml:"password,omitempty" json:"password,omitempty"`
+	PasswordFile string `yaml:"password_file,omitempty" json:"password_file,omitempty"`
+	// PasswordRef is the name of the secret within the secret manager to use as the password.
+	PasswordRef string `yaml:"password_ref,omitempty" json:"password_ref,omitempty"`
+}
+
+// SetDirectory joins any relative file paths with dir.
+func (a *DigestAuth) SetDirectory(dir string) {
+	if a == nil {
+		return
+	}
+	a.PasswordFile = JoinDir(dir, a.PasswordFile)
+	a.UsernameFile = JoinDir(dir, a.UsernameFile)
+}
+
+// digestChallenge is a parsed WWW-Authenticate Digest challenge.
+type digestChallenge struct {
+	realm     string
+	nonce     string
+	opaque    string
+	algorithm string
+	qop       string
+}
+
+// parseDigestChallenge returns the strongest Digest challenge supported among
+// the WWW-Authenticate headers of a response.
+func parseDigestChallenge(h http.Header) (*digestChallenge, bool) {
+	var best *digestChallenge
+	for _, v := range h.Values("Www-Authenticate") {
+		scheme, params, _ := strings.Cut(strings.TrimSpace(v), " ")
+		if !strings.EqualFold(scheme, "Digest") {
+			continue
+		}
+		p := parseAuthParams(params)
+		c := &digestChallenge{
+			realm:     p["realm"],
+			nonce:     p["nonce"],
+			opaque:    p["opaque"],
+			algorithm: p["algorithm"],
+		}
+		if c.algorithm == "" {
+			c.algorithm = "MD5"
+		}
+		if digestHash(c.algorithm) == nil || c.nonce == "" {
+			continue
+		}
+		if qops := p["qop"]; qops != "" {
+			var offered []string
+			for q := range strings.SplitSeq(qops, ",") {
+				offered = append(offered, strings.TrimSpace(q))
+			}
+			switch {
+			case slices.Contains(offered, "auth"):
+				c.qop = "auth"
+			case slices.Contains(offered, "auth-int"):
+				c.qop = "auth-int"
+			default:
+				continue
+			}
+		}
+		if best == nil || strings.HasPrefix(strings.ToUpper(c.algorithm), "SHA-256") {
+			best = c
+		}
+	}
+	return best, best != nil
+}
+
+// parseAuthParams parses a comma separated list of auth-params, whose values
+// may be quoted strings.
+func parseAuthParams(s string) map[string]string {
+	params := map[string]string{}
+	for s != "" {
+		s = strings.TrimLeft(s, " ,")
+		key, rest, ok := strings.Cut(s, "=")
+		if !ok {
+			break
+		}
+		key = strings.ToLower(strings.TrimSpace(key))
+		rest = strings.TrimLeft(rest, " ")
+		var value strings.Builder
+		if strings.HasPrefix(rest, `"`) {
+			i := 1
+			for ; i < len(rest) && rest[i] != '"'; i++ {
+				if rest[i] == '\\' && i+1 < len(rest) {
+					i++
+				}
+				value.WriteByte(rest[i])
+			}
+			s = rest[min(i+1, len(rest)):]
+		} else {
+			v, after, _ := strings.Cut(rest, ",")
+			value.WriteString(strings.TrimSpace(v))
+			s = after
+		}
+		params[key] = value.String()
+	}
+	return params
+}
+
+// digestHash returns the hash function of a Digest algorithm, or nil if the
+// algorithm is not supported.
+func digestHash(algorithm string) func() hash.Hash {
+	switch strings.TrimSuffix(strings.ToUpper(algorithm), "-SESS") {
+	case "MD5":
+		return md5.New
+	case "SHA-256":
+		return sha256.New
+	}
+	return nil
+}
+
+func digestHex(h func() hash.Hash, s string) string {
+	d := h()
+	io.WriteString(d, s)
+	return hex.EncodeToString(d.Sum(nil))
+}
+
+// authorization returns the Authorization header value answering the
+// challenge for req.
+func (c *digestChallenge) authorization(req *http.Request, body []byte, username, password string, nc uint32, cnonce string) string {
+	h := digestHash(c.algorithm)
+	uri := req.URL.RequestURI()
+
+	ha1 := digestHex(h, username+":"+c.realm+":"+password)
+	if strings.HasSuffix(strings.ToUpper(c.algorithm), "-SESS") {
+		ha1 = digestHex(h, ha1+":"+c.nonce+":"+cnonce)
+	}
+	a2 := req.Method + ":" + uri
+	if c.qop == "auth-int" {
+		d := h()
+		d.Write(body)
+		a2 += ":" + hex.EncodeToString(d.Sum(nil))
+	}
+	ha2 := digestHex(h, a2)
+
+	ncValue := fmt.Sprintf("%08x", nc)
+	var response string
+	if c.qop == "" {
+		response = digestHex(h, ha1+":"+c.nonce+":"+ha2)
+	} else {
+		response = digestHex(h, ha1+":"+c.nonce+":"+ncValue+":"+cnonce+":"+c.qop+":"+ha2)
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, `Digest username=%q, realm=%q, nonce=%q, uri=%q, algorithm=%s, response=%q`,
+		username, c.realm, c.nonce, uri, c.algorithm, response)
+	if c.opaque != "" {
+		fmt.Fprintf(&b, `, opaque=%q`, c.opaque)
+	}
+	if c.qop != "" {
+		fmt.Fprintf(&b, `, qop=%s, nc=%s, cnonce=%q`, c.qop, ncValue, cnonce)
+	}
+	return b.String()
+}
+
+type digestAuthRoundTripper struct {
+	username SecretReader
+	password SecretReader
+	rt       http.RoundTripper
+
+	mtx sync.Mutex
+	// spaces holds the latest challenge of each protection space.
+	spaces map[digestSpace]*digestState
+	// realms holds the realm of the latest challenge of each origin, which
+	// is answered preemptively.
+	realms map[string]string
+}
+
+// digestSpace identifies a protection space (RFC 7235 section 2.2): the
+// canonical root URL of the server and the realm.
+type digestSpace struct {
+	origin string
+	realm  string
+}
+
+// digestState is a challenge and the nonce count used with it.
+type digestState struct {
+	challenge *digestChallenge
+	nc        uint32
+}
+
+// NewDigestAuthRoundTripper will apply a Digest authorization header to a
+// request unless it has already been set. The challenges of the servers are
+// cached by protection space, so that subsequent requests to the same server
+// are authenticated preemptively.
+func NewDigestAuthRoundTripper(username, password SecretReader, rt http.RoundTripper) http.RoundTripper {
+	return &digestAuthRoundTripper{
+		username: username,
+		password: password,
+		rt:       rt,
+		spaces:   map[digestSpace]*digestState{},
+		realms:   map[string]string{},
+	}
+}
+
+// digestOrigin returns the canonical root URL of u: the scheme, host and
+// port, with the default port of the scheme made explicit.
+func digestOrigin(u *url.URL) string {
+	scheme, port := strings.ToLower(u.Scheme), u.Port()
+	if port == "" {
+		port = "80"
+		if scheme == "https" {
+			port = "443"
+		}
+	}
+	return scheme + "://" + net.JoinHostPort(strings.ToLower(u.Hostname()), port)
+}
+
+func (rt *digestAuthRoundTripper) RoundTrip(req *http.Request) (*http.Response, error) {
+	if len(req.Header.Get("Authorization")) != 0 || isCrossHostRedirect(req) {
+		return rt.rt.RoundTrip(req)
+	}
+
+	var username, password string
+	if rt.username != nil {
+		var err error
+		username, err = rt.username.Fetch(req.Context())
+		if err != nil {
+			return nil, fmt.Errorf("unable to read digest auth username: %w", err)
+		}
+	}
+	if rt.password != nil {
+		var err error
+		password, err = rt.password.Fetch(req.Context())
+		if err != nil {
+			return nil, fmt.Errorf("unable to read digest auth password: %w", err)
+		}
+	}
+
+	// The body may be sent twice if the server challenges the first attempt.
+	body, req, err := readBody(req)
+	if err != nil {
+		return nil, fmt.Errorf("unable to read request body for digest auth: %w", err)
+	}
+
+	origin := digestOrigin(req.URL)
+	var challenge *digestChallenge
+	rt.mtx.Lock()
+	if realm, ok := rt.realms[origin]; ok {
+		challenge = rt.spaces[digestSpace{origin: origin, realm: realm}].challenge
+	}
+	rt.mtx.Unlock()
+
+	resp, err := rt.send(req, body, origin, challenge, username, password)
+	if err != nil || resp.StatusCode != http.StatusUnauthorized {
+		return resp, err
+	}
+	newChallenge, ok := parseDigestChallenge(resp.Header)
+	if !ok {
+		return resp, nil
+	}
+	rt.mtx.Lock()
+	rt.spaces[digestSpace{origin: origin, realm: newChallenge.realm}] = &digestState{challenge: newChallenge}
+	rt.realms[origin] = newChallenge.realm
+	rt.mtx.Unlock()
+
+	io.Copy(io.Discard, resp.Body)
+	resp.Body.Close()
+	if req.GetBody != nil {
+		req = cloneRequest(req)
+		req.Body, err = req.GetBody()
+		if err != nil {
+			return nil, err
+		}
+	}
+	return rt.send(req, body, origin, newChallenge, username, password)
+}
+
+// send sends req, answering challenge if it is not nil.
+func (rt *digestAuthRoundTripper) send(req *http.Request, body []byte, origin string, challenge *digestChallenge, username, password string) (*http.Response, error) {
+	if challenge == nil {
+		return rt.rt.RoundTrip(req)
+	}
+	var nc uint32 = 1
+	rt.mtx.Lock()
+	if state := rt.spaces[digestSpace{origin: origin, realm: challenge.realm}]; state != nil && state.challenge == challenge {
+		state.nc++
+		nc = state.nc
+	}
+	rt.mtx.Unlock()
+
+	cnonce := make([]byte, 16)
+	if _, err := rand.Read(cnonce); err != nil {
+		return nil, err
+	}
+	req = cloneRequest(req)
+	req.Header.Set("Authorization", challenge.authorization(req, body, username, password, nc, hex.EncodeToString(cnonce)))
+	return rt.rt.RoundTrip(req)
+}
+
+func (rt *digestAuthRoundTripper) CloseIdleConnections() {
+	if ci, ok := rt.rt.(closeIdler); ok {
+		ci.CloseIdleConnections()
+	}
+}
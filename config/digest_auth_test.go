@@ -0,0 +1,173 @@
+// Copyright 2026 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package config
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+// newDigestServer returns a server requiring Digest authentication with the
+// given algorithm and nonce. Requests answering another nonce are challenged
+// again. It records the nonce counts of the authenticated requests.
+func newDigestServer(t *testing.T, algorithm, nonce string, ncs *[]string) *httptest.Server {
+	const realm = "test"
+	h := digestHash(algorithm)
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		scheme, params, _ := strings.Cut(r.Header.Get("Authorization"), " ")
+		if scheme != "Digest" || parseAuthParams(params)["nonce"] != nonce {
+			w.Header().Add("WWW-Authenticate", fmt.Sprintf(`Digest realm=%q, qop="auth,auth-int", nonce=%q, opaque="5ccc069c", algorithm=%s`, realm, nonce, algorithm))
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		p := parseAuthParams(params)
+		ha1 := digestHex(h, p["username"]+":"+realm+":secret")
+		ha2 := digestHex(h, r.Method+":"+p["uri"])
+		expected := digestHex(h, ha1+":"+nonce+":"+p["nc"]+":"+p["cnonce"]+":"+p["qop"]+":"+ha2)
+		if p["response"] != expected || p["opaque"] != "5ccc069c" || p["uri"] != r.URL.RequestURI() {
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+		*ncs = append(*ncs, p["nc"])
+		io.Copy(w, r.Body)
+	}))
+}
+
+func TestDigestAuth(t *testing.T) {
+	for _, algorithm := range []string{"MD5", "SHA-256"} {
+		t.Run(algorithm, func(t *testing.T) {
+			var ncs []string
+			ts := newDigestServer(t, algorithm, "dcd98b7102dd2f0e8b11d0f600bfb0c093", &ncs)
+			defer ts.Close()
+
+			cfg, err := LoadHTTPConfig(`
+digest_auth:
+  username: user
+  password: secret
+`)
+			require.NoError(t, err)
+			client, err := NewClientFromConfig(*cfg, "test")
+			require.NoError(t, err)
+
+			resp, err := client.Get(ts.URL + "/path?query=1")
+			require.NoError(t, err)
+			resp.Body.Close()
+			require.Equal(t, http.StatusOK, resp.StatusCode)
+
+			// The body is sent again after the challenge.
+			resp, err = client.Post(ts.URL, "text/plain", struct{ io.Reader }{strings.NewReader("streamed")})
+			require.NoError(t, err)
+			b, err := io.ReadAll(resp.Body)
+			require.NoError(t, err)
+			resp.Body.Close()
+			require.Equal(t, http.StatusOK, resp.StatusCode)
+			require.Equal(t, "streamed", string(b))
+
+			// The cached challenge is answered with an increasing nonce count.
+			require.Equal(t, []string{"00000001", "00000002"}, ncs)
+		})
+	}
+}
+
+func TestDigestAuthWrongPassword(t *testing.T) {
+	var ncs []string
+	ts := newDigestServer(t, "MD5", "dcd98b7102dd2f0e8b11d0f600bfb0c093", &ncs)
+	defer ts.Close()
+
+	rt := NewDigestAuthRoundTripper(NewInlineSecret("user"), NewInlineSecret("wrong"), http.DefaultTransport)
+	client := &http.Client{Transport: rt}
+	resp, err := client.Get(ts.URL)
+	require.NoError(t, err)
+	resp.Body.Close()
+	require.Equal(t, http.StatusForbidden, resp.StatusCode)
+	require.Empty(t, ncs)
+}
+
+func TestDigestAuthMultipleServers(t *testing.T) {
+	var ncsA, ncsB []string
+	tsA := newDigestServer(t, "MD5", "nonce-a", &ncsA)
+	defer tsA.Close()
+	tsB := newDigestServer(t, "MD5", "nonce-b", &ncsB)
+	defer tsB.Close()
+
+	rt := NewDigestAuthRoundTripper(NewInlineSecret("user"), NewInlineSecret("secret"), http.DefaultTransport)
+	client := &http.Client{Transport: rt}
+	for range 3 {
+		for _, ts := range []*httptest.Server{tsA, tsB} {
+			resp, err := client.Get(ts.URL)
+			require.NoError(t, err)
+			resp.Body.Close()
+			require.Equal(t, http.StatusOK, resp.StatusCode)
+		}
+	}
+
+	// Each server keeps its own challenge: only the first request to each of
+	// them is challenged and the nonce counts increase independently.
+	require.Equal(t, []string{"00000001", "00000002", "00000003"}, ncsA)
+	require.Equal(t, []string{"00000001", "00000002", "00000003"}, ncsB)
+}
+
+func TestParseDigestChallenge(t *testing.T) {
+	h := http.Header{}
+	h.Add("WWW-Authenticate", `Basic realm="test"`)
+	h.Add("WWW-Authenticate", `Digest realm="test, with comma", nonce="abc", algorithm=MD5, qop="auth"`)
+	h.Add("WWW-Authenticate", `Digest realm="test", nonce="def", algorithm=SHA-256, qop="auth-int"`)
+	h.Add("WWW-Authenticate", `Digest realm="test", nonce="ghi", algorithm=SHA-512-256`)
+
+	c, ok := parseDigestChallenge(h)
+	require.True(t, ok)
+	require.Equal(t, &digestChallenge{realm: "test", nonce: "def", algorithm: "SHA-256", qop: "auth-int"}, c)
+
+	h.Del("WWW-Authenticate")
+	h.Add("WWW-Authenticate", `Digest realm="test, with comma", nonce="abc", qop="auth"`)
+	c, ok = parseDigestChallenge(h)
+	require.True(t, ok)
+	require.Equal(t, &digestChallenge{realm: "test, with comma", nonce: "abc", algorithm: "MD5", qop: "auth"}, c)
+
+	_, ok = parseDigestChallenge(http.Header{})
+	require.False(t, ok)
+}
+
+func TestDigestAuthValidate(t *testing.T) {
+	for _, tc := range []struct {
+		config string
+		errMsg string
+	}{
+		{
+			config: "digest_auth:\n  username: user\nbasic_auth:\n  username: user\n",
+			errMsg: "at most one of basic_auth, digest_auth, oauth2, authorization, bearer_token & bearer_token_file must be configured",
+		},
+		{
+			config: "digest_auth:\n  username: user\nbearer_token: token\n",
+			errMsg: "at most one of basic_auth, digest_auth, oauth2, authorization, bearer_token & bearer_token_file must be configured",
+		},
+		{
+			config: "digest_auth:\n  username: user\n  username_file: file\n",
+			errMsg: "at most one of digest_auth username, username_file & username_ref must be configured",
+		},
+		{
+			config: "digest_auth:\n  password: secret\n  password_ref: ref\n",
+			errMsg: "at most one of digest_auth password, password_file & password_ref must be configured",
+		},
+	} {
+		_, err := LoadHTTPConfig(tc.config)
+		require.EqualError(t, err, tc.errMsg)
+	}
+}
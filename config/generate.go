@@ -20,222 +20,89 @@ package main
 
 import (
 	"bytes"
-	"crypto/rand"
-	"crypto/rsa"
 	"crypto/x509"
-	"crypto/x509/pkix"
-	"encoding/pem"
-	"fmt"
-	"io"
 	"log"
-	"math/big"
 	"net"
 	"os"
 	"time"
+
+	"github.com/prometheus/common/config/certtest"
 )
 
 const (
 	validityPeriod = 50 * 365 * 24 * time.Hour
 )
 
-func EncodeCertificate(w io.Writer, cert *x509.Certificate) error {
-	return pem.Encode(w, &pem.Block{Type: "CERTIFICATE", Bytes: cert.Raw})
-}
-
-func EncodeKey(w io.Writer, priv *rsa.PrivateKey) error {
-	b, err := x509.MarshalPKCS8PrivateKey(priv)
-	if err != nil {
-		return fmt.Errorf("failed to marshal private key: %v", err)
-	}
-
-	return pem.Encode(w, &pem.Block{Type: "PRIVATE KEY", Bytes: b})
-}
-
-var serialNumber *big.Int
-
-func init() {
-	serialNumberLimit := new(big.Int).Lsh(big.NewInt(1), 128)
-
-	var err error
-	serialNumber, err = rand.Int(rand.Reader, serialNumberLimit)
-	if err != nil {
-		panic(fmt.Errorf("failed to generate  serial number: %v", err))
-	}
-}
-
-func SerialNumber() *big.Int {
-	var serial big.Int
-
-	serial.Set(serialNumber)
-	serialNumber.Add(&serial, big.NewInt(1))
-
-	return &serial
-}
-
-func GenerateCertificateAuthority(commonName string, parentCert *x509.Certificate, parentKey *rsa.PrivateKey) (*x509.Certificate, *rsa.PrivateKey, error) {
+func options(commonName string) certtest.Options {
 	now := time.Now()
-
-	caKey, err := rsa.GenerateKey(rand.Reader, 4096)
-	if err != nil {
-		return nil, nil, fmt.Errorf("failed to generate CA private key: %v", err)
-	}
-
-	caCert := &x509.Certificate{
-		SerialNumber: SerialNumber(),
-		Subject: pkix.Name{
-			Country:            []string{"US"},
-			Organization:       []string{"Prometheus"},
-			OrganizationalUnit: []string{"Prometheus Certificate Authority"},
-			CommonName:         commonName,
-		},
-		NotBefore:             now,
-		NotAfter:              now.Add(validityPeriod),
-		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageKeyEncipherment | x509.KeyUsageCertSign,
-		IsCA:                  true,
-		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageAny},
-		BasicConstraintsValid: true,
+	return certtest.Options{
+		CommonName: commonName,
+		KeyType:    certtest.RSA,
+		RSABits:    4096,
+		NotBefore:  now,
+		NotAfter:   now.Add(validityPeriod),
 	}
-
-	if parentCert == nil && parentKey == nil {
-		parentCert = caCert
-		parentKey = caKey
-	}
-
-	b, err := x509.CreateCertificate(rand.Reader, caCert, parentCert, &caKey.PublicKey, parentKey)
-	if err != nil {
-		return nil, nil, fmt.Errorf("failed to create CA certificate: %v", err)
-	}
-
-	caCert, err = x509.ParseCertificate(b)
-	if err != nil {
-		return nil, nil, fmt.Errorf("failed to decode CA certificate: %v", err)
-	}
-
-	return caCert, caKey, nil
 }
 
-func GenerateCertificate(caCert *x509.Certificate, caKey *rsa.PrivateKey, server bool, name string, ipAddresses ...net.IP) (*x509.Certificate, *rsa.PrivateKey, error) {
-	now := time.Now()
-
-	key, err := rsa.GenerateKey(rand.Reader, 4096)
-	if err != nil {
-		return nil, nil, fmt.Errorf("failed to generate private key: %v", err)
-	}
-
-	cert := &x509.Certificate{
-		SerialNumber: SerialNumber(),
-		Subject: pkix.Name{
-			Country:      []string{"US"},
-			Organization: []string{"Prometheus"},
-			CommonName:   name,
-		},
-		NotBefore:             now,
-		NotAfter:              now.Add(validityPeriod),
-		KeyUsage:              x509.KeyUsageKeyEncipherment,
-		BasicConstraintsValid: true,
-	}
-
-	if server {
-		cert.DNSNames = []string{name}
-		cert.IPAddresses = ipAddresses
-		cert.ExtKeyUsage = []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth, x509.ExtKeyUsageServerAuth}
-	} else {
-		cert.ExtKeyUsage = []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth}
-	}
-
-	if caCert == nil && caKey == nil {
-		caCert = cert
-		caKey = key
-	}
-
-	b, err := x509.CreateCertificate(rand.Reader, cert, caCert, &key.PublicKey, caKey)
-	if err != nil {
-		return nil, nil, fmt.Errorf("failed to create certificate: %v", err)
-	}
-
-	cert, err = x509.ParseCertificate(b)
-	if err != nil {
-		return nil, nil, fmt.Errorf("failed to decode certificate: %v", err)
-	}
-
-	return cert, key, nil
-}
-
-func writeCertificateAndKey(path string, cert *x509.Certificate, key *rsa.PrivateKey) error {
-	var b bytes.Buffer
-
-	if err := EncodeCertificate(&b, cert); err != nil {
-		return err
-	}
-
-	if err := os.WriteFile(fmt.Sprintf("%s.crt", path), b.Bytes(), 0o644); err != nil {
-		return err
-	}
-
-	b.Reset()
-	if err := EncodeKey(&b, key); err != nil {
-		return err
-	}
-
-	if err := os.WriteFile(fmt.Sprintf("%s.key", path), b.Bytes(), 0o644); err != nil {
+func writeCertificateAndKey(path string, cert *certtest.Certificate) error {
+	if err := os.WriteFile(path+".crt", cert.CertPEM(), 0o644); err != nil {
 		return err
 	}
-
-	return nil
+	return os.WriteFile(path+".key", cert.KeyPEM(), 0o644)
 }
 
 func main() {
 	log.Println("Generating root CA")
-	rootCert, rootKey, err := GenerateCertificateAuthority("Prometheus Root CA", nil, nil)
+	rootCA, err := certtest.NewRootCA(options("Prometheus Root CA"))
 	if err != nil {
 		log.Fatal(err)
 	}
 
 	log.Println("Generating CA")
-	caCert, caKey, err := GenerateCertificateAuthority("Prometheus TLS CA", rootCert, rootKey)
+	ca, err := rootCA.NewIntermediateCA(options("Prometheus TLS CA"))
 	if err != nil {
 		log.Fatal(err)
 	}
 
 	log.Println("Generating server certificate")
-	cert, key, err := GenerateCertificate(caCert, caKey, true, "localhost", net.IPv4(127, 0, 0, 1), net.IPv4(127, 0, 0, 0))
+	opts := options("localhost")
+	opts.DNSNames = []string{"localhost"}
+	opts.IPAddresses = []net.IP{net.IPv4(127, 0, 0, 1), net.IPv4(127, 0, 0, 0)}
+	cert, err := ca.Issue(opts)
 	if err != nil {
 		log.Fatal(err)
 	}
 
-	if err := writeCertificateAndKey("testdata/server", cert, key); err != nil {
+	if err := writeCertificateAndKey("testdata/server", cert); err != nil {
 		log.Fatal(err)
 	}
 
 	log.Println("Generating client certificate")
-	cert, key, err = GenerateCertificate(caCert, caKey, false, "localhost")
+	opts = options("localhost")
+	opts.ExtKeyUsage = []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth}
+	cert, err = ca.Issue(opts)
 	if err != nil {
 		log.Fatal(err)
 	}
 
-	if err := writeCertificateAndKey("testdata/client", cert, key); err != nil {
+	if err := writeCertificateAndKey("testdata/client", cert); err != nil {
 		log.Fatal(err)
 	}
 
 	log.Println("Generating self-signed client certificate")
-	cert, key, err = GenerateCertificate(nil, nil, false, "localhost")
+	cert, err = certtest.NewSelfSigned(opts)
 	if err != nil {
 		log.Fatal(err)
 	}
 
-	if err := writeCertificateAndKey("testdata/self-signed-client", cert, key); err != nil {
+	if err := writeCertificateAndKey("testdata/self-signed-client", cert); err != nil {
 		log.Fatal(err)
 	}
 
 	log.Println("Generating CA bundle")
 	var b bytes.Buffer
-	if err := EncodeCertificate(&b, caCert); err != nil {
-		log.Fatal(err)
-	}
-
-	if err := EncodeCertificate(&b, rootCert); err != nil {
-		log.Fatal(err)
-	}
+	b.Write(ca.CertPEM())
+	b.Write(rootCA.CertPEM())
 
 	if err := os.WriteFile("testdata/tls-ca-chain.pem", b.Bytes(), 0o644); err != nil {
 		log.Fatal(err)
@@ -84,6 +84,9 @@ type Header struct {
 	Values  []string `yaml:"values,omitempty" json:"values,omitempty"`
 	Secrets []Secret `yaml:"secrets,omitempty" json:"secrets,omitempty"`
 	Files   []string `yaml:"files,omitempty" json:"files,omitempty"`
+	// Refs are the names of secrets within the secret manager to use as
+	// header values.
+	Refs []string `yaml:"refs,omitempty" json:"refs,omitempty"`
 }
 
 // SetDirectory makes headers file relative to the configuration file.
@@ -93,25 +96,62 @@ func (h *Header) SetDirectory(dir string) {
 	}
 }
 
+// usingRefs reports whether any header value is read from the secret manager.
+func (h *Headers) usingRefs() bool {
+	if h == nil {
+		return false
+	}
+	for _, header := range h.Headers {
+		if len(header.Refs) > 0 {
+			return true
+		}
+	}
+	return false
+}
+
 // NewHeadersRoundTripper returns a RoundTripper that sets HTTP headers on
-// requests as configured.
-func NewHeadersRoundTripper(config *Headers, next http.RoundTripper) http.RoundTripper {
-	if len(config.Headers) == 0 {
+// requests as configured. The WithSecretManager option provides the secret
+// manager used to resolve header refs.
+func NewHeadersRoundTripper(config *Headers, next http.RoundTripper, optFuncs ...HTTPClientOption) http.RoundTripper {
+	var opts httpClientOptions
+	for _, opt := range optFuncs {
+		opt.applyToHTTPClientOptions(&opts)
+	}
+	return newHeadersRoundTripper(config, nil, next, opts.secretManager)
+}
+
+// newHeadersRoundTripper returns a RoundTripper setting the configured
+// headers and, if overrides is not nil, the headers overridden in the request
+// context.
+func newHeadersRoundTripper(config *Headers, overrides *RequestOverridesConfig, next http.RoundTripper, secretManager SecretManager) http.RoundTripper {
+	if config == nil {
+		config = &Headers{}
+	}
+	if len(config.Headers) == 0 && overrides == nil {
 		return next
 	}
 	return &headersRoundTripper{
-		config: config,
-		next:   next,
+		config:        config,
+		overrides:     overrides,
+		next:          next,
+		secretManager: secretManager,
 	}
 }
 
 type headersRoundTripper struct {
-	next   http.RoundTripper
-	config *Headers
+	next          http.RoundTripper
+	config        *Headers
+	overrides     *RequestOverridesConfig
+	secretManager SecretManager
 }
 
 // RoundTrip implements http.RoundTripper.
 func (rt *headersRoundTripper) RoundTrip(req *http.Request) (*http.Response, error) {
+	if rt.overrides != nil {
+		if err := checkRequestOverrides(req.Context(), rt.overrides); err != nil {
+			return nil, err
+		}
+	}
 	req = cloneRequest(req)
 	for n, h := range rt.config.Headers {
 		for _, v := range h.Values {
@@ -127,6 +167,21 @@ func (rt *headersRoundTripper) RoundTrip(req *http.Request) (*http.Response, err
 			}
 			req.Header.Add(n, strings.TrimSpace(string(b)))
 		}
+		for _, ref := range h.Refs {
+			if rt.secretManager == nil {
+				return nil, fmt.Errorf("unable to read header %s from ref %s: cannot use secret ref without manager", n, ref)
+			}
+			v, err := rt.secretManager.Fetch(req.Context(), ref)
+			if err != nil {
+				return nil, fmt.Errorf("unable to read header %s from ref %s: %w", n, ref, err)
+			}
+			req.Header.Add(n, v)
+		}
+	}
+	if rt.overrides != nil {
+		for n, v := range requestOverridesFromContext(req.Context()).headers {
+			req.Header[n] = v
+		}
 	}
 	return rt.next.RoundTrip(req)
 }
@@ -144,3 +144,43 @@ func TestHeadersRoundTripperSameHostRedirect(t *testing.T) {
 
 	require.Equalf(t, "session=abc", cookieOnRedirect, "Cookie must be forwarded on a same-host redirect.")
 }
+
+func TestHeadersRefs(t *testing.T) {
+	received := http.Header{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		received = r.Header.Clone()
+		fmt.Fprint(w, "ok")
+	}))
+	t.Cleanup(server.Close)
+
+	cfg, _, err := LoadHTTPConfigFile("testdata/http.conf.headers.ref.yaml")
+	require.NoError(t, err)
+
+	_, err = NewClientFromConfig(*cfg, "test")
+	require.EqualError(t, err, "unable to use http_headers: cannot use secret ref without manager")
+
+	manager := secretManager{
+		data: map[string]string{
+			"api-key": "s3cr3t",
+		},
+	}
+	client, err := NewClientFromConfig(*cfg, "test", WithSecretManager(&manager))
+	require.NoError(t, err)
+
+	resp, err := client.Get(server.URL)
+	require.NoError(t, err)
+	resp.Body.Close()
+	require.Equal(t, "tenant", received.Get("X-Scope-OrgID"))
+	require.Equal(t, "s3cr3t", received.Get("X-Api-Key"))
+
+	// Rotated secrets are picked up on the next request.
+	manager.data["api-key"] = "n3w"
+	resp, err = client.Get(server.URL)
+	require.NoError(t, err)
+	resp.Body.Close()
+	require.Equal(t, "n3w", received.Get("X-Api-Key"))
+
+	delete(manager.data, "api-key")
+	_, err = client.Get(server.URL)
+	require.ErrorContains(t, err, "unable to read header X-Api-Key from ref api-key: unknown secret api-key")
+}
@@ -0,0 +1,305 @@
+// Copyright 2026 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package config
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"slices"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/prometheus/common/model"
+	"github.com/prometheus/common/route"
+)
+
+const (
+	// hedgingLatencyWindow is the number of latency samples kept to compute
+	// the hedging delay from a percentile.
+	hedgingLatencyWindow = 1000
+	// hedgingMinSamples is the number of latency samples required before the
+	// percentile is used instead of the configured delay.
+	hedgingMinSamples = 20
+)
+
+// DefaultHedgingConfig is the default hedging configuration.
+var DefaultHedgingConfig = HedgingConfig{
+	MaxInFlight: 10,
+}
+
+// HedgingConfig configures request hedging: if a GET, HEAD or QUERY request
+// has not received a response after a delay, a second identical request is
+// sent and whichever response arrives first is used.
+type HedgingConfig struct {
+	// Delay is the time to wait for a response before sending the hedged
+	// request. When LatencyPercentile is set, it is used until enough
+	// latencies have been observed.
+	Delay model.Duration `yaml:"delay,omitempty" json:"delay,omitempty"`
+	// LatencyPercentile derives the delay from the given percentile (between 0
+	// and 1 exclusive) of the latencies of the most recent requests.
+	LatencyPercentile float64 `yaml:"latency_percentile,omitempty" json:"latency_percentile,omitempty"`
+	// MaxInFlight caps the number of hedged requests in flight at any time.
+	MaxInFlight int `yaml:"max_in_flight,omitempty" json:"max_in_flight,omitempty"`
+}
+
+// Validate validates the HedgingConfig.
+func (c *HedgingConfig) Validate() error {
+	if c == nil {
+		return nil
+	}
+	if c.Delay <= 0 {
+		return errors.New("hedging delay must be configured")
+	}
+	if c.LatencyPercentile < 0 || c.LatencyPercentile >= 1 {
+		return errors.New("hedging latency_percentile must be between 0 and 1")
+	}
+	if c.MaxInFlight < 0 {
+		return errors.New("hedging max_in_flight must not be negative")
+	}
+	return nil
+}
+
+// HedgingMetrics counts the outcome of hedged requests. The counters can be
+// exposed by the caller, for instance through prometheus.NewCounterFunc.
+type HedgingMetrics struct {
+	// Requests is the number of requests eligible for hedging.
+	Requests atomic.Uint64
+	// Hedges is the number of hedged requests sent.
+	Hedges atomic.Uint64
+	// HedgeWins is the number of hedged requests which responded first.
+	HedgeWins atomic.Uint64
+	// Throttled is the number of hedged requests not sent because
+	// MaxInFlight was reached.
+	Throttled atomic.Uint64
+}
+
+// WithHedgingMetrics sets the HedgingMetrics updated by the hedging round
+// tripper.
+func WithHedgingMetrics(metrics *HedgingMetrics) HTTPClientOption {
+	return httpClientOptionFunc(func(opts *httpClientOptions) {
+		opts.hedgingMetrics = metrics
+	})
+}
+
+// hedger holds the state shared by the hedging round trippers created for a
+// client, which outlives the transports recreated on TLS changes.
+type hedger struct {
+	cfg      HedgingConfig
+	metrics  *HedgingMetrics
+	inFlight chan struct{}
+
+	mtx       sync.Mutex
+	latencies []time.Duration
+	next      int
+}
+
+func newHedger(cfg *HedgingConfig, metrics *HedgingMetrics) *hedger {
+	c := *cfg
+	orDefault(&c.MaxInFlight, DefaultHedgingConfig.MaxInFlight)
+	if metrics == nil {
+		metrics = &HedgingMetrics{}
+	}
+	return &hedger{
+		cfg:       c,
+		metrics:   metrics,
+		inFlight:  make(chan struct{}, c.MaxInFlight),
+		latencies: make([]time.Duration, 0, hedgingLatencyWindow),
+	}
+}
+
+// NewHedgingRoundTripper returns a RoundTripper that hedges GET, HEAD and
+// QUERY requests as configured. Only the WithHedgingMetrics option is used.
+func NewHedgingRoundTripper(cfg *HedgingConfig, next http.RoundTripper, optFuncs ...HTTPClientOption) http.RoundTripper {
+	var opts httpClientOptions
+	for _, opt := range optFuncs {
+		opt.applyToHTTPClientOptions(&opts)
+	}
+	return newHedger(cfg, opts.hedgingMetrics).roundTripper(next)
+}
+
+func (h *hedger) roundTripper(next http.RoundTripper) http.RoundTripper {
+	return &hedgingRoundTripper{hedger: h, next: next}
+}
+
+// delay returns the time to wait before sending a hedged request.
+func (h *hedger) delay() time.Duration {
+	if h.cfg.LatencyPercentile == 0 {
+		return time.Duration(h.cfg.Delay)
+	}
+	h.mtx.Lock()
+	if len(h.latencies) < hedgingMinSamples {
+		h.mtx.Unlock()
+		return time.Duration(h.cfg.Delay)
+	}
+	sorted := slices.Clone(h.latencies)
+	h.mtx.Unlock()
+	slices.Sort(sorted)
+	return sorted[int(h.cfg.LatencyPercentile*float64(len(sorted)))]
+}
+
+// observe records the latency of a successful request.
+func (h *hedger) observe(d time.Duration) {
+	if h.cfg.LatencyPercentile == 0 {
+		return
+	}
+	h.mtx.Lock()
+	defer h.mtx.Unlock()
+	if len(h.latencies) < hedgingLatencyWindow {
+		h.latencies = append(h.latencies, d)
+		return
+	}
+	h.latencies[h.next] = d
+	h.next = (h.next + 1) % hedgingLatencyWindow
+}
+
+type hedgingRoundTripper struct {
+	*hedger
+	next http.RoundTripper
+}
+
+type hedgeResult struct {
+	resp    *http.Response
+	err     error
+	attempt int
+	latency time.Duration
+	cancel  context.CancelFunc
+}
+
+// hedgeable reports whether req can safely be sent more than once.
+func hedgeable(req *http.Request) bool {
+	switch req.Method {
+	case http.MethodGet, http.MethodHead, route.MethodQuery:
+	default:
+		return false
+	}
+	return req.Body == nil || req.Body == http.NoBody || req.GetBody != nil
+}
+
+// RoundTrip implements http.RoundTripper.
+func (rt *hedgingRoundTripper) RoundTrip(req *http.Request) (*http.Response, error) {
+	if !hedgeable(req) {
+		return rt.next.RoundTrip(req)
+	}
+	rt.metrics.Requests.Add(1)
+
+	var (
+		results = make(chan hedgeResult, 2)
+		cancels []context.CancelFunc
+		pending = 1
+	)
+	send := func(r *http.Request) {
+		ctx, cancel := context.WithCancel(r.Context())
+		attempt := len(cancels)
+		cancels = append(cancels, cancel)
+		go func() {
+			if attempt > 0 {
+				defer func() { <-rt.inFlight }()
+			}
+			start := time.Now()
+			resp, err := rt.next.RoundTrip(r.WithContext(ctx))
+			results <- hedgeResult{resp: resp, err: err, attempt: attempt, latency: time.Since(start), cancel: cancel}
+		}()
+	}
+	send(req)
+
+	timer := time.NewTimer(rt.delay())
+	defer timer.Stop()
+	for {
+		select {
+		case <-timer.C:
+			hedgeReq, err := cloneHedgeRequest(req)
+			if err != nil {
+				continue
+			}
+			select {
+			case rt.inFlight <- struct{}{}:
+			default:
+				rt.metrics.Throttled.Add(1)
+				continue
+			}
+			rt.metrics.Hedges.Add(1)
+			pending++
+			send(hedgeReq)
+		case res := <-results:
+			pending--
+			if res.err != nil {
+				res.cancel()
+				if pending == 0 {
+					return nil, res.err
+				}
+				continue
+			}
+			if res.attempt > 0 {
+				rt.metrics.HedgeWins.Add(1)
+			}
+			rt.observe(res.latency)
+			// Abort the request which lost the race, if it is still in flight.
+			for range pending {
+				go discardHedgeResult(results)
+			}
+			for i, cancel := range cancels {
+				if i != res.attempt {
+					cancel()
+				}
+			}
+			res.resp.Body = &cancelOnCloseBody{ReadCloser: res.resp.Body, cancel: res.cancel}
+			return res.resp, nil
+		}
+	}
+}
+
+// discardHedgeResult releases the resources of a request which lost the race.
+func discardHedgeResult(results <-chan hedgeResult) {
+	res := <-results
+	res.cancel()
+	if res.resp != nil {
+		res.resp.Body.Close()
+	}
+}
+
+// cloneHedgeRequest returns a copy of req with a fresh body.
+func cloneHedgeRequest(req *http.Request) (*http.Request, error) {
+	r := req.Clone(req.Context())
+	if req.GetBody != nil {
+		body, err := req.GetBody()
+		if err != nil {
+			return nil, err
+		}
+		r.Body = body
+	}
+	return r, nil
+}
+
+// cancelOnCloseBody cancels the context of a request once its response body
+// is closed.
+type cancelOnCloseBody struct {
+	io.ReadCloser
+	cancel context.CancelFunc
+}
+
+func (b *cancelOnCloseBody) Close() error {
+	err := b.ReadCloser.Close()
+	b.cancel()
+	return err
+}
+
+// CloseIdleConnections implements closeIdler.
+func (rt *hedgingRoundTripper) CloseIdleConnections() {
+	if ci, ok := rt.next.(closeIdler); ok {
+		ci.CloseIdleConnections()
+	}
+}
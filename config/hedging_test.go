@@ -0,0 +1,190 @@
+// Copyright 2026 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package config
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/prometheus/common/model"
+	"github.com/prometheus/common/route"
+)
+
+// newHedgingTestServer returns a server which only answers the second request
+// it receives, and the request bodies it has read.
+func newHedgingTestServer(t *testing.T) (*httptest.Server, *atomic.Int32, chan string) {
+	var requests atomic.Int32
+	bodies := make(chan string, 2)
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		b, _ := io.ReadAll(r.Body)
+		bodies <- string(b)
+		if requests.Add(1) == 1 {
+			// Block the first request until the client gives up on it.
+			<-r.Context().Done()
+			return
+		}
+		fmt.Fprint(w, ExpectedMessage)
+	}))
+	t.Cleanup(ts.Close)
+	return ts, &requests, bodies
+}
+
+func TestHedgingHedgeWins(t *testing.T) {
+	ts, requests, _ := newHedgingTestServer(t)
+
+	cfg, err := LoadHTTPConfig(`
+hedging:
+  delay: 50ms
+`)
+	require.NoError(t, err)
+
+	metrics := &HedgingMetrics{}
+	client, err := NewClientFromConfig(*cfg, "test", WithHedgingMetrics(metrics))
+	require.NoError(t, err)
+
+	resp, err := client.Get(ts.URL)
+	require.NoError(t, err)
+	body, err := io.ReadAll(resp.Body)
+	require.NoError(t, err)
+	resp.Body.Close()
+
+	require.Equal(t, ExpectedMessage, string(body))
+	require.Equal(t, int32(2), requests.Load())
+	require.Equal(t, uint64(1), metrics.Requests.Load())
+	require.Equal(t, uint64(1), metrics.Hedges.Load())
+	require.Equal(t, uint64(1), metrics.HedgeWins.Load())
+}
+
+func TestHedgingReplaysBody(t *testing.T) {
+	ts, _, bodies := newHedgingTestServer(t)
+
+	rt := NewHedgingRoundTripper(&HedgingConfig{Delay: model.Duration(50 * time.Millisecond)}, http.DefaultTransport)
+	req, err := http.NewRequest(route.MethodQuery, ts.URL, strings.NewReader("up"))
+	require.NoError(t, err)
+
+	resp, err := rt.RoundTrip(req)
+	require.NoError(t, err)
+	resp.Body.Close()
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	require.Equal(t, "up", <-bodies)
+	require.Equal(t, "up", <-bodies)
+}
+
+func TestHedgingNotHedged(t *testing.T) {
+	var requests atomic.Int32
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		requests.Add(1)
+		time.Sleep(100 * time.Millisecond)
+		fmt.Fprint(w, ExpectedMessage)
+	}))
+	defer ts.Close()
+
+	metrics := &HedgingMetrics{}
+	rt := NewHedgingRoundTripper(&HedgingConfig{Delay: model.Duration(10 * time.Millisecond)}, http.DefaultTransport, WithHedgingMetrics(metrics))
+	client := http.Client{Transport: rt}
+
+	// POST requests are not idempotent and are never hedged.
+	resp, err := client.Post(ts.URL, "text/plain", strings.NewReader("body"))
+	require.NoError(t, err)
+	resp.Body.Close()
+	require.Equal(t, int32(1), requests.Load())
+	require.Zero(t, metrics.Requests.Load())
+}
+
+func TestHedgingMaxInFlight(t *testing.T) {
+	release := make(chan struct{})
+	var calls atomic.Int32
+	next := roundTripFunc(func(req *http.Request) (*http.Response, error) {
+		calls.Add(1)
+		select {
+		case <-release:
+		case <-req.Context().Done():
+			return nil, req.Context().Err()
+		}
+		return &http.Response{StatusCode: http.StatusOK, Body: http.NoBody}, nil
+	})
+
+	metrics := &HedgingMetrics{}
+	rt := NewHedgingRoundTripper(&HedgingConfig{Delay: model.Duration(10 * time.Millisecond), MaxInFlight: 1}, next, WithHedgingMetrics(metrics))
+
+	done := make(chan struct{})
+	for range 2 {
+		go func() {
+			req, _ := http.NewRequest(http.MethodGet, "http://localhost", nil)
+			resp, err := rt.RoundTrip(req)
+			if err == nil {
+				resp.Body.Close()
+			}
+			done <- struct{}{}
+		}()
+	}
+	require.Eventually(t, func() bool {
+		return metrics.Hedges.Load()+metrics.Throttled.Load() == 2
+	}, 5*time.Second, 10*time.Millisecond)
+	close(release)
+	<-done
+	<-done
+
+	require.Equal(t, uint64(1), metrics.Hedges.Load())
+	require.Equal(t, uint64(1), metrics.Throttled.Load())
+	require.Equal(t, int32(3), calls.Load())
+}
+
+func TestHedgingLatencyPercentile(t *testing.T) {
+	h := newHedger(&HedgingConfig{Delay: model.Duration(time.Second), LatencyPercentile: 0.9}, nil)
+	for i := range hedgingMinSamples - 1 {
+		h.observe(time.Duration(i) * time.Millisecond)
+	}
+	// Not enough samples yet.
+	require.Equal(t, time.Second, h.delay())
+
+	h = newHedger(&HedgingConfig{Delay: model.Duration(time.Second), LatencyPercentile: 0.9}, nil)
+	for i := range 100 {
+		h.observe(time.Duration(i) * time.Millisecond)
+	}
+	require.Equal(t, 90*time.Millisecond, h.delay())
+}
+
+func TestHedgingConfigValidate(t *testing.T) {
+	for _, tc := range []struct {
+		yaml string
+		err  string
+	}{
+		{
+			yaml: "hedging:\n  max_in_flight: 2\n",
+			err:  "hedging delay must be configured",
+		},
+		{
+			yaml: "hedging:\n  delay: 1s\n  latency_percentile: 1.5\n",
+			err:  "hedging latency_percentile must be between 0 and 1",
+		},
+	} {
+		_, err := LoadHTTPConfig(tc.yaml)
+		require.EqualError(t, err, tc.err)
+	}
+}
+
+type roundTripFunc func(*http.Request) (*http.Response, error)
+
+func (f roundTripFunc) RoundTrip(req *http.Request) (*http.Response, error) {
+	return f(req)
+}
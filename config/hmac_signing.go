@@ -0,0 +1,336 @@
+// Copyright 2026 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package config
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/prometheus/common/model"
+)
+
+// DefaultHMACSigningConfig is the default HMAC signing configuration.
+var DefaultHMACSigningConfig = HMACSigningConfig{
+	Algorithm:          "sha256",
+	Header:             "X-Signature",
+	TimestampHeader:    "X-Timestamp",
+	Encoding:           "hex",
+	Template:           "{{ .Method }}\n{{ .Path }}\n{{ .Timestamp }}\n{{ .BodyHash }}",
+	ClockSkewTolerance: model.Duration(5 * time.Minute),
+}
+
+// HMACSigningConfig configures the signature of requests with an HMAC over a
+// canonical string built from the request.
+type HMACSigningConfig struct {
+	// Algorithm is the hash function used for the HMAC and the body hash. Valid
+	// values are "sha256" and "sha512". Defaults to "sha256".
+	Algorithm string `yaml:"algorithm,omitempty" json:"algorithm,omitempty"`
+	Key       Secret `yaml:"key,omitempty" json:"key,omitempty"`
+	KeyFile   string `yaml:"key_file,omitempty" json:"key_file,omitempty"`
+	// KeyRef is the name of the secret within the secret manager to use as the
+	// HMAC key.
+	KeyRef string `yaml:"key_ref,omitempty" json:"key_ref,omitempty"`
+	// Header is the request header carrying the signature. Defaults to
+	// "X-Signature".
+	Header string `yaml:"header,omitempty" json:"header,omitempty"`
+	// Prefix is prepended to the encoded signature, e.g. "sha256=".
+	Prefix string `yaml:"prefix,omitempty" json:"prefix,omitempty"`
+	// TimestampHeader is the request header carrying the Unix timestamp, in
+	// seconds, used in the signature. Defaults to "X-Timestamp".
+	TimestampHeader string `yaml:"timestamp_header,omitempty" json:"timestamp_header,omitempty"`
+	// Encoding of the signature. Valid values are "hex" and "base64". Defaults
+	// to "hex".
+	Encoding string `yaml:"encoding,omitempty" json:"encoding,omitempty"`
+	// Template is a Go text/template rendering the canonical string to sign.
+	// The available fields are .Method, .Path, .Query, .Host, .Timestamp and
+	// .BodyHash, the hex-encoded hash of the request body. Defaults to the
+	// method, path, timestamp and body hash separated by newlines.
+	Template string `yaml:"template,omitempty" json:"template,omitempty"`
+	// ClockSkewTolerance is the maximum difference between the timestamp of a
+	// request and the local clock accepted by VerifyHMACSignature. Defaults to
+	// 5m.
+	ClockSkewTolerance model.Duration `yaml:"clock_skew_tolerance,omitempty" json:"clock_skew_tolerance,omitempty"`
+}
+
+// SetDirectory joins any relative file paths with dir.
+func (c *HMACSigningConfig) SetDirectory(dir string) {
+	if c == nil {
+		return
+	}
+	c.KeyFile = JoinDir(dir, c.KeyFile)
+}
+
+// Validate validates the HMACSigningConfig.
+func (c *HMACSigningConfig) Validate() error {
+	if c == nil {
+		return nil
+	}
+	if nonZeroCount(len(c.Key) > 0, len(c.KeyFile) > 0, len(c.KeyRef) > 0) != 1 {
+		return errors.New("exactly one of hmac_signing key, key_file & key_ref must be configured")
+	}
+	cfg := c.withDefaults()
+	if _, err := newHMACHash(cfg.Algorithm); err != nil {
+		return err
+	}
+	if cfg.Encoding != "hex" && cfg.Encoding != "base64" {
+		return fmt.Errorf("invalid hmac_signing encoding %q, must be hex or base64", cfg.Encoding)
+	}
+	if _, err := template.New("hmac").Parse(cfg.Template); err != nil {
+		return fmt.Errorf("invalid hmac_signing template: %w", err)
+	}
+	if c.ClockSkewTolerance < 0 {
+		return errors.New("hmac_signing clock_skew_tolerance must not be negative")
+	}
+	return nil
+}
+
+func (c *HMACSigningConfig) withDefaults() HMACSigningConfig {
+	cfg := *c
+	orDefault(&cfg.Algorithm, DefaultHMACSigningConfig.Algorithm)
+	orDefault(&cfg.Header, DefaultHMACSigningConfig.Header)
+	orDefault(&cfg.TimestampHeader, DefaultHMACSigningConfig.TimestampHeader)
+	orDefault(&cfg.Encoding, DefaultHMACSigningConfig.Encoding)
+	orDefault(&cfg.Template, DefaultHMACSigningConfig.Template)
+	orDefault(&cfg.ClockSkewTolerance, DefaultHMACSigningConfig.ClockSkewTolerance)
+	return cfg
+}
+
+func newHMACHash(algorithm string) (func() hash.Hash, error) {
+	switch strings.ToLower(algorithm) {
+	case "sha256":
+		return sha256.New, nil
+	case "sha512":
+		return sha512.New, nil
+	}
+	return nil, fmt.Errorf("invalid hmac_signing algorithm %q, must be sha256 or sha512", algorithm)
+}
+
+// hmacCanonicalRequest holds the fields available to the template rendering
+// the canonical string of a request.
+type hmacCanonicalRequest struct {
+	Method    string
+	Path      string
+	Query     string
+	Host      string
+	Timestamp string
+	BodyHash  string
+}
+
+// hmacSigner computes request signatures.
+type hmacSigner struct {
+	cfg      HMACSigningConfig
+	key      SecretReader
+	hash     func() hash.Hash
+	template *template.Template
+}
+
+func newHMACSigner(cfg *HMACSigningConfig, secretManager SecretManager) (*hmacSigner, error) {
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+	key, err := toSecret(secretManager, cfg.Key, cfg.KeyFile, cfg.KeyRef)
+	if err != nil {
+		return nil, fmt.Errorf("unable to use hmac_signing key: %w", err)
+	}
+	c := cfg.withDefaults()
+	h, _ := newHMACHash(c.Algorithm)
+	return &hmacSigner{
+		cfg:      c,
+		key:      key,
+		hash:     h,
+		template: template.Must(template.New("hmac").Parse(c.Template)),
+	}, nil
+}
+
+// signature returns the encoded signature of req, whose body is given
+// separately, at timestamp ts.
+func (s *hmacSigner) signature(ctx context.Context, req *http.Request, body []byte, ts string) (string, error) {
+	key, err := s.key.Fetch(ctx)
+	if err != nil {
+		return "", fmt.Errorf("unable to read hmac_signing key: %w", err)
+	}
+
+	bodyHash := s.hash()
+	bodyHash.Write(body)
+	host := req.Host
+	if host == "" {
+		host = req.URL.Host
+	}
+	// An empty path is sent as "/", which is what the server sees.
+	path := req.URL.EscapedPath()
+	if path == "" {
+		path = "/"
+	}
+	var canonical bytes.Buffer
+	err = s.template.Execute(&canonical, hmacCanonicalRequest{
+		Method:    req.Method,
+		Path:      path,
+		Query:     req.URL.RawQuery,
+		Host:      host,
+		Timestamp: ts,
+		BodyHash:  hex.EncodeToString(bodyHash.Sum(nil)),
+	})
+	if err != nil {
+		return "", fmt.Errorf("unable to render hmac_signing template: %w", err)
+	}
+
+	mac := hmac.New(s.hash, []byte(key))
+	mac.Write(canonical.Bytes())
+	if s.cfg.Encoding == "base64" {
+		return s.cfg.Prefix + base64.StdEncoding.EncodeToString(mac.Sum(nil)), nil
+	}
+	return s.cfg.Prefix + hex.EncodeToString(mac.Sum(nil)), nil
+}
+
+// readBody returns the body of req and a request which can still be sent with
+// its body. The body is replayable afterwards through GetBody.
+func readBody(req *http.Request) ([]byte, *http.Request, error) {
+	if req.Body == nil || req.Body == http.NoBody {
+		return nil, req, nil
+	}
+	if req.GetBody != nil {
+		rc, err := req.GetBody()
+		if err != nil {
+			return nil, nil, err
+		}
+		defer rc.Close()
+		b, err := io.ReadAll(rc)
+		return b, req, err
+	}
+	b, err := io.ReadAll(req.Body)
+	req.Body.Close()
+	if err != nil {
+		return nil, nil, err
+	}
+	req = cloneRequest(req)
+	req.Body = io.NopCloser(bytes.NewReader(b))
+	req.GetBody = func() (io.ReadCloser, error) {
+		return io.NopCloser(bytes.NewReader(b)), nil
+	}
+	return b, req, nil
+}
+
+// NewHMACSigningRoundTripper returns a RoundTripper that signs requests as
+// configured. The WithSecretManager option provides the secret manager used to
+// resolve key_ref.
+func NewHMACSigningRoundTripper(cfg *HMACSigningConfig, next http.RoundTripper, optFuncs ...HTTPClientOption) (http.RoundTripper, error) {
+	var opts httpClientOptions
+	for _, opt := range optFuncs {
+		opt.applyToHTTPClientOptions(&opts)
+	}
+	signer, err := newHMACSigner(cfg, opts.secretManager)
+	if err != nil {
+		return nil, err
+	}
+	return &hmacSigningRoundTripper{signer: signer, next: next, now: time.Now}, nil
+}
+
+type hmacSigningRoundTripper struct {
+	signer *hmacSigner
+	next   http.RoundTripper
+	now    func() time.Time
+}
+
+// RoundTrip implements http.RoundTripper.
+func (rt *hmacSigningRoundTripper) RoundTrip(req *http.Request) (*http.Response, error) {
+	if isCrossHostRedirect(req) {
+		return rt.next.RoundTrip(req)
+	}
+	body, req, err := readBody(req)
+	if err != nil {
+		return nil, fmt.Errorf("unable to read request body for hmac_signing: %w", err)
+	}
+	ts := strconv.FormatInt(rt.now().Unix(), 10)
+	sig, err := rt.signer.signature(req.Context(), req, body, ts)
+	if err != nil {
+		return nil, err
+	}
+	req = cloneRequest(req)
+	req.Header.Set(rt.signer.cfg.TimestampHeader, ts)
+	req.Header.Set(rt.signer.cfg.Header, sig)
+	return rt.next.RoundTrip(req)
+}
+
+// CloseIdleConnections implements closeIdler.
+func (rt *hmacSigningRoundTripper) CloseIdleConnections() {
+	if ci, ok := rt.next.(closeIdler); ok {
+		ci.CloseIdleConnections()
+	}
+}
+
+// maxHMACVerifyBodySize is the largest request body read by
+// VerifyHMACSignature.
+const maxHMACVerifyBodySize = 10 << 20
+
+// VerifyHMACSignature checks that req carries a valid signature according to
+// cfg and that its timestamp is within the clock skew tolerance. It is meant
+// for servers receiving requests signed by clients configured with the same
+// HMACSigningConfig. Request bodies larger than 10MiB are rejected. The
+// request body remains readable afterwards.
+func VerifyHMACSignature(req *http.Request, cfg *HMACSigningConfig, optFuncs ...HTTPClientOption) error {
+	var opts httpClientOptions
+	for _, opt := range optFuncs {
+		opt.applyToHTTPClientOptions(&opts)
+	}
+	signer, err := newHMACSigner(cfg, opts.secretManager)
+	if err != nil {
+		return err
+	}
+
+	ts := req.Header.Get(signer.cfg.TimestampHeader)
+	sec, err := strconv.ParseInt(ts, 10, 64)
+	if err != nil {
+		return fmt.Errorf("invalid hmac_signing timestamp %q", ts)
+	}
+	skew := time.Since(time.Unix(sec, 0)).Abs()
+	if skew > time.Duration(signer.cfg.ClockSkewTolerance) {
+		return fmt.Errorf("hmac_signing timestamp is %s away from the local clock", skew.Truncate(time.Second))
+	}
+
+	var body []byte
+	if req.Body != nil && req.Body != http.NoBody {
+		body, err = io.ReadAll(http.MaxBytesReader(nil, req.Body, maxHMACVerifyBodySize))
+		req.Body.Close()
+		if err != nil {
+			var maxErr *http.MaxBytesError
+			if errors.As(err, &maxErr) {
+				return fmt.Errorf("hmac_signing request body exceeds %d bytes", maxHMACVerifyBodySize)
+			}
+			return err
+		}
+		req.Body = io.NopCloser(bytes.NewReader(body))
+	}
+	expected, err := signer.signature(req.Context(), req, body, ts)
+	if err != nil {
+		return err
+	}
+	if !hmac.Equal([]byte(expected), []byte(req.Header.Get(signer.cfg.Header))) {
+		return errors.New("invalid hmac_signing signature")
+	}
+	return nil
+}
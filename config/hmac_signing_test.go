@@ -0,0 +1,169 @@
+// Copyright 2026 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package config
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestHMACSigning(t *testing.T) {
+	cfg, err := LoadHTTPConfig(`
+hmac_signing:
+  key: s3cr3t
+`)
+	require.NoError(t, err)
+
+	var bodies []string
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if err := VerifyHMACSignature(r, cfg.HMACSigning); err != nil {
+			http.Error(w, err.Error(), http.StatusUnauthorized)
+			return
+		}
+		b, _ := io.ReadAll(r.Body)
+		bodies = append(bodies, string(b))
+	}))
+	defer ts.Close()
+
+	client, err := NewClientFromConfig(*cfg, "test")
+	require.NoError(t, err)
+
+	resp, err := client.Get(ts.URL + "/path?query=1")
+	require.NoError(t, err)
+	resp.Body.Close()
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+
+	resp, err = client.Post(ts.URL, "text/plain", strings.NewReader("replayable"))
+	require.NoError(t, err)
+	resp.Body.Close()
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+
+	// A body without GetBody is buffered before signing.
+	resp, err = client.Post(ts.URL, "text/plain", struct{ io.Reader }{strings.NewReader("streamed")})
+	require.NoError(t, err)
+	resp.Body.Close()
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+
+	require.Equal(t, []string{"", "replayable", "streamed"}, bodies)
+
+	// A request signed with another key is rejected.
+	other := *cfg
+	other.HMACSigning = &HMACSigningConfig{Key: "other"}
+	client, err = NewClientFromConfig(other, "test")
+	require.NoError(t, err)
+	resp, err = client.Get(ts.URL)
+	require.NoError(t, err)
+	resp.Body.Close()
+	require.Equal(t, http.StatusUnauthorized, resp.StatusCode)
+}
+
+func TestHMACSigningTemplate(t *testing.T) {
+	now := time.Unix(1700000000, 0)
+	var received http.Header
+	next := roundTripFunc(func(req *http.Request) (*http.Response, error) {
+		received = req.Header
+		return &http.Response{StatusCode: http.StatusOK, Body: http.NoBody}, nil
+	})
+
+	rt, err := NewHMACSigningRoundTripper(&HMACSigningConfig{
+		KeyRef:          "hmac",
+		Header:          "X-Hub-Signature-256",
+		Prefix:          "sha256=",
+		TimestampHeader: "X-Request-Time",
+		Encoding:        "base64",
+		Template:        "{{ .Method }} {{ .Host }}{{ .Path }}?{{ .Query }} {{ .Timestamp }}",
+	}, next, WithSecretManager(&secretManager{data: map[string]string{"hmac": "key"}}))
+	require.NoError(t, err)
+	rt.(*hmacSigningRoundTripper).now = func() time.Time { return now }
+
+	req, err := http.NewRequest(http.MethodGet, "http://example.com/a%2Fb?x=y", nil)
+	require.NoError(t, err)
+	_, err = rt.RoundTrip(req)
+	require.NoError(t, err)
+
+	mac := hmac.New(sha256.New, []byte("key"))
+	mac.Write([]byte("GET example.com/a%2Fb?x=y 1700000000"))
+	require.Equal(t, "sha256="+base64.StdEncoding.EncodeToString(mac.Sum(nil)), received.Get("X-Hub-Signature-256"))
+	require.Equal(t, "1700000000", received.Get("X-Request-Time"))
+	require.Empty(t, req.Header.Get("X-Hub-Signature-256"), "the original request must not be modified")
+}
+
+func TestVerifyHMACSignatureClockSkew(t *testing.T) {
+	cfg := &HMACSigningConfig{Key: "key"}
+	rt, err := NewHMACSigningRoundTripper(cfg, roundTripFunc(func(req *http.Request) (*http.Response, error) {
+		require.EqualError(t, VerifyHMACSignature(req, cfg), "hmac_signing timestamp is 10m0s away from the local clock")
+		return &http.Response{StatusCode: http.StatusOK, Body: http.NoBody}, nil
+	}))
+	require.NoError(t, err)
+	rt.(*hmacSigningRoundTripper).now = func() time.Time { return time.Now().Add(-10 * time.Minute) }
+
+	req, err := http.NewRequest(http.MethodGet, "http://example.com", nil)
+	require.NoError(t, err)
+	_, err = rt.RoundTrip(req)
+	require.NoError(t, err)
+
+	req.Header.Set("X-Timestamp", "now")
+	require.EqualError(t, VerifyHMACSignature(req, cfg), `invalid hmac_signing timestamp "now"`)
+	req.Header.Set("X-Timestamp", strconv.FormatInt(time.Now().Unix(), 10))
+	require.EqualError(t, VerifyHMACSignature(req, cfg), "invalid hmac_signing signature")
+}
+
+func TestVerifyHMACSignatureBodyLimit(t *testing.T) {
+	cfg := &HMACSigningConfig{Key: "key"}
+	req, err := http.NewRequest(http.MethodPost, "http://example.com", strings.NewReader(strings.Repeat("a", maxHMACVerifyBodySize+1)))
+	require.NoError(t, err)
+	req.Header.Set("X-Timestamp", strconv.FormatInt(time.Now().Unix(), 10))
+	require.EqualError(t, VerifyHMACSignature(req, cfg), "hmac_signing request body exceeds 10485760 bytes")
+}
+
+func TestHMACSigningConfigValidate(t *testing.T) {
+	for _, tc := range []struct {
+		yaml string
+		err  string
+	}{
+		{
+			yaml: "hmac_signing:\n  header: X-Sig\n",
+			err:  "exactly one of hmac_signing key, key_file & key_ref must be configured",
+		},
+		{
+			yaml: "hmac_signing:\n  key: a\n  key_file: b\n",
+			err:  "exactly one of hmac_signing key, key_file & key_ref must be configured",
+		},
+		{
+			yaml: "hmac_signing:\n  key: a\n  algorithm: md5\n",
+			err:  `invalid hmac_signing algorithm "md5", must be sha256 or sha512`,
+		},
+		{
+			yaml: "hmac_signing:\n  key: a\n  encoding: base32\n",
+			err:  `invalid hmac_signing encoding "base32", must be hex or base64`,
+		},
+		{
+			yaml: "hmac_signing:\n  key: a\n  template: '{{ .Method'\n",
+			err:  `invalid hmac_signing template: template: hmac:1: unclosed action`,
+		},
+	} {
+		_, err := LoadHTTPConfig(tc.yaml)
+		require.EqualError(t, err, tc.err)
+	}
+}
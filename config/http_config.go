@@ -22,7 +22,7 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
-	"maps"
+	"log/slog"
 	"net"
 	"net/http"
 	"net/url"
@@ -43,7 +43,8 @@ import (
 )
 
 const (
-	grantTypeJWTBearer = "urn:ietf:params:oauth:grant-type:jwt-bearer"
+	grantTypeJWTBearer    = "urn:ietf:params:oauth:grant-type:jwt-bearer"
+	grantTypeRefreshToken = "refresh_token"
 )
 
 var (
@@ -251,8 +252,23 @@ type OAuth2 struct {
 	// ClientCertificateKeyRef is the name of the secret within the secret manager to use as the client
 	// secret.
 	ClientCertificateKeyRef string `yaml:"client_certificate_key_ref,omitempty" json:"client_certificate_key_ref,omitempty"`
+	// RefreshToken is the initial refresh token. Only used if GrantType is
+	// set to "refresh_token". The refresh tokens rotated by the server are
+	// only kept in memory and are lost on restart; use RefreshTokenFile to
+	// persist them.
+	RefreshToken Secret `yaml:"refresh_token,omitempty" json:"refresh_token,omitempty"`
+	// RefreshTokenFile is the file to read the initial refresh token from.
+	// The refresh tokens rotated by the server are written back to it. Only
+	// used if GrantType is set to "refresh_token".
+	RefreshTokenFile string `yaml:"refresh_token_file,omitempty" json:"refresh_token_file,omitempty"`
+	// RefreshTokenRef is the name of the secret within the secret manager to
+	// use as the initial refresh token. Only used if GrantType is set to
+	// "refresh_token". As with RefreshToken, rotated refresh tokens are not
+	// written back and are lost on restart.
+	RefreshTokenRef string `yaml:"refresh_token_ref,omitempty" json:"refresh_token_ref,omitempty"`
 	// GrantType is the OAuth2 grant type to use. It can be one of
-	// "client_credentials" or "urn:ietf:params:oauth:grant-type:jwt-bearer" (RFC 7523).
+	// "client_credentials", "refresh_token" or
+	// "urn:ietf:params:oauth:grant-type:jwt-bearer" (RFC 7523).
 	// Default value is "client_credentials"
 	GrantType string `yaml:"grant_type,omitempty" json:"grant_type,omitempty"`
 	// SignatureAlgorithm is the RSA algorithm used to sign JWT token. Only used if
@@ -274,8 +290,14 @@ type OAuth2 struct {
 	Scopes         []string          `yaml:"scopes,omitempty" json:"scopes,omitempty"`
 	TokenURL       string            `yaml:"token_url,omitempty" json:"token_url,omitempty"`
 	EndpointParams map[string]string `yaml:"endpoint_params,omitempty" json:"endpoint_params,omitempty"`
-	TLSConfig      TLSConfig         `yaml:"tls_config,omitempty"`
-	ProxyConfig    `yaml:",inline"`
+	// TokenCacheFile is the path of a file in which the tokens are cached, so
+	// that they are reused across restarts. The file can be shared by several
+	// clients and processes. Tokens without an expiry are not cached.
+	TokenCacheFile string `yaml:"token_cache_file,omitempty" json:"token_cache_file,omitempty"`
+	// DPoP enables sender-constrained tokens (RFC 9449).
+	DPoP        *DPoPConfig `yaml:"dpop,omitempty" json:"dpop,omitempty"`
+	TLSConfig   TLSConfig   `yaml:"tls_config,omitempty"`
+	ProxyConfig `yaml:",inline"`
 }
 
 // UnmarshalYAML implements the yaml.Unmarshaler interface.
@@ -302,6 +324,9 @@ func (o *OAuth2) SetDirectory(dir string) {
 		return
 	}
 	o.ClientSecretFile = JoinDir(dir, o.ClientSecretFile)
+	o.RefreshTokenFile = JoinDir(dir, o.RefreshTokenFile)
+	o.TokenCacheFile = JoinDir(dir, o.TokenCacheFile)
+	o.DPoP.SetDirectory(dir)
 	o.TLSConfig.SetDirectory(dir)
 }
 
@@ -333,6 +358,8 @@ func LoadHTTPConfigFile(filename string) (*HTTPClientConfig, []byte, error) {
 type HTTPClientConfig struct {
 	// The HTTP basic authentication credentials for the targets.
 	BasicAuth *BasicAuth `yaml:"basic_auth,omitempty" json:"basic_auth,omitempty"`
+	// The HTTP digest authentication credentials for the targets.
+	DigestAuth *DigestAuth `yaml:"digest_auth,omitempty" json:"digest_auth,omitempty"`
 	// The HTTP authorization credentials for the targets.
 	Authorization *Authorization `yaml:"authorization,omitempty" json:"authorization,omitempty"`
 	// The OAuth2 client credentials used to fetch a token for the targets.
@@ -358,6 +385,29 @@ type HTTPClientConfig struct {
 	// HTTPHeaders specify headers to inject in the requests. Those headers
 	// could be marshalled back to the users.
 	HTTPHeaders *Headers `yaml:"http_headers,omitempty" json:"http_headers,omitempty"`
+	// Transport configures the connection pool and timeouts of the underlying
+	// transport.
+	Transport *TransportConfig `yaml:"transport,omitempty" json:"transport,omitempty"`
+	// Dialer configures how the targets are resolved and dialed.
+	Dialer *DialerConfig `yaml:"dialer,omitempty" json:"dialer,omitempty"`
+	// Hedging configures the hedging of GET, HEAD and QUERY requests.
+	Hedging *HedgingConfig `yaml:"hedging,omitempty" json:"hedging,omitempty"`
+	// MaxResponseBodyBytes limits the size of the response bodies. Reading a
+	// larger body fails with a *ResponseBodyTooLargeError. Gzip-encoded
+	// responses are decompressed and limited both before and after
+	// decompression. Zero means no limit.
+	MaxResponseBodyBytes int64 `yaml:"max_response_body_bytes,omitempty" json:"max_response_body_bytes,omitempty"`
+	// MaxResponseHeaderBytes limits the size of the response headers. Zero
+	// means the default limit of net/http.
+	MaxResponseHeaderBytes int64 `yaml:"max_response_header_bytes,omitempty" json:"max_response_header_bytes,omitempty"`
+	// HMACSigning configures the signature of the requests with an HMAC.
+	HMACSigning *HMACSigningConfig `yaml:"hmac_signing,omitempty" json:"hmac_signing,omitempty"`
+	// RequestOverrides lists the headers and credentials which can be
+	// overridden per request through the request context.
+	RequestOverrides *RequestOverridesConfig `yaml:"request_overrides,omitempty" json:"request_overrides,omitempty"`
+	// RedirectPolicy controls the redirects followed when FollowRedirects is
+	// enabled.
+	RedirectPolicy *RedirectPolicy `yaml:"redirect_policy,omitempty" json:"redirect_policy,omitempty"`
 }
 
 // SetDirectory joins any relative file paths with dir.
@@ -367,9 +417,11 @@ func (c *HTTPClientConfig) SetDirectory(dir string) {
 	}
 	c.TLSConfig.SetDirectory(dir)
 	c.BasicAuth.SetDirectory(dir)
+	c.DigestAuth.SetDirectory(dir)
 	c.Authorization.SetDirectory(dir)
 	c.OAuth2.SetDirectory(dir)
 	c.HTTPHeaders.SetDirectory(dir)
+	c.HMACSigning.SetDirectory(dir)
 	c.BearerTokenFile = JoinDir(dir, c.BearerTokenFile)
 }
 
@@ -402,6 +454,17 @@ func (c *HTTPClientConfig) Validate() error {
 	if c.BasicAuth != nil && nonZeroCount(string(c.BasicAuth.Password) != "", c.BasicAuth.PasswordFile != "", c.BasicAuth.PasswordRef != "") > 1 {
 		return errors.New("at most one of basic_auth password, password_file & password_ref must be configured")
 	}
+	if c.DigestAuth != nil {
+		if c.BasicAuth != nil || c.Authorization != nil || c.OAuth2 != nil || len(c.BearerToken) > 0 || len(c.BearerTokenFile) > 0 {
+			return errors.New("at most one of basic_auth, digest_auth, oauth2, authorization, bearer_token & bearer_token_file must be configured")
+		}
+		if nonZeroCount(c.DigestAuth.Username != "", c.DigestAuth.UsernameFile != "", c.DigestAuth.UsernameRef != "") > 1 {
+			return errors.New("at most one of digest_auth username, username_file & username_ref must be configured")
+		}
+		if nonZeroCount(string(c.DigestAuth.Password) != "", c.DigestAuth.PasswordFile != "", c.DigestAuth.PasswordRef != "") > 1 {
+			return errors.New("at most one of digest_auth password, password_file & password_ref must be configured")
+		}
+	}
 	if c.Authorization != nil {
 		if len(c.BearerToken) > 0 || len(c.BearerTokenFile) > 0 {
 			return errors.New("authorization is not compatible with bearer_token & bearer_token_file")
@@ -451,6 +514,19 @@ func (c *HTTPClientConfig) Validate() error {
 		} else if nonZeroCount(len(c.OAuth2.ClientSecret) > 0, len(c.OAuth2.ClientSecretFile) > 0, len(c.OAuth2.ClientSecretRef) > 0) > 1 {
 			return errors.New("at most one of oauth2 client_secret, client_secret_file & client_secret_ref must be configured using grant-type=client_credentials")
 		}
+		if err := c.OAuth2.DPoP.Validate(); err != nil {
+			return err
+		}
+		if c.OAuth2.DPoP != nil && !c.OAuth2.DPoP.hasKey() && c.OAuth2.TokenCacheFile != "" {
+			return errors.New("oauth2 token_cache_file requires a dpop private key, as generated keys do not survive restarts")
+		}
+		if c.OAuth2.GrantType == grantTypeRefreshToken {
+			if nonZeroCount(len(c.OAuth2.RefreshToken) > 0, len(c.OAuth2.RefreshTokenFile) > 0, len(c.OAuth2.RefreshTokenRef) > 0) != 1 {
+				return errors.New("exactly one of oauth2 refresh_token, refresh_token_file & refresh_token_ref must be configured using grant-type=refresh_token")
+			}
+		} else if nonZeroCount(len(c.OAuth2.RefreshToken) > 0, len(c.OAuth2.RefreshTokenFile) > 0, len(c.OAuth2.RefreshTokenRef) > 0) > 0 {
+			return errors.New("oauth2 refresh_token, refresh_token_file & refresh_token_ref can only be configured using grant-type=refresh_token")
+		}
 	}
 	if err := c.ProxyConfig.Validate(); err != nil {
 		return err
@@ -460,6 +536,30 @@ func (c *HTTPClientConfig) Validate() error {
 			return err
 		}
 	}
+	if err := c.Transport.Validate(); err != nil {
+		return err
+	}
+	if err := c.Dialer.Validate(); err != nil {
+		return err
+	}
+	if err := c.Hedging.Validate(); err != nil {
+		return err
+	}
+	if c.MaxResponseBodyBytes < 0 || c.MaxResponseHeaderBytes < 0 {
+		return errors.New("max_response_body_bytes and max_response_header_bytes must not be negative")
+	}
+	if err := c.HMACSigning.Validate(); err != nil {
+		return err
+	}
+	if err := c.RequestOverrides.Validate(); err != nil {
+		return err
+	}
+	if err := c.RedirectPolicy.Validate(); err != nil {
+		return err
+	}
+	if c.RequestOverrides != nil && c.RequestOverrides.Credentials && (c.BasicAuth != nil || c.DigestAuth != nil || c.OAuth2 != nil) {
+		return errors.New("request_overrides credentials is only compatible with authorization")
+	}
 	return nil
 }
 
@@ -505,6 +605,9 @@ type httpClientOptions struct {
 	userAgent         string
 	host              string
 	secretManager     SecretManager
+	hedgingMetrics    *HedgingMetrics
+	auditLogger       *slog.Logger
+	auditLogPolicy    AuditLogPolicy
 }
 
 // HTTPClientOption defines an option that can be applied to the HTTP client.
@@ -609,10 +712,17 @@ func NewClientFromConfig(cfg HTTPClientConfig, name string, optFuncs ...HTTPClie
 		return nil, err
 	}
 	client := newClient(rt)
-	if !cfg.FollowRedirects {
+	switch {
+	case !cfg.FollowRedirects:
 		client.CheckRedirect = func(*http.Request, []*http.Request) error {
 			return http.ErrUseLastResponse
 		}
+	case cfg.RedirectPolicy != nil:
+		// The redirect policy, including its maximum number of hops, is
+		// enforced by the round tripper.
+		client.CheckRedirect = func(*http.Request, []*http.Request) error {
+			return nil
+		}
 	}
 	return client, nil
 }
@@ -624,6 +734,35 @@ func NewRoundTripperFromConfig(cfg HTTPClientConfig, name string, optFuncs ...HT
 	return NewRoundTripperFromConfigWithContext(context.Background(), cfg, name, optFuncs...)
 }
 
+// newDialContextFunc returns the function dialing the connections of the
+// client configured by cfg and opts, or nil to use the default dialer.
+func newDialContextFunc(cfg *HTTPClientConfig, opts httpClientOptions) (DialContextFunc, error) {
+	transportCfg := cfg.Transport.withDefaults()
+	dialContextFunc := opts.dialContextFunc
+	if cfg.Dialer != nil {
+		// The dial timeout bounds the dials to all the resolved addresses,
+		// which share it.
+		if dialContextFunc == nil && transportCfg.KeepAlive > 0 {
+			dialContextFunc = (&net.Dialer{KeepAlive: time.Duration(transportCfg.KeepAlive)}).DialContext
+		}
+		dialContextFunc, err := NewDialContextFuncFromConfig(cfg.Dialer, dialContextFunc)
+		if err != nil {
+			return nil, err
+		}
+		if transportCfg.DialTimeout > 0 {
+			dialContextFunc = dialContextWithTimeout(dialContextFunc, time.Duration(transportCfg.DialTimeout))
+		}
+		return dialContextFunc, nil
+	}
+	switch {
+	case dialContextFunc != nil && transportCfg.DialTimeout > 0:
+		dialContextFunc = dialContextWithTimeout(dialContextFunc, time.Duration(transportCfg.DialTimeout))
+	case dialContextFunc == nil && transportCfg.dialer() != nil:
+		dialContextFunc = transportCfg.dialer().DialContext
+	}
+	return dialContextFunc, nil
+}
+
 // NewRoundTripperFromConfigWithContext returns a new HTTP RoundTripper configured for the
 // given config.HTTPClientConfig and config.HTTPClientOption.
 // The name is used as go-conntrack metric label.
@@ -633,11 +772,18 @@ func NewRoundTripperFromConfigWithContext(ctx context.Context, cfg HTTPClientCon
 		opt.applyToHTTPClientOptions(&opts)
 	}
 
+	transportCfg := cfg.Transport.withDefaults()
+
+	dialContextFunc, err := newDialContextFunc(&cfg, opts)
+	if err != nil {
+		return nil, err
+	}
+
 	var dialContext func(ctx context.Context, network, addr string) (net.Conn, error)
 
-	if opts.dialContextFunc != nil {
+	if dialContextFunc != nil {
 		dialContext = conntrack.NewDialContextFunc(
-			conntrack.DialWithDialContextFunc((func(context.Context, string, string) (net.Conn, error))(opts.dialContextFunc)),
+			conntrack.DialWithDialContextFunc((func(context.Context, string, string) (net.Conn, error))(dialContextFunc)),
 			conntrack.DialWithTracing(),
 			conntrack.DialWithName(name))
 	} else {
@@ -646,28 +792,51 @@ func NewRoundTripperFromConfigWithContext(ctx context.Context, cfg HTTPClientCon
 			conntrack.DialWithName(name))
 	}
 
+	if cfg.HTTPHeaders.usingRefs() && opts.secretManager == nil {
+		return nil, errors.New("unable to use http_headers: cannot use secret ref without manager")
+	}
+
+	var hedging *hedger
+	if cfg.Hedging != nil {
+		hedging = newHedger(cfg.Hedging, opts.hedgingMetrics)
+	}
+
 	newRT := func(tlsConfig *tls.Config) (http.RoundTripper, error) {
 		// The only timeout we care about is the configured scrape timeout.
 		// It is applied on request. So we leave out any timings here.
 		var rt http.RoundTripper = &http.Transport{
-			Proxy:                 cfg.Proxy(),
-			ProxyConnectHeader:    cfg.GetProxyConnectHeader(),
-			MaxIdleConns:          20000,
-			MaxIdleConnsPerHost:   1000, // see https://github.com/golang/go/issues/13801
-			DisableKeepAlives:     !opts.keepAlivesEnabled,
-			TLSClientConfig:       tlsConfig,
-			DisableCompression:    true,
-			IdleConnTimeout:       opts.idleConnTimeout,
-			TLSHandshakeTimeout:   10 * time.Second,
-			ExpectContinueTimeout: 1 * time.Second,
-			DialContext:           dialContext,
+			Proxy:                  cfg.Proxy(),
+			ProxyConnectHeader:     cfg.GetProxyConnectHeader(),
+			MaxIdleConns:           transportCfg.MaxIdleConns,
+			MaxIdleConnsPerHost:    transportCfg.MaxIdleConnsPerHost,
+			MaxConnsPerHost:        transportCfg.MaxConnsPerHost,
+			DisableKeepAlives:      !opts.keepAlivesEnabled,
+			TLSClientConfig:        tlsConfig,
+			DisableCompression:     true,
+			IdleConnTimeout:        opts.idleConnTimeout,
+			TLSHandshakeTimeout:    time.Duration(transportCfg.TLSHandshakeTimeout),
+			ExpectContinueTimeout:  time.Duration(*transportCfg.ExpectContinueTimeout),
+			ResponseHeaderTimeout:  time.Duration(transportCfg.ResponseHeaderTimeout),
+			MaxResponseHeaderBytes: cfg.MaxResponseHeaderBytes,
+			DialContext:            dialContext,
 		}
 		if opts.http2Enabled && cfg.EnableHTTP2 {
 			http2t, err := http2.ConfigureTransports(rt.(*http.Transport))
 			if err != nil {
 				return nil, err
 			}
-			http2t.ReadIdleTimeout = time.Minute
+			http2t.ReadIdleTimeout = time.Duration(*transportCfg.HTTP2ReadIdleTimeout)
+			http2t.PingTimeout = time.Duration(transportCfg.HTTP2PingTimeout)
+		}
+
+		if cfg.MaxResponseBodyBytes > 0 {
+			rt = NewResponseBodyLimitRoundTripper(cfg.MaxResponseBodyBytes, rt)
+		}
+
+		// Hedge requests below the authentication round trippers, so that
+		// credentials are only fetched once per request.
+		if hedging != nil {
+			rt = hedging.roundTripper(rt)
 		}
 
 		// If a authorization_credentials is provided, create a round tripper that will set the
@@ -677,7 +846,9 @@ func NewRoundTripperFromConfigWithContext(ctx context.Context, cfg HTTPClientCon
 			if err != nil {
 				return nil, fmt.Errorf("unable to use credentials: %w", err)
 			}
-			rt = NewAuthorizationCredentialsRoundTripper(cfg.Authorization.Type, credentialsSecret, rt)
+			rt = newAuthorizationCredentialsRoundTripper(cfg.Authorization.Type, credentialsSecret, cfg.RequestOverrides, rt)
+		} else if cfg.RequestOverrides != nil && cfg.RequestOverrides.Credentials {
+			rt = newAuthorizationCredentialsRoundTripper("Bearer", nil, cfg.RequestOverrides, rt)
 		}
 		// Backwards compatibility, be nice with importers who would not have
 		// called Validate().
@@ -701,27 +872,35 @@ func NewRoundTripperFromConfigWithContext(ctx context.Context, cfg HTTPClientCon
 			rt = NewBasicAuthRoundTripper(usernameSecret, passwordSecret, rt)
 		}
 
+		if cfg.DigestAuth != nil {
+			usernameSecret, err := toSecret(opts.secretManager, Secret(cfg.DigestAuth.Username), cfg.DigestAuth.UsernameFile, cfg.DigestAuth.UsernameRef)
+			if err != nil {
+				return nil, fmt.Errorf("unable to use username: %w", err)
+			}
+			passwordSecret, err := toSecret(opts.secretManager, cfg.DigestAuth.Password, cfg.DigestAuth.PasswordFile, cfg.DigestAuth.PasswordRef)
+			if err != nil {
+				return nil, fmt.Errorf("unable to use password: %w", err)
+			}
+			rt = NewDigestAuthRoundTripper(usernameSecret, passwordSecret, rt)
+		}
+
 		if cfg.OAuth2 != nil {
-			var (
-				oauthCredential SecretReader
-				err             error
-			)
-
-			if cfg.OAuth2.GrantType == grantTypeJWTBearer {
-				oauthCredential, err = toSecret(opts.secretManager, cfg.OAuth2.ClientCertificateKey, cfg.OAuth2.ClientCertificateKeyFile, cfg.OAuth2.ClientCertificateKeyRef)
-				if err != nil {
-					return nil, fmt.Errorf("unable to use client certificate: %w", err)
-				}
-			} else {
-				oauthCredential, err = toSecret(opts.secretManager, cfg.OAuth2.ClientSecret, cfg.OAuth2.ClientSecretFile, cfg.OAuth2.ClientSecretRef)
-				if err != nil {
-					return nil, fmt.Errorf("unable to use client secret: %w", err)
-				}
+			oauthCredential, err := cfg.OAuth2.credential(opts.secretManager)
+			if err != nil {
+				return nil, err
 			}
 			rt = NewOAuth2RoundTripper(oauthCredential, cfg.OAuth2, rt, optFuncs...)
 		}
 
-		if cfg.HTTPHeaders != nil {
+		if cfg.HMACSigning != nil {
+			var err error
+			rt, err = NewHMACSigningRoundTripper(cfg.HMACSigning, rt, WithSecretManager(opts.secretManager))
+			if err != nil {
+				return nil, err
+			}
+		}
+
+		if cfg.HTTPHeaders != nil || cfg.RequestOverrides != nil {
 			// Strip sensitive headers added by headersRoundTripper on cross-host
 			// redirects before they reach the transport. Only needed when
 			// redirects are actually followed; when FollowRedirects is false
@@ -730,7 +909,7 @@ func NewRoundTripperFromConfigWithContext(ctx context.Context, cfg HTTPClientCon
 			if cfg.FollowRedirects {
 				rt = &sensitiveHeadersStripRT{next: rt}
 			}
-			rt = NewHeadersRoundTripper(cfg.HTTPHeaders, rt)
+			rt = newHeadersRoundTripper(cfg.HTTPHeaders, cfg.RequestOverrides, rt, opts.secretManager)
 		}
 
 		if opts.userAgent != "" {
@@ -741,6 +920,10 @@ func NewRoundTripperFromConfigWithContext(ctx context.Context, cfg HTTPClientCon
 			rt = NewHostRoundTripper(opts.host, rt)
 		}
 
+		if cfg.FollowRedirects && cfg.RedirectPolicy != nil {
+			rt = &redirectPolicyRoundTripper{policy: cfg.RedirectPolicy, next: rt}
+		}
+
 		// Return a new configured RoundTripper.
 		return rt, nil
 	}
@@ -755,11 +938,21 @@ func NewRoundTripperFromConfigWithContext(ctx context.Context, cfg HTTPClientCon
 		return nil, err
 	}
 
+	var rt http.RoundTripper
 	if tlsSettings.immutable() {
 		// No need for a RoundTripper that reloads the files automatically.
-		return newRT(tlsConfig)
+		rt, err = newRT(tlsConfig)
+	} else {
+		rt, err = NewTLSRoundTripperWithContext(ctx, tlsConfig, tlsSettings, newRT)
+	}
+	if err != nil {
+		return nil, err
 	}
-	return NewTLSRoundTripperWithContext(ctx, tlsConfig, tlsSettings, newRT)
+
+	if opts.auditLogger != nil {
+		rt = newAuditLogRoundTripper(opts.auditLogger, opts.auditLogPolicy, &cfg, rt)
+	}
+	return rt, nil
 }
 
 // SecretManager manages secret data mapped to names known as "references" or "refs".
@@ -818,6 +1011,40 @@ func (*FileSecret) Immutable() bool {
 	return false
 }
 
+// caDirSecret reads the PEM encoded certificates of the *.pem and *.crt files
+// in a directory.
+type caDirSecret struct {
+	dir string
+}
+
+func (s *caDirSecret) Fetch(context.Context) (string, error) {
+	entries, err := os.ReadDir(s.dir)
+	if err != nil {
+		return "", fmt.Errorf("unable to read directory %s: %w", s.dir, err)
+	}
+	var sb strings.Builder
+	for _, e := range entries {
+		if e.IsDir() || (filepath.Ext(e.Name()) != ".pem" && filepath.Ext(e.Name()) != ".crt") {
+			continue
+		}
+		b, err := os.ReadFile(filepath.Join(s.dir, e.Name()))
+		if err != nil {
+			return "", fmt.Errorf("unable to read file %s: %w", filepath.Join(s.dir, e.Name()), err)
+		}
+		sb.Write(b)
+		sb.WriteByte('\n')
+	}
+	return sb.String(), nil
+}
+
+func (s *caDirSecret) Description() string {
+	return "directory " + s.dir
+}
+
+func (*caDirSecret) Immutable() bool {
+	return false
+}
+
 // refSecret fetches a single secret from a SecretManager.
 type refSecret struct {
 	ref     string
@@ -860,6 +1087,7 @@ func toSecret(secretManager SecretManager, text Secret, file, ref string) (Secre
 type authorizationCredentialsRoundTripper struct {
 	authType        string
 	authCredentials SecretReader
+	overrides       *RequestOverridesConfig
 	rt              http.RoundTripper
 }
 
@@ -867,7 +1095,15 @@ type authorizationCredentialsRoundTripper struct {
 // read from the provided SecretReader to a request unless the authorization header
 // has already been set.
 func NewAuthorizationCredentialsRoundTripper(authType string, authCredentials SecretReader, rt http.RoundTripper) http.RoundTripper {
-	return &authorizationCredentialsRoundTripper{authType, authCredentials, rt}
+	return newAuthorizationCredentialsRoundTripper(authType, authCredentials, nil, rt)
+}
+
+// newAuthorizationCredentialsRoundTripper is like
+// NewAuthorizationCredentialsRoundTripper, but honours the credentials set in
+// the request context if overrides allows it. If authCredentials is nil, only
+// the requests with overridden credentials are authorized.
+func newAuthorizationCredentialsRoundTripper(authType string, authCredentials SecretReader, overrides *RequestOverridesConfig, rt http.RoundTripper) http.RoundTripper {
+	return &authorizationCredentialsRoundTripper{authType, authCredentials, overrides, rt}
 }
 
 func (rt *authorizationCredentialsRoundTripper) RoundTrip(req *http.Request) (*http.Response, error) {
@@ -875,6 +1111,21 @@ func (rt *authorizationCredentialsRoundTripper) RoundTrip(req *http.Request) (*h
 		return rt.rt.RoundTrip(req)
 	}
 
+	if rt.overrides != nil && rt.overrides.Credentials {
+		if o := requestOverridesFromContext(req.Context()).credentials; o != nil {
+			authType := o.authType
+			if authType == "" {
+				authType = rt.authType
+			}
+			req = cloneRequest(req)
+			req.Header.Set("Authorization", fmt.Sprintf("%s %s", authType, o.credentials))
+			return rt.rt.RoundTrip(req)
+		}
+		if rt.authCredentials == nil {
+			return rt.rt.RoundTrip(req)
+		}
+	}
+
 	var authCredentials string
 	if rt.authCredentials != nil {
 		var err error
@@ -949,6 +1200,32 @@ type oauth2RoundTripper struct {
 	oauthCredential SecretReader
 	opts            *httpClientOptions
 	client          *http.Client
+	dpop            *dpopProver
+}
+
+// credential returns the SecretReader of the credential used by the grant
+// type: the client certificate key, the refresh token or the client secret.
+func (o *OAuth2) credential(secretManager SecretManager) (SecretReader, error) {
+	switch o.GrantType {
+	case grantTypeJWTBearer:
+		s, err := toSecret(secretManager, o.ClientCertificateKey, o.ClientCertificateKeyFile, o.ClientCertificateKeyRef)
+		if err != nil {
+			return nil, fmt.Errorf("unable to use client certificate: %w", err)
+		}
+		return s, nil
+	case grantTypeRefreshToken:
+		s, err := toSecret(secretManager, o.RefreshToken, o.RefreshTokenFile, o.RefreshTokenRef)
+		if err != nil {
+			return nil, fmt.Errorf("unable to use refresh token: %w", err)
+		}
+		return s, nil
+	default:
+		s, err := toSecret(secretManager, o.ClientSecret, o.ClientSecretFile, o.ClientSecretRef)
+		if err != nil {
+			return nil, fmt.Errorf("unable to use client secret: %w", err)
+		}
+		return s, nil
+	}
 }
 
 // NewOAuth2RoundTripper returns a round tripper that performs OAuth2
@@ -966,13 +1243,17 @@ func NewOAuth2RoundTripper(oauthCredential SecretReader, config *OAuth2, next ht
 		opt.applyToHTTPClientOptions(&opts)
 	}
 
-	return &oauth2RoundTripper{
+	rt := &oauth2RoundTripper{
 		config: config,
 		// A correct tokenSource will be added later on.
 		lastRT:          &oauth2.Transport{Base: next},
 		opts:            &opts,
 		oauthCredential: oauthCredential,
 	}
+	if config.DPoP != nil {
+		rt.dpop = newDPoPProver(config.DPoP, opts.secretManager)
+	}
+	return rt
 }
 
 type oauth2TokenSourceConfig interface {
@@ -1017,10 +1298,16 @@ func (rt *oauth2RoundTripper) newOauth2TokenSource(req *http.Request, clientCred
 	if ua := req.UserAgent(); ua != "" {
 		t = NewUserAgentRoundTripper(ua, t)
 	}
+	if rt.dpop != nil {
+		t = &dpopTokenRoundTripper{prover: rt.dpop, next: t}
+	}
 
 	var config oauth2TokenSourceConfig
+	// cacheCredential identifies the client in the token cache.
+	cacheCredential := clientCredential
 
-	if rt.config.GrantType == grantTypeJWTBearer {
+	switch rt.config.GrantType {
+	case grantTypeJWTBearer:
 		// RFC 7523 3.1 - JWT authorization grants
 		// RFC 7523 3.2 - Client Authentication Processing is not implement upstream yet,
 		// see https://github.com/golang/oauth2/pull/745
@@ -1053,7 +1340,24 @@ func (rt *oauth2RoundTripper) newOauth2TokenSource(req *http.Request, clientCred
 			PrivateClaims:    rt.config.Claims,
 			EndpointParams:   mapToValues(rt.config.EndpointParams),
 		}
-	} else {
+	case grantTypeRefreshToken:
+		clientSecret, err := rt.refreshTokenClientSecret(req.Context())
+		if err != nil {
+			return nil, nil, err
+		}
+		// The refresh token rotates, the client secret does not.
+		cacheCredential = clientSecret
+		config = &refreshTokenConfig{
+			Config: oauth2.Config{
+				ClientID:     rt.config.ClientID,
+				ClientSecret: clientSecret,
+				Endpoint:     oauth2.Endpoint{TokenURL: rt.config.TokenURL},
+				Scopes:       rt.config.Scopes,
+			},
+			RefreshToken: clientCredential,
+			OnRotate:     rt.persistRefreshToken,
+		}
+	default:
 		config = &clientcredentials.Config{
 			ClientID:       rt.config.ClientID,
 			ClientSecret:   clientCredential,
@@ -1064,7 +1368,11 @@ func (rt *oauth2RoundTripper) newOauth2TokenSource(req *http.Request, clientCred
 	}
 	client = &http.Client{Transport: t}
 	ctx := context.WithValue(context.Background(), oauth2.HTTPClient, client)
-	return client, config.TokenSource(ctx), nil
+	source = config.TokenSource(ctx)
+	if rt.config.TokenCacheFile != "" {
+		source = newFileTokenSource(rt.config.TokenCacheFile, rt.config, cacheCredential, source)
+	}
+	return client, source, nil
 }
 
 func (rt *oauth2RoundTripper) RoundTrip(req *http.Request) (*http.Response, error) {
@@ -1110,19 +1418,34 @@ func (rt *oauth2RoundTripper) RoundTrip(req *http.Request) (*http.Response, erro
 			}
 
 			rt.mtx.Lock()
-			rt.lastSecret = newSecret
-			rt.lastRT.Source = source
-			if rt.client != nil {
-				rt.client.CloseIdleConnections()
+			if rt.lastRT.Source != nil && rt.lastSecret == newSecret {
+				// A concurrent request, or the rotation of the refresh
+				// token, has already set up this secret. Replacing its
+				// token source would waste the rotated refresh token.
+				rt.mtx.Unlock()
+				client.CloseIdleConnections()
+			} else {
+				rt.lastSecret = newSecret
+				rt.lastRT.Source = source
+				if rt.client != nil {
+					rt.client.CloseIdleConnections()
+				}
+				rt.client = client
+				rt.mtx.Unlock()
 			}
-			rt.client = client
-			rt.mtx.Unlock()
 		}
 	}
 
 	rt.mtx.RLock()
 	currentRT := rt.lastRT
 	rt.mtx.RUnlock()
+	if rt.dpop != nil {
+		base := currentRT.Base
+		if base == nil {
+			base = http.DefaultTransport
+		}
+		return rt.dpop.roundTrip(req, currentRT.Source, base)
+	}
 	return currentRT.RoundTrip(req)
 }
 
@@ -1153,6 +1476,8 @@ func mapToValues(m map[string]string) url.Values {
 // The decision is sticky, mirroring net/http: once any hop leaves the original
 // host's domain, credentials and sensitive headers stay stripped for the rest
 // of the chain, even if a later hop redirects back to the original host.
+// Subdomains of the original host are part of its domain, unless the redirect
+// policy of the request sets StripAuthOnSubdomains.
 //
 // This works regardless of whether the caller uses NewClientFromConfig or a
 // custom http.Client built from NewRoundTripperFromConfigWithContext directly.
@@ -1161,8 +1486,10 @@ func isCrossHostRedirect(req *http.Request) bool {
 		return false
 	}
 	originalHost := strings.ToLower(originalRequestHost(req))
+	subdomains := !redirectPolicyFromContext(req.Context()).StripAuthOnSubdomains
 	for r := req; r.Response != nil && r.Response.Request != nil; r = r.Response.Request {
-		if !isDomainOrSubdomain(strings.ToLower(r.URL.Hostname()), originalHost) {
+		host := strings.ToLower(r.URL.Hostname())
+		if host != originalHost && (!subdomains || !isDomainOrSubdomain(host, originalHost)) {
 			return true
 		}
 	}
@@ -1241,7 +1568,7 @@ func cloneRequest(r *http.Request) *http.Request {
 	r2 := new(http.Request)
 	*r2 = *r
 	// Deep copy of the Header.
-	maps.Copy(r.Header, r2.Header)
+	r2.Header = r.Header.Clone()
 	return r2
 }
 
@@ -1284,7 +1611,7 @@ func NewTLSConfigWithContext(ctx context.Context, cfg *TLSConfig, optFuncs ...TL
 
 	// If a CA cert is provided then let's read it in so we can validate the
 	// scrape target's certificate properly.
-	caSecret, err := toSecret(opts.secretManager, Secret(cfg.CA), cfg.CAFile, cfg.CARef)
+	caSecret, err := cfg.caSecret(opts.secretManager)
 	if err != nil {
 		return nil, fmt.Errorf("unable to use CA cert: %w", err)
 	}
@@ -1293,7 +1620,11 @@ func NewTLSConfigWithContext(ctx context.Context, cfg *TLSConfig, optFuncs ...TL
 		if err != nil {
 			return nil, fmt.Errorf("unable to read CA cert: %w", err)
 		}
-		if !updateRootCA(tlsConfig, []byte(ca)) {
+		pool, err := newRootCAPool(cfg.CAAppendSystemRoots)
+		if err != nil {
+			return nil, err
+		}
+		if !updateRootCA(tlsConfig, pool, []byte(ca)) {
 			return nil, fmt.Errorf("unable to use specified CA cert %s", caSecret.Description())
 		}
 	}
@@ -1316,6 +1647,23 @@ func NewTLSConfigWithContext(ctx context.Context, cfg *TLSConfig, optFuncs ...TL
 			return cfg.getClientCertificate(ctx, opts.secretManager)
 		}
 	}
+	if len(cfg.ClientCertificates) > 0 {
+		// Verify that all the client certs and keys are valid.
+		if _, err := cfg.getClientCertificates(ctx, opts.secretManager); err != nil {
+			return nil, err
+		}
+		tlsConfig.GetClientCertificate = func(cri *tls.CertificateRequestInfo) (*tls.Certificate, error) {
+			var ctx context.Context
+			if cri != nil {
+				ctx = cri.Context()
+			}
+			certs, err := cfg.getClientCertificates(ctx, opts.secretManager)
+			if err != nil {
+				return nil, err
+			}
+			return selectClientCertificate(cri, certs), nil
+		}
+	}
 
 	return tlsConfig, nil
 }
@@ -1330,6 +1678,13 @@ type TLSConfig struct {
 	Key Secret `yaml:"key,omitempty" json:"key,omitempty"`
 	// The CA cert to use for the targets.
 	CAFile string `yaml:"ca_file,omitempty" json:"ca_file,omitempty"`
+	// CADir is a directory whose *.pem and *.crt files contain the CA certs to
+	// use for the targets. The directory is read again when its content changes.
+	CADir string `yaml:"ca_dir,omitempty" json:"ca_dir,omitempty"`
+	// CAAppendSystemRoots trusts the system CA certs in addition to the
+	// configured ones. It has no effect if no CA cert is configured, as the
+	// system CA certs are used then.
+	CAAppendSystemRoots bool `yaml:"ca_append_system_roots,omitempty" json:"ca_append_system_roots,omitempty"`
 	// The client cert file for the targets.
 	CertFile string `yaml:"cert_file,omitempty" json:"cert_file,omitempty"`
 	// The client key file for the targets.
@@ -1343,6 +1698,9 @@ type TLSConfig struct {
 	// KeyRef is the name of the secret within the secret manager to use as the client key for
 	// the targets.
 	KeyRef string `yaml:"key_ref,omitempty" json:"key_ref,omitempty"`
+	// ClientCertificates are alternative pairs of client cert and key, the
+	// client presents the one accepted by the server.
+	ClientCertificates []TLSClientCertificate `yaml:"client_certificates,omitempty" json:"client_certificates,omitempty"`
 	// Used to verify the hostname for the targets.
 	ServerName string `yaml:"server_name,omitempty" json:"server_name,omitempty"`
 	// Disable target certificate validation.
@@ -1359,8 +1717,12 @@ func (c *TLSConfig) SetDirectory(dir string) {
 		return
 	}
 	c.CAFile = JoinDir(dir, c.CAFile)
+	c.CADir = JoinDir(dir, c.CADir)
 	c.CertFile = JoinDir(dir, c.CertFile)
 	c.KeyFile = JoinDir(dir, c.KeyFile)
+	for i := range c.ClientCertificates {
+		c.ClientCertificates[i].SetDirectory(dir)
+	}
 }
 
 // UnmarshalYAML implements the yaml.Unmarshaler interface.
@@ -1376,8 +1738,8 @@ func (c *TLSConfig) UnmarshalYAML(unmarshal func(any) error) error {
 // file-based fields for the TLS CA, client certificate, and client key are
 // used.
 func (c *TLSConfig) Validate() error {
-	if nonZeroCount(len(c.CA) > 0, len(c.CAFile) > 0, len(c.CARef) > 0) > 1 {
-		return errors.New("at most one of ca, ca_file & ca_ref must be configured")
+	if nonZeroCount(len(c.CA) > 0, len(c.CAFile) > 0, len(c.CARef) > 0, len(c.CADir) > 0) > 1 {
+		return errors.New("at most one of ca, ca_file, ca_ref & ca_dir must be configured")
 	}
 	if nonZeroCount(len(c.Cert) > 0, len(c.CertFile) > 0, len(c.CertRef) > 0) > 1 {
 		return errors.New("at most one of cert, cert_file & cert_ref must be configured")
@@ -1392,6 +1754,15 @@ func (c *TLSConfig) Validate() error {
 		return errors.New("exactly one of cert or cert_file must be configured when a client key is configured")
 	}
 
+	if len(c.ClientCertificates) > 0 && c.usingClientCert() {
+		return errors.New("client_certificates cannot be used with cert & key")
+	}
+	for i := range c.ClientCertificates {
+		if err := c.ClientCertificates[i].validate(i); err != nil {
+			return err
+		}
+	}
+
 	return nil
 }
 
@@ -1403,8 +1774,16 @@ func (c *TLSConfig) usingClientKey() bool {
 	return len(c.Key) > 0 || len(c.KeyFile) > 0 || len(c.KeyRef) > 0
 }
 
+// caSecret returns the SecretReader of the configured CA certs, if any.
+func (c *TLSConfig) caSecret(secretManager SecretManager) (SecretReader, error) {
+	if c.CADir != "" {
+		return &caDirSecret{dir: c.CADir}, nil
+	}
+	return toSecret(secretManager, Secret(c.CA), c.CAFile, c.CARef)
+}
+
 func (c *TLSConfig) roundTripperSettings(secretManager SecretManager) (TLSRoundTripperSettings, error) {
-	ca, err := toSecret(secretManager, Secret(c.CA), c.CAFile, c.CARef)
+	ca, err := c.caSecret(secretManager)
 	if err != nil {
 		return TLSRoundTripperSettings{}, err
 	}
@@ -1416,53 +1795,56 @@ func (c *TLSConfig) roundTripperSettings(secretManager SecretManager) (TLSRoundT
 	if err != nil {
 		return TLSRoundTripperSettings{}, err
 	}
-	return TLSRoundTripperSettings{
-		CA:   ca,
-		Cert: cert,
-		Key:  key,
-	}, nil
+	settings := TLSRoundTripperSettings{
+		CA:                ca,
+		Cert:              cert,
+		Key:               key,
+		AppendSystemRoots: c.CAAppendSystemRoots,
+	}
+	for i := range c.ClientCertificates {
+		cert, key, err := c.ClientCertificates[i].secrets(secretManager)
+		if err != nil {
+			return TLSRoundTripperSettings{}, err
+		}
+		settings.ClientCertificates = append(settings.ClientCertificates, TLSRoundTripperCertificate{Cert: cert, Key: key})
+	}
+	return settings, nil
 }
 
 // getClientCertificate reads the pair of client cert and key and returns a tls.Certificate.
 func (c *TLSConfig) getClientCertificate(ctx context.Context, secretManager SecretManager) (*tls.Certificate, error) {
-	var (
-		certData, keyData string
-		err               error
-	)
+	return c.clientCertificate().load(ctx, secretManager)
+}
 
-	certSecret, err := toSecret(secretManager, Secret(c.Cert), c.CertFile, c.CertRef)
-	if err != nil {
-		return nil, fmt.Errorf("unable to use client cert: %w", err)
-	}
-	if certSecret != nil {
-		certData, err = certSecret.Fetch(ctx)
-		if err != nil {
-			return nil, fmt.Errorf("unable to read specified client cert: %w", err)
-		}
+// clientCertificate returns the pair of client cert and key configured at the
+// top level.
+func (c *TLSConfig) clientCertificate() *TLSClientCertificate {
+	return &TLSClientCertificate{
+		Cert:     c.Cert,
+		Key:      c.Key,
+		CertFile: c.CertFile,
+		KeyFile:  c.KeyFile,
+		CertRef:  c.CertRef,
+		KeyRef:   c.KeyRef,
 	}
+}
 
-	keySecret, err := toSecret(secretManager, c.Key, c.KeyFile, c.KeyRef)
-	if err != nil {
-		return nil, fmt.Errorf("unable to use client key: %w", err)
+// newRootCAPool returns the pool the configured CA certs are added to, which
+// is a copy of the system pool if appendSystemRoots is set.
+func newRootCAPool(appendSystemRoots bool) (*x509.CertPool, error) {
+	if !appendSystemRoots {
+		return x509.NewCertPool(), nil
 	}
-	if keySecret != nil {
-		keyData, err = keySecret.Fetch(ctx)
-		if err != nil {
-			return nil, fmt.Errorf("unable to read specified client key: %w", err)
-		}
-	}
-
-	cert, err := tls.X509KeyPair([]byte(certData), []byte(keyData))
+	pool, err := x509.SystemCertPool()
 	if err != nil {
-		return nil, fmt.Errorf("unable to use specified client cert (%s) & key (%s): %w", certSecret.Description(), keySecret.Description(), err)
+		return nil, fmt.Errorf("unable to load system CA certs: %w", err)
 	}
-
-	return &cert, nil
+	return pool, nil
 }
 
-// updateRootCA parses the given byte slice as a series of PEM encoded certificates and updates tls.Config.RootCAs.
-func updateRootCA(cfg *tls.Config, b []byte) bool {
-	caCertPool := x509.NewCertPool()
+// updateRootCA parses the given byte slice as a series of PEM encoded certificates,
+// adds them to caCertPool and updates tls.Config.RootCAs.
+func updateRootCA(cfg *tls.Config, caCertPool *x509.CertPool, b []byte) bool {
 	if !caCertPool.AppendCertsFromPEM(b) {
 		return false
 	}
@@ -1490,9 +1872,26 @@ type TLSRoundTripperSettings struct {
 	CA   SecretReader
 	Cert SecretReader
 	Key  SecretReader
+	// ClientCertificates are the alternative pairs of client cert and key.
+	ClientCertificates []TLSRoundTripperCertificate
+	// AppendSystemRoots adds the CA certs to the system ones instead of
+	// replacing them.
+	AppendSystemRoots bool
+}
+
+// TLSRoundTripperCertificate is a pair of client cert and key watched by the
+// TLS RoundTripper.
+type TLSRoundTripperCertificate struct {
+	Cert SecretReader
+	Key  SecretReader
 }
 
 func (t *TLSRoundTripperSettings) immutable() bool {
+	for _, c := range t.ClientCertificates {
+		if !c.Cert.Immutable() || !c.Key.Immutable() {
+			return false
+		}
+	}
 	return (t.CA == nil || t.CA.Immutable()) && (t.Cert == nil || t.Cert.Immutable()) && (t.Key == nil || t.Key.Immutable())
 }
 
@@ -1556,6 +1955,21 @@ func (t *tlsRoundTripper) getTLSDataWithHash(ctx context.Context) ([]byte, []byt
 		keyBytes = []byte(key)
 	}
 
+	// The alternative pairs only matter for detecting changes, so they are
+	// hashed together with the top-level pair.
+	for _, c := range t.settings.ClientCertificates {
+		cert, err := c.Cert.Fetch(ctx)
+		if err != nil {
+			return nil, nil, nil, nil, fmt.Errorf("unable to read client cert: %w", err)
+		}
+		certBytes = append(certBytes, cert...)
+		key, err := c.Key.Fetch(ctx)
+		if err != nil {
+			return nil, nil, nil, nil, fmt.Errorf("unable to read client key: %w", err)
+		}
+		keyBytes = append(keyBytes, key...)
+	}
+
 	var caHash, certHash, keyHash [32]byte
 
 	if len(caBytes) > 0 {
@@ -1593,8 +2007,14 @@ func (t *tlsRoundTripper) RoundTrip(req *http.Request) (*http.Response, error) {
 	// The cert and key files are read separately by the client
 	// using GetClientCertificate.
 	tlsConfig := t.tlsConfig.Clone()
-	if t.settings.CA != nil && !updateRootCA(tlsConfig, caData) {
-		return nil, fmt.Errorf("unable to use specified CA cert %s", t.settings.CA.Description())
+	if t.settings.CA != nil {
+		pool, err := newRootCAPool(t.settings.AppendSystemRoots)
+		if err != nil {
+			return nil, err
+		}
+		if !updateRootCA(tlsConfig, pool, caData) {
+			return nil, fmt.Errorf("unable to use specified CA cert %s", t.settings.CA.Description())
+		}
 	}
 	rt, err = t.newRT(tlsConfig)
 	if err != nil {
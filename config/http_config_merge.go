@@ -0,0 +1,309 @@
+// Copyright 2026 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package config
+
+import (
+	"encoding/json"
+	"fmt"
+	"maps"
+	"slices"
+)
+
+// MergeHTTPClientConfig returns base overridden by the settings of override,
+// and validates the result.
+//
+// The settings set in override replace the ones of base, while the unset ones
+// are inherited. Mutually exclusive settings are replaced as a group: an
+// override setting oauth2 clears the inherited basic_auth, and an override
+// setting ca_file clears the inherited ca. The http_headers and
+// proxy_connect_header maps are merged by header name. The nested blocks
+// transport, dialer, hedging, hmac_signing, request_overrides and
+// redirect_policy are replaced as a whole.
+//
+// As booleans cannot be unset, follow_redirects and enable_http2 are only
+// overridden when they differ from DefaultHTTPClientConfig. The TLS
+// insecure_skip_verify setting is never inherited, so that certificate
+// verification cannot be disabled by base alone, and ca_append_system_roots is
+// replaced together with the CA settings, or when it is true in override.
+// LayeredHTTPClientConfigs loaded from YAML or JSON inherit these four
+// booleans unless a configuration sets them explicitly.
+func MergeHTTPClientConfig(base, override HTTPClientConfig) (HTTPClientConfig, error) {
+	return mergeHTTPClientConfig(base, override, nil)
+}
+
+// explicitFlags records the booleans explicitly set by a configuration, which
+// can not be told apart from unset ones otherwise. The other settings are
+// collected by the inline maps, so that the strict mode of the YAML decoder
+// does not reject them.
+type explicitFlags struct {
+	FollowRedirects *bool            `yaml:"follow_redirects" json:"follow_redirects"`
+	EnableHTTP2     *bool            `yaml:"enable_http2" json:"enable_http2"`
+	TLSConfig       explicitTLSFlags `yaml:"tls_config" json:"tls_config"`
+	Other           map[string]any   `yaml:",inline" json:"-"`
+}
+
+type explicitTLSFlags struct {
+	InsecureSkipVerify  *bool          `yaml:"insecure_skip_verify" json:"insecure_skip_verify"`
+	CAAppendSystemRoots *bool          `yaml:"ca_append_system_roots" json:"ca_append_system_roots"`
+	Other               map[string]any `yaml:",inline" json:"-"`
+}
+
+func mergeHTTPClientConfig(base, override HTTPClientConfig, flags *explicitFlags) (HTTPClientConfig, error) {
+	merged := base
+
+	if override.hasAuth() {
+		merged.BasicAuth = clonePtr(override.BasicAuth)
+		merged.DigestAuth = clonePtr(override.DigestAuth)
+		merged.Authorization = clonePtr(override.Authorization)
+		merged.OAuth2 = clonePtr(override.OAuth2)
+		merged.BearerToken = override.BearerToken
+		merged.BearerTokenFile = override.BearerTokenFile
+	} else {
+		merged.BasicAuth = clonePtr(base.BasicAuth)
+		merged.DigestAuth = clonePtr(base.DigestAuth)
+		merged.Authorization = clonePtr(base.Authorization)
+		merged.OAuth2 = clonePtr(base.OAuth2)
+	}
+
+	var tlsFlags *explicitTLSFlags
+	if flags != nil {
+		tlsFlags = &flags.TLSConfig
+	}
+	merged.TLSConfig = mergeTLSConfig(base.TLSConfig, override.TLSConfig, tlsFlags)
+	merged.ProxyConfig = mergeProxyConfig(base.ProxyConfig, override.ProxyConfig)
+
+	if flags != nil {
+		if flags.FollowRedirects != nil {
+			merged.FollowRedirects = override.FollowRedirects
+		}
+		if flags.EnableHTTP2 != nil {
+			merged.EnableHTTP2 = override.EnableHTTP2
+		}
+	} else {
+		if override.FollowRedirects != DefaultHTTPClientConfig.FollowRedirects {
+			merged.FollowRedirects = override.FollowRedirects
+		}
+		if override.EnableHTTP2 != DefaultHTTPClientConfig.EnableHTTP2 {
+			merged.EnableHTTP2 = override.EnableHTTP2
+		}
+	}
+
+	merged.HTTPHeaders = mergeHeaders(base.HTTPHeaders, override.HTTPHeaders)
+
+	merged.Transport = overridePtr(base.Transport, override.Transport)
+	merged.Dialer = overridePtr(base.Dialer, override.Dialer)
+	merged.Hedging = overridePtr(base.Hedging, override.Hedging)
+	merged.HMACSigning = overridePtr(base.HMACSigning, override.HMACSigning)
+	merged.RequestOverrides = overridePtr(base.RequestOverrides, override.RequestOverrides)
+	merged.RedirectPolicy = overridePtr(base.RedirectPolicy, override.RedirectPolicy)
+
+	if override.MaxResponseBodyBytes != 0 {
+		merged.MaxResponseBodyBytes = override.MaxResponseBodyBytes
+	}
+	if override.MaxResponseHeaderBytes != 0 {
+		merged.MaxResponseHeaderBytes = override.MaxResponseHeaderBytes
+	}
+
+	if err := merged.Validate(); err != nil {
+		return HTTPClientConfig{}, err
+	}
+	return merged, nil
+}
+
+// hasAuth reports whether any of the mutually exclusive authentication
+// methods is configured.
+func (c *HTTPClientConfig) hasAuth() bool {
+	return c.BasicAuth != nil || c.DigestAuth != nil || c.Authorization != nil || c.OAuth2 != nil ||
+		len(c.BearerToken) > 0 || len(c.BearerTokenFile) > 0
+}
+
+func mergeTLSConfig(base, override TLSConfig, flags *explicitTLSFlags) TLSConfig {
+	merged := base
+	if nonZeroCount(override.CA, override.CAFile, override.CARef, override.CADir) > 0 {
+		merged.CA, merged.CAFile, merged.CARef, merged.CADir = override.CA, override.CAFile, override.CARef, override.CADir
+		merged.CAAppendSystemRoots = override.CAAppendSystemRoots
+	} else if override.CAAppendSystemRoots {
+		merged.CAAppendSystemRoots = true
+	}
+	if override.usingClientCert() || override.usingClientKey() || len(override.ClientCertificates) > 0 {
+		merged.Cert, merged.CertFile, merged.CertRef = override.Cert, override.CertFile, override.CertRef
+		merged.Key, merged.KeyFile, merged.KeyRef = override.Key, override.KeyFile, override.KeyRef
+		merged.ClientCertificates = override.ClientCertificates
+	}
+	merged.ClientCertificates = slices.Clone(merged.ClientCertificates)
+	if override.ServerName != "" {
+		merged.ServerName = override.ServerName
+	}
+	merged.InsecureSkipVerify = override.InsecureSkipVerify
+	if flags != nil {
+		if flags.InsecureSkipVerify == nil {
+			merged.InsecureSkipVerify = base.InsecureSkipVerify
+		}
+		if flags.CAAppendSystemRoots != nil {
+			merged.CAAppendSystemRoots = *flags.CAAppendSystemRoots
+		}
+	}
+	if override.MinVersion != 0 {
+		merged.MinVersion = override.MinVersion
+	}
+	if override.MaxVersion != 0 {
+		merged.MaxVersion = override.MaxVersion
+	}
+	return merged
+}
+
+func mergeProxyConfig(base, override ProxyConfig) ProxyConfig {
+	merged := ProxyConfig{
+		ProxyURL:             base.ProxyURL,
+		NoProxy:              base.NoProxy,
+		ProxyFromEnvironment: base.ProxyFromEnvironment,
+	}
+	if override.ProxyFromEnvironment || (override.ProxyURL.URL != nil && override.ProxyURL.String() != "") {
+		merged.ProxyURL = override.ProxyURL
+		merged.NoProxy = override.NoProxy
+		merged.ProxyFromEnvironment = override.ProxyFromEnvironment
+	} else if override.NoProxy != "" {
+		merged.NoProxy = override.NoProxy
+	}
+	if base.ProxyConnectHeader != nil || override.ProxyConnectHeader != nil {
+		merged.ProxyConnectHeader = maps.Clone(base.ProxyConnectHeader)
+		if merged.ProxyConnectHeader == nil {
+			merged.ProxyConnectHeader = ProxyHeader{}
+		}
+		maps.Copy(merged.ProxyConnectHeader, override.ProxyConnectHeader)
+	}
+	return merged
+}
+
+func mergeHeaders(base, override *Headers) *Headers {
+	if base == nil && override == nil {
+		return nil
+	}
+	merged := &Headers{Headers: map[string]Header{}}
+	if base != nil {
+		maps.Copy(merged.Headers, base.Headers)
+	}
+	if override != nil {
+		maps.Copy(merged.Headers, override.Headers)
+	}
+	return merged
+}
+
+// clonePtr returns a shallow copy of *p, so that the merged configuration
+// does not share the blocks which Validate modifies.
+func clonePtr[T any](p *T) *T {
+	if p == nil {
+		return nil
+	}
+	c := *p
+	return &c
+}
+
+func overridePtr[T any](base, override *T) *T {
+	if override != nil {
+		return clonePtr(override)
+	}
+	return clonePtr(base)
+}
+
+// LayeredHTTPClientConfigs are named HTTP client configurations inheriting
+// the settings of a defaults block, following MergeHTTPClientConfig:
+//
+//	defaults:
+//	  tls_config:
+//	    ca_file: ca.crt
+//	configs:
+//	  job:
+//	    basic_auth:
+//	      username: user
+//
+// The follow_redirects, enable_http2 and TLS insecure_skip_verify and
+// ca_append_system_roots settings of the defaults are inherited unless a
+// configuration sets them explicitly, which is only known for configurations
+// unmarshaled from YAML or JSON. Configurations
+// built in Go are merged with MergeHTTPClientConfig as is.
+type LayeredHTTPClientConfigs struct {
+	Defaults HTTPClientConfig            `yaml:"defaults,omitempty" json:"defaults,omitempty"`
+	Configs  map[string]HTTPClientConfig `yaml:"configs,omitempty" json:"configs,omitempty"`
+
+	// flags holds the booleans set explicitly by each configuration.
+	flags map[string]*explicitFlags
+}
+
+// UnmarshalYAML implements the yaml.Unmarshaler interface.
+func (c *LayeredHTTPClientConfigs) UnmarshalYAML(unmarshal func(any) error) error {
+	type plain LayeredHTTPClientConfigs
+	*c = LayeredHTTPClientConfigs{Defaults: DefaultHTTPClientConfig}
+	if err := unmarshal((*plain)(c)); err != nil {
+		return err
+	}
+	var flags struct {
+		Configs map[string]*explicitFlags `yaml:"configs"`
+		Other   map[string]any            `yaml:",inline"`
+	}
+	if err := unmarshal(&flags); err != nil {
+		return err
+	}
+	c.flags = flags.Configs
+	return c.validate()
+}
+
+// UnmarshalJSON implements the json.Unmarshaler interface.
+func (c *LayeredHTTPClientConfigs) UnmarshalJSON(data []byte) error {
+	type plain LayeredHTTPClientConfigs
+	*c = LayeredHTTPClientConfigs{Defaults: DefaultHTTPClientConfig}
+	if err := json.Unmarshal(data, (*plain)(c)); err != nil {
+		return err
+	}
+	var flags struct {
+		Configs map[string]*explicitFlags `json:"configs"`
+	}
+	if err := json.Unmarshal(data, &flags); err != nil {
+		return err
+	}
+	c.flags = flags.Configs
+	return c.validate()
+}
+
+// validate checks that every configuration is valid once merged.
+func (c *LayeredHTTPClientConfigs) validate() error {
+	for name := range c.Configs {
+		if _, err := c.Get(name); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// SetDirectory joins any relative file paths with dir.
+func (c *LayeredHTTPClientConfigs) SetDirectory(dir string) {
+	c.Defaults.SetDirectory(dir)
+	for name, cfg := range c.Configs {
+		cfg.SetDirectory(dir)
+		c.Configs[name] = cfg
+	}
+}
+
+// Get returns the configuration of the given name merged with the defaults.
+func (c *LayeredHTTPClientConfigs) Get(name string) (HTTPClientConfig, error) {
+	cfg, ok := c.Configs[name]
+	if !ok {
+		return HTTPClientConfig{}, fmt.Errorf("unknown HTTP client config %q", name)
+	}
+	merged, err := mergeHTTPClientConfig(c.Defaults, cfg, c.flags[name])
+	if err != nil {
+		return HTTPClientConfig{}, fmt.Errorf("HTTP client config %q: %w", name, err)
+	}
+	return merged, nil
+}
@@ -0,0 +1,245 @@
+// Copyright 2026 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package config
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"go.yaml.in/yaml/v2"
+)
+
+func TestMergeHTTPClientConfig(t *testing.T) {
+	base, err := LoadHTTPConfig(`
+basic_auth:
+  username: user
+  password: pass
+tls_config:
+  ca: ca
+  server_name: example.org
+http_headers:
+  X-A:
+    values: [a]
+  X-B:
+    values: [b]
+follow_redirects: false
+`)
+	require.NoError(t, err)
+	override, err := LoadHTTPConfig(`
+oauth2:
+  client_id: id
+  token_url: https://auth.example.org/token
+tls_config:
+  ca_file: ca.crt
+  min_version: TLS13
+http_headers:
+  X-B:
+    values: [override]
+`)
+	require.NoError(t, err)
+
+	merged, err := MergeHTTPClientConfig(*base, *override)
+	require.NoError(t, err)
+	require.Nil(t, merged.BasicAuth)
+	require.Equal(t, "id", merged.OAuth2.ClientID)
+	require.Equal(t, TLSConfig{CAFile: "ca.crt", ServerName: "example.org", MinVersion: TLSVersions["TLS13"]}, merged.TLSConfig)
+	require.Equal(t, map[string]Header{"X-A": {Values: []string{"a"}}, "X-B": {Values: []string{"override"}}}, merged.HTTPHeaders.Headers)
+	// The override keeps the default of follow_redirects, so it is inherited.
+	require.False(t, merged.FollowRedirects)
+	require.True(t, merged.EnableHTTP2)
+
+	// The inputs are not modified.
+	require.Equal(t, "user", base.BasicAuth.Username)
+	require.Len(t, base.HTTPHeaders.Headers["X-B"].Values, 1)
+	require.Equal(t, "b", base.HTTPHeaders.Headers["X-B"].Values[0])
+	require.Equal(t, "ca", base.TLSConfig.CA)
+}
+
+func TestMergeHTTPClientConfigInherit(t *testing.T) {
+	base := DefaultHTTPClientConfig
+	base.Authorization = &Authorization{Credentials: "token"}
+	base.TLSConfig = TLSConfig{CertFile: "client.crt", KeyFile: "client.key"}
+
+	override := DefaultHTTPClientConfig
+	override.EnableHTTP2 = false
+	override.BearerTokenFile = "token"
+	override.TLSConfig = TLSConfig{InsecureSkipVerify: true}
+
+	merged, err := MergeHTTPClientConfig(base, override)
+	require.NoError(t, err)
+	// The bearer token file replaces the inherited authorization.
+	require.Equal(t, &Authorization{Type: "Bearer", CredentialsFile: "token"}, merged.Authorization)
+	require.Equal(t, TLSConfig{CertFile: "client.crt", KeyFile: "client.key", InsecureSkipVerify: true}, merged.TLSConfig)
+	require.False(t, merged.EnableHTTP2)
+	require.Empty(t, base.Authorization.Type)
+
+	// Certificate verification is not disabled by the base alone.
+	base.TLSConfig = TLSConfig{CA: "ca", CAAppendSystemRoots: true, InsecureSkipVerify: true}
+	merged, err = MergeHTTPClientConfig(base, DefaultHTTPClientConfig)
+	require.NoError(t, err)
+	require.Equal(t, TLSConfig{CA: "ca", CAAppendSystemRoots: true}, merged.TLSConfig)
+	// ca_append_system_roots is replaced together with the CA.
+	override = DefaultHTTPClientConfig
+	override.TLSConfig = TLSConfig{CAFile: "ca.crt"}
+	merged, err = MergeHTTPClientConfig(base, override)
+	require.NoError(t, err)
+	require.Equal(t, TLSConfig{CAFile: "ca.crt"}, merged.TLSConfig)
+
+	base.TLSConfig = TLSConfig{}
+	override = DefaultHTTPClientConfig
+	override.RequestOverrides = &RequestOverridesConfig{Credentials: true}
+	base.Authorization = nil
+	base.BasicAuth = &BasicAuth{Username: "user"}
+	_, err = MergeHTTPClientConfig(base, override)
+	require.EqualError(t, err, "request_overrides credentials is only compatible with authorization")
+}
+
+func TestLayeredHTTPClientConfigs(t *testing.T) {
+	var cfg LayeredHTTPClientConfigs
+	require.NoError(t, yaml.UnmarshalStrict([]byte(`
+defaults:
+  basic_auth:
+    username: user
+    password_file: password
+  enable_http2: false
+configs:
+  a:
+    tls_config:
+      insecure_skip_verify: true
+  b:
+    authorization:
+      credentials_file: token
+`), &cfg))
+	cfg.SetDirectory("/etc/prometheus")
+
+	a, err := cfg.Get("a")
+	require.NoError(t, err)
+	require.Equal(t, &BasicAuth{Username: "user", PasswordFile: "/etc/prometheus/password"}, a.BasicAuth)
+	require.True(t, a.TLSConfig.InsecureSkipVerify)
+	require.False(t, a.EnableHTTP2)
+
+	b, err := cfg.Get("b")
+	require.NoError(t, err)
+	require.Nil(t, b.BasicAuth)
+	require.Equal(t, &Authorization{Type: "Bearer", CredentialsFile: "/etc/prometheus/token"}, b.Authorization)
+
+	_, err = cfg.Get("c")
+	require.EqualError(t, err, `unknown HTTP client config "c"`)
+
+	err = yaml.UnmarshalStrict([]byte(`
+defaults:
+  basic_auth:
+    username: user
+configs:
+  a:
+    request_overrides:
+      credentials: true
+`), &cfg)
+	require.EqualError(t, err, `HTTP client config "a": request_overrides credentials is only compatible with authorization`)
+}
+
+func TestLayeredHTTPClientConfigsTLSFlags(t *testing.T) {
+	const (
+		yamlConfig = `
+defaults:
+  tls_config:
+    ca_file: ca.crt
+    ca_append_system_roots: true
+    insecure_skip_verify: true
+configs:
+  inherit:
+    tls_config:
+      server_name: example.org
+  verify:
+    tls_config:
+      server_name: example.org
+      insecure_skip_verify: false
+  no_system_roots:
+    tls_config:
+      ca_append_system_roots: false
+`
+		jsonConfig = `{
+  "defaults": {"tls_config": {"ca_file": "ca.crt", "ca_append_system_roots": true, "insecure_skip_verify": true}},
+  "configs": {
+    "inherit": {"tls_config": {"server_name": "example.org"}},
+    "verify": {"tls_config": {"server_name": "example.org", "insecure_skip_verify": false}},
+    "no_system_roots": {"tls_config": {"ca_append_system_roots": false}}
+  }
+}`
+	)
+
+	var fromYAML, fromJSON LayeredHTTPClientConfigs
+	require.NoError(t, yaml.UnmarshalStrict([]byte(yamlConfig), &fromYAML))
+	require.NoError(t, json.Unmarshal([]byte(jsonConfig), &fromJSON))
+
+	for _, cfg := range []LayeredHTTPClientConfigs{fromYAML, fromJSON} {
+		inherit, err := cfg.Get("inherit")
+		require.NoError(t, err)
+		require.Equal(t, TLSConfig{CAFile: "ca.crt", CAAppendSystemRoots: true, InsecureSkipVerify: true, ServerName: "example.org"}, inherit.TLSConfig)
+
+		verify, err := cfg.Get("verify")
+		require.NoError(t, err)
+		require.Equal(t, TLSConfig{CAFile: "ca.crt", CAAppendSystemRoots: true, ServerName: "example.org"}, verify.TLSConfig)
+
+		noSystemRoots, err := cfg.Get("no_system_roots")
+		require.NoError(t, err)
+		require.Equal(t, TLSConfig{CAFile: "ca.crt", InsecureSkipVerify: true}, noSystemRoots.TLSConfig)
+	}
+}
+
+func TestLayeredHTTPClientConfigsFlags(t *testing.T) {
+	const (
+		yamlConfig = `
+defaults:
+  follow_redirects: false
+  enable_http2: false
+configs:
+  inherit: {}
+  redirects:
+    follow_redirects: true
+  http2:
+    enable_http2: true
+`
+		jsonConfig = `{
+  "defaults": {"follow_redirects": false, "enable_http2": false},
+  "configs": {
+    "inherit": {},
+    "redirects": {"follow_redirects": true},
+    "http2": {"enable_http2": true}
+  }
+}`
+	)
+
+	var fromYAML, fromJSON LayeredHTTPClientConfigs
+	require.NoError(t, yaml.UnmarshalStrict([]byte(yamlConfig), &fromYAML))
+	require.NoError(t, json.Unmarshal([]byte(jsonConfig), &fromJSON))
+
+	for _, cfg := range []LayeredHTTPClientConfigs{fromYAML, fromJSON} {
+		inherit, err := cfg.Get("inherit")
+		require.NoError(t, err)
+		require.False(t, inherit.FollowRedirects)
+		require.False(t, inherit.EnableHTTP2)
+
+		redirects, err := cfg.Get("redirects")
+		require.NoError(t, err)
+		require.True(t, redirects.FollowRedirects)
+		require.False(t, redirects.EnableHTTP2)
+
+		http2, err := cfg.Get("http2")
+		require.NoError(t, err)
+		require.False(t, http2.FollowRedirects)
+		require.True(t, http2.EnableHTTP2)
+	}
+}
@@ -800,6 +800,52 @@ func TestTLSConfig(t *testing.T) {
 	require.Truef(t, reflect.DeepEqual(tlsConfig, expectedTLSConfig), "Unexpected TLS Config result: \n\n%+v\n expected\n\n%+v", tlsConfig, expectedTLSConfig)
 }
 
+func TestTLSConfigCAAppendSystemRoots(t *testing.T) {
+	tlsConfig, err := NewTLSConfig(&TLSConfig{CAFile: TLSCAChainPath, CAAppendSystemRoots: true})
+	require.NoError(t, err)
+
+	tlsCAChain, err := os.ReadFile(TLSCAChainPath)
+	require.NoError(t, err)
+	expected, err := x509.SystemCertPool()
+	require.NoError(t, err)
+	require.True(t, expected.AppendCertsFromPEM(tlsCAChain))
+	require.True(t, expected.Equal(tlsConfig.RootCAs))
+}
+
+func TestTLSConfigCADir(t *testing.T) {
+	ts := httptest.NewUnstartedServer(http.HandlerFunc(func(http.ResponseWriter, *http.Request) {}))
+	cert, err := tls.LoadX509KeyPair(ServerCertificatePath, ServerKeyPath)
+	require.NoError(t, err)
+	ts.TLS = &tls.Config{Certificates: []tls.Certificate{cert}}
+	ts.StartTLS()
+	defer ts.Close()
+
+	dir := t.TempDir()
+	copyFile := func(src, dst string) {
+		b, err := os.ReadFile(src)
+		require.NoError(t, err)
+		require.NoError(t, os.WriteFile(filepath.Join(dir, dst), b, 0o600))
+	}
+	copyFile(WrongClientCertPath, "other.pem")
+	// Files with other extensions are ignored.
+	copyFile(ServerKeyPath, "server.key")
+
+	client, err := NewClientFromConfig(HTTPClientConfig{TLSConfig: TLSConfig{CADir: dir}}, "test")
+	require.NoError(t, err)
+	_, err = client.Get(ts.URL)
+	require.Error(t, err)
+
+	copyFile(TLSCAChainPath, "ca.crt")
+	resp, err := client.Get(ts.URL)
+	require.NoError(t, err)
+	resp.Body.Close()
+
+	_, err = NewTLSConfig(&TLSConfig{CADir: t.TempDir()})
+	require.ErrorContains(t, err, "unable to use specified CA cert directory")
+	_, err = NewTLSConfig(&TLSConfig{CADir: dir, CAFile: TLSCAChainPath})
+	require.EqualError(t, err, "at most one of ca, ca_file, ca_ref & ca_dir must be configured")
+}
+
 func TestTLSConfigEmpty(t *testing.T) {
 	configTLSConfig := TLSConfig{
 		InsecureSkipVerify: true,
@@ -2697,6 +2743,42 @@ func TestHeaders(t *testing.T) {
 	require.NoErrorf(t, err, "can't fetch URL: %v", err)
 }
 
+func TestCloneRequestDoesNotMutateCallerHeaders(t *testing.T) {
+	req, err := http.NewRequest(http.MethodGet, "http://example.com", nil)
+	require.NoError(t, err)
+	req.Header.Set("X-Caller", "a")
+
+	r2 := cloneRequest(req)
+	r2.Header.Set("Authorization", "Bearer secret")
+	r2.Header.Set("X-Caller", "b")
+	require.Equal(t, http.Header{"X-Caller": {"a"}}, req.Header)
+
+	// None of the round trippers adding credentials or headers may leak them
+	// into the request of the caller.
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		require.Equal(t, "Bearer token", r.Header.Get("Authorization"))
+		require.Equal(t, "value1", r.Header.Get("One"))
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	t.Cleanup(ts.Close)
+
+	cfg := HTTPClientConfig{
+		Authorization: &Authorization{Type: "Bearer", Credentials: "token"},
+		HTTPHeaders: &Headers{Headers: map[string]Header{
+			"One": {Values: []string{"value1"}},
+		}},
+	}
+	client, err := NewClientFromConfig(cfg, "test")
+	require.NoError(t, err)
+	req, err = http.NewRequest(http.MethodGet, ts.URL, nil)
+	require.NoError(t, err)
+	req.Header.Set("X-Caller", "a")
+	resp, err := client.Do(req)
+	require.NoError(t, err)
+	resp.Body.Close()
+	require.Equal(t, http.Header{"X-Caller": {"a"}}, req.Header)
+}
+
 func TestMultipleHeaders(t *testing.T) {
 	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		for k, v := range map[string][]string{
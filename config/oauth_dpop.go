@@ -0,0 +1,332 @@
+// Copyright 2026 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package config
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
+	"golang.org/x/oauth2"
+)
+
+// DPoPConfig configures the OAuth2 client to request and use
+// sender-constrained tokens with Demonstrating Proof of Possession (RFC 9449).
+// When no private key is configured, an ECDSA P-256 key is generated at
+// startup. Tokens issued with a token_type other than DPoP are sent as bearer
+// tokens.
+type DPoPConfig struct {
+	// PrivateKey is the PEM encoded ECDSA, RSA or Ed25519 private key used to
+	// sign the proofs.
+	PrivateKey     Secret `yaml:"private_key,omitempty" json:"private_key,omitempty"`
+	PrivateKeyFile string `yaml:"private_key_file,omitempty" json:"private_key_file,omitempty"`
+	// PrivateKeyRef is the name of the secret within the secret manager to
+	// use as the private key.
+	PrivateKeyRef string `yaml:"private_key_ref,omitempty" json:"private_key_ref,omitempty"`
+}
+
+// SetDirectory joins any relative file paths with dir.
+func (c *DPoPConfig) SetDirectory(dir string) {
+	if c == nil {
+		return
+	}
+	c.PrivateKeyFile = JoinDir(dir, c.PrivateKeyFile)
+}
+
+// Validate validates the DPoPConfig.
+func (c *DPoPConfig) Validate() error {
+	if c == nil {
+		return nil
+	}
+	if nonZeroCount(len(c.PrivateKey) > 0, len(c.PrivateKeyFile) > 0, len(c.PrivateKeyRef) > 0) > 1 {
+		return errors.New("at most one of oauth2 dpop private_key, private_key_file & private_key_ref must be configured")
+	}
+	return nil
+}
+
+// hasKey returns whether a private key is configured.
+func (c *DPoPConfig) hasKey() bool {
+	return len(c.PrivateKey) > 0 || len(c.PrivateKeyFile) > 0 || len(c.PrivateKeyRef) > 0
+}
+
+// dpopProver signs DPoP proofs and keeps track of the nonces provided by the
+// servers.
+type dpopProver struct {
+	cfg           *DPoPConfig
+	secretManager SecretManager
+
+	mtx    sync.Mutex
+	key    crypto.Signer
+	method jwt.SigningMethod
+	jwk    map[string]any
+	// nonces are the latest nonces received, by origin.
+	nonces map[string]string
+}
+
+func newDPoPProver(cfg *DPoPConfig, secretManager SecretManager) *dpopProver {
+	return &dpopProver{
+		cfg:           cfg,
+		secretManager: secretManager,
+		nonces:        map[string]string{},
+	}
+}
+
+// loadKey loads or generates the private key on first use. The key is kept for
+// the lifetime of the prover, as the tokens are bound to it.
+func (p *dpopProver) loadKey(ctx context.Context) error {
+	p.mtx.Lock()
+	defer p.mtx.Unlock()
+	if p.key != nil {
+		return nil
+	}
+
+	var key crypto.Signer
+	if p.cfg.hasKey() {
+		secret, err := toSecret(p.secretManager, p.cfg.PrivateKey, p.cfg.PrivateKeyFile, p.cfg.PrivateKeyRef)
+		if err != nil {
+			return fmt.Errorf("unable to use dpop private key: %w", err)
+		}
+		pem, err := secret.Fetch(ctx)
+		if err != nil {
+			return fmt.Errorf("unable to read dpop private key: %w", err)
+		}
+		if key, err = parseDPoPKey([]byte(pem)); err != nil {
+			return err
+		}
+	} else {
+		var err error
+		if key, err = ecdsa.GenerateKey(elliptic.P256(), rand.Reader); err != nil {
+			return fmt.Errorf("unable to generate dpop private key: %w", err)
+		}
+	}
+
+	method, jwk, err := dpopPublicKey(key)
+	if err != nil {
+		return err
+	}
+	p.key, p.method, p.jwk = key, method, jwk
+	return nil
+}
+
+// parseDPoPKey parses a PEM encoded ECDSA, RSA or Ed25519 private key.
+func parseDPoPKey(pem []byte) (crypto.Signer, error) {
+	if key, err := jwt.ParseECPrivateKeyFromPEM(pem); err == nil {
+		return key, nil
+	}
+	if key, err := jwt.ParseRSAPrivateKeyFromPEM(pem); err == nil {
+		return key, nil
+	}
+	if key, err := jwt.ParseEdPrivateKeyFromPEM(pem); err == nil {
+		return key.(crypto.Signer), nil
+	}
+	return nil, errors.New("dpop private key must be a PEM encoded ECDSA, RSA or Ed25519 key")
+}
+
+// dpopPublicKey returns the signing method matching key and its public key
+// as a JWK (RFC 7517).
+func dpopPublicKey(key crypto.Signer) (jwt.SigningMethod, map[string]any, error) {
+	b64 := base64.RawURLEncoding.EncodeToString
+	switch k := key.(type) {
+	case *ecdsa.PrivateKey:
+		var method jwt.SigningMethod
+		switch k.Curve {
+		case elliptic.P256():
+			method = jwt.SigningMethodES256
+		case elliptic.P384():
+			method = jwt.SigningMethodES384
+		case elliptic.P521():
+			method = jwt.SigningMethodES512
+		default:
+			return nil, nil, errors.New("unsupported dpop private key curve")
+		}
+		pub, err := k.PublicKey.ECDH()
+		if err != nil {
+			return nil, nil, err
+		}
+		// The uncompressed point is 0x04 || X || Y, with fixed size coordinates.
+		point := pub.Bytes()[1:]
+		return method, map[string]any{
+			"kty": "EC",
+			"crv": k.Curve.Params().Name,
+			"x":   b64(point[:len(point)/2]),
+			"y":   b64(point[len(point)/2:]),
+		}, nil
+	case *rsa.PrivateKey:
+		return jwt.SigningMethodRS256, map[string]any{
+			"kty": "RSA",
+			"n":   b64(k.N.Bytes()),
+			"e":   b64(big.NewInt(int64(k.E)).Bytes()),
+		}, nil
+	case ed25519.PrivateKey:
+		return jwt.SigningMethodEdDSA, map[string]any{
+			"kty": "OKP",
+			"crv": "Ed25519",
+			"x":   b64(k.Public().(ed25519.PublicKey)),
+		}, nil
+	}
+	return nil, nil, errors.New("unsupported dpop private key type")
+}
+
+// dpopOrigin returns the origin of u, which scopes the server nonces.
+func dpopOrigin(u *url.URL) string {
+	return strings.ToLower(u.Scheme + "://" + u.Host)
+}
+
+// proof returns a DPoP proof for req. If accessToken is not empty, the proof
+// is bound to it with the ath claim.
+func (p *dpopProver) proof(req *http.Request, accessToken string) (string, error) {
+	// The htu claim is the URL without query and fragment (RFC 9449 section
+	// 4.2), with the empty path normalized to "/".
+	htu := url.URL{Scheme: req.URL.Scheme, Host: req.URL.Host, Path: req.URL.Path, RawPath: req.URL.RawPath}
+	if htu.Path == "" {
+		htu.Path = "/"
+	}
+	claims := jwt.MapClaims{
+		"jti": uuid.NewString(),
+		"htm": req.Method,
+		"htu": htu.String(),
+		"iat": time.Now().Unix(),
+	}
+	if accessToken != "" {
+		ath := sha256.Sum256([]byte(accessToken))
+		claims["ath"] = base64.RawURLEncoding.EncodeToString(ath[:])
+	}
+
+	p.mtx.Lock()
+	if nonce := p.nonces[dpopOrigin(req.URL)]; nonce != "" {
+		claims["nonce"] = nonce
+	}
+	token := jwt.NewWithClaims(p.method, claims)
+	token.Header["typ"] = "dpop+jwt"
+	token.Header["jwk"] = p.jwk
+	key := p.key
+	p.mtx.Unlock()
+
+	return token.SignedString(key)
+}
+
+// updateNonce records the nonce provided by resp, if any. It returns whether
+// the nonce changed.
+func (p *dpopProver) updateNonce(req *http.Request, resp *http.Response) bool {
+	nonce := resp.Header.Get("DPoP-Nonce")
+	if nonce == "" {
+		return false
+	}
+	origin := dpopOrigin(req.URL)
+	p.mtx.Lock()
+	defer p.mtx.Unlock()
+	if p.nonces[origin] == nonce {
+		return false
+	}
+	p.nonces[origin] = nonce
+	return true
+}
+
+// send sends req with a DPoP proof. When the server asks for a new nonce, the
+// request is retried once with it.
+func (p *dpopProver) send(req *http.Request, accessToken string, next http.RoundTripper, useNonce func(*http.Response) bool) (*http.Response, error) {
+	if err := p.loadKey(req.Context()); err != nil {
+		return nil, err
+	}
+	for attempt := 0; ; attempt++ {
+		proof, err := p.proof(req, accessToken)
+		if err != nil {
+			return nil, fmt.Errorf("unable to sign dpop proof: %w", err)
+		}
+		r := cloneRequest(req)
+		r.Header.Set("DPoP", proof)
+		if accessToken != "" {
+			r.Header.Set("Authorization", "DPoP "+accessToken)
+		}
+		if attempt > 0 && req.GetBody != nil {
+			if r.Body, err = req.GetBody(); err != nil {
+				return nil, err
+			}
+		}
+
+		resp, err := next.RoundTrip(r)
+		if err != nil {
+			return nil, err
+		}
+		if !p.updateNonce(req, resp) || !useNonce(resp) || attempt > 0 {
+			return resp, nil
+		}
+		if req.Body != nil && req.Body != http.NoBody && req.GetBody == nil {
+			// The body cannot be sent again.
+			return resp, nil
+		}
+		io.Copy(io.Discard, resp.Body)
+		resp.Body.Close()
+	}
+}
+
+// roundTrip sends req to a resource server with an access token from source.
+// Tokens which are not DPoP-bound are sent as bearer tokens, without proof.
+func (p *dpopProver) roundTrip(req *http.Request, source oauth2.TokenSource, next http.RoundTripper) (*http.Response, error) {
+	if source == nil {
+		return nil, errors.New("oauth2: Transport's Source is nil")
+	}
+	token, err := source.Token()
+	if err != nil {
+		return nil, err
+	}
+	if !strings.EqualFold(token.Type(), "DPoP") {
+		// The authorization server does not support DPoP and issued a
+		// token of another type (RFC 9449 section 5).
+		r := cloneRequest(req)
+		token.SetAuthHeader(r)
+		return next.RoundTrip(r)
+	}
+	return p.send(req, token.AccessToken, next, func(resp *http.Response) bool {
+		return resp.StatusCode == http.StatusUnauthorized && strings.Contains(resp.Header.Get("WWW-Authenticate"), "use_dpop_nonce")
+	})
+}
+
+// dpopTokenRoundTripper attaches DPoP proofs to the requests sent to the
+// token endpoint.
+type dpopTokenRoundTripper struct {
+	prover *dpopProver
+	next   http.RoundTripper
+}
+
+func (rt *dpopTokenRoundTripper) RoundTrip(req *http.Request) (*http.Response, error) {
+	return rt.prover.send(req, "", rt.next, func(resp *http.Response) bool {
+		// The authorization server answers with a use_dpop_nonce error
+		// (RFC 9449 section 8).
+		return resp.StatusCode == http.StatusBadRequest
+	})
+}
+
+func (rt *dpopTokenRoundTripper) CloseIdleConnections() {
+	if ci, ok := rt.next.(closeIdler); ok {
+		ci.CloseIdleConnections()
+	}
+}
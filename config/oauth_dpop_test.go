@@ -0,0 +1,224 @@
+// Copyright 2026 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package config
+
+import (
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"fmt"
+	"io"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/stretchr/testify/require"
+)
+
+// verifyDPoPProof verifies the DPoP proof of r and returns its claims and the
+// JWK of the key that signed it.
+func verifyDPoPProof(t *testing.T, r *http.Request) (jwt.MapClaims, map[string]any) {
+	var jwk map[string]any
+	claims := jwt.MapClaims{}
+	_, err := jwt.ParseWithClaims(r.Header.Get("DPoP"), claims, func(token *jwt.Token) (any, error) {
+		require.Equal(t, "dpop+jwt", token.Header["typ"])
+		jwk = token.Header["jwk"].(map[string]any)
+		b64 := func(k string) []byte {
+			b, err := base64.RawURLEncoding.DecodeString(jwk[k].(string))
+			require.NoError(t, err)
+			return b
+		}
+		switch jwk["kty"] {
+		case "EC":
+			return &ecdsa.PublicKey{Curve: elliptic.P256(), X: new(big.Int).SetBytes(b64("x")), Y: new(big.Int).SetBytes(b64("y"))}, nil
+		case "OKP":
+			return ed25519.PublicKey(b64("x")), nil
+		}
+		return nil, fmt.Errorf("unexpected key type %v", jwk["kty"])
+	})
+	require.NoError(t, err)
+	require.Equal(t, r.Method, claims["htm"])
+	require.Equal(t, "http://"+r.Host+r.URL.Path, claims["htu"])
+	require.NotEmpty(t, claims["jti"])
+	return claims, jwk
+}
+
+func TestOAuth2DPoP(t *testing.T) {
+	var tokenRequests int
+	tokenTS := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		tokenRequests++
+		claims, _ := verifyDPoPProof(t, r)
+		require.NotContains(t, claims, "ath")
+		w.Header().Add("Content-Type", "application/json")
+		if claims["nonce"] != "as-nonce" {
+			w.Header().Set("DPoP-Nonce", "as-nonce")
+			w.WriteHeader(http.StatusBadRequest)
+			fmt.Fprint(w, `{"error":"use_dpop_nonce"}`)
+			return
+		}
+		fmt.Fprint(w, `{"access_token":"12345","token_type":"DPoP","expires_in":3600}`)
+	}))
+	defer tokenTS.Close()
+
+	var (
+		requests int
+		bodies   []string
+		jwks     []map[string]any
+	)
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		require.Equal(t, "DPoP 12345", r.Header.Get("Authorization"))
+		claims, jwk := verifyDPoPProof(t, r)
+		jwks = append(jwks, jwk)
+		ath := sha256.Sum256([]byte("12345"))
+		require.Equal(t, base64.RawURLEncoding.EncodeToString(ath[:]), claims["ath"])
+		if claims["nonce"] != "rs-nonce" {
+			w.Header().Set("DPoP-Nonce", "rs-nonce")
+			w.Header().Set("WWW-Authenticate", `DPoP error="use_dpop_nonce"`)
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		b, _ := io.ReadAll(r.Body)
+		bodies = append(bodies, string(b))
+	}))
+	defer ts.Close()
+
+	cfg, err := LoadHTTPConfig(fmt.Sprintf(`
+oauth2:
+  client_id: client
+  client_secret: secret
+  token_url: %s
+  dpop: {}
+`, tokenTS.URL))
+	require.NoError(t, err)
+	client, err := NewClientFromConfig(*cfg, "test")
+	require.NoError(t, err)
+
+	resp, err := client.Post(ts.URL+"/path?query=1", "text/plain", strings.NewReader("body"))
+	require.NoError(t, err)
+	resp.Body.Close()
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+
+	resp, err = client.Get(ts.URL)
+	require.NoError(t, err)
+	resp.Body.Close()
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+
+	// The nonces are remembered, the first request of each server is retried.
+	require.Equal(t, 2, tokenRequests)
+	require.Equal(t, 3, requests)
+	require.Equal(t, []string{"body", ""}, bodies)
+	// The generated key is reused.
+	require.Equal(t, jwks[0], jwks[2])
+}
+
+func TestOAuth2DPoPKey(t *testing.T) {
+	_, key, err := ed25519.GenerateKey(rand.Reader)
+	require.NoError(t, err)
+	der, err := x509.MarshalPKCS8PrivateKey(key)
+	require.NoError(t, err)
+	keyPEM := pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der})
+
+	tokenTS := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, jwk := verifyDPoPProof(t, r)
+		require.Equal(t, "Ed25519", jwk["crv"])
+		require.Equal(t, base64.RawURLEncoding.EncodeToString(key.Public().(ed25519.PublicKey)), jwk["x"])
+		w.Header().Add("Content-Type", "application/json")
+		fmt.Fprint(w, `{"access_token":"12345","token_type":"DPoP"}`)
+	}))
+	defer tokenTS.Close()
+
+	ts := httptest.NewServer(http.HandlerFunc(func(_ http.ResponseWriter, r *http.Request) {
+		verifyDPoPProof(t, r)
+	}))
+	defer ts.Close()
+
+	cfg := HTTPClientConfig{OAuth2: &OAuth2{
+		ClientID:     "client",
+		ClientSecret: "secret",
+		TokenURL:     tokenTS.URL,
+		DPoP:         &DPoPConfig{PrivateKey: Secret(keyPEM)},
+	}}
+	client, err := NewClientFromConfig(cfg, "test")
+	require.NoError(t, err)
+	resp, err := client.Get(ts.URL)
+	require.NoError(t, err)
+	resp.Body.Close()
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+
+	cfg.OAuth2.DPoP.PrivateKey = "invalid"
+	client, err = NewClientFromConfig(cfg, "test")
+	require.NoError(t, err)
+	_, err = client.Get(ts.URL)
+	require.ErrorContains(t, err, "dpop private key must be a PEM encoded ECDSA, RSA or Ed25519 key")
+}
+
+func TestOAuth2DPoPBearerFallback(t *testing.T) {
+	tokenTS := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		verifyDPoPProof(t, r)
+		w.Header().Add("Content-Type", "application/json")
+		fmt.Fprint(w, `{"access_token":"12345","token_type":"Bearer"}`)
+	}))
+	defer tokenTS.Close()
+
+	// The server did not bind the token to the key, so it is used as a
+	// bearer token.
+	ts := httptest.NewServer(http.HandlerFunc(func(_ http.ResponseWriter, r *http.Request) {
+		require.Equal(t, "Bearer 12345", r.Header.Get("Authorization"))
+		require.Empty(t, r.Header.Get("DPoP"))
+	}))
+	defer ts.Close()
+
+	cfg := HTTPClientConfig{OAuth2: &OAuth2{
+		ClientID:     "client",
+		ClientSecret: "secret",
+		TokenURL:     tokenTS.URL,
+		DPoP:         &DPoPConfig{},
+	}}
+	client, err := NewClientFromConfig(cfg, "test")
+	require.NoError(t, err)
+	resp, err := client.Get(ts.URL)
+	require.NoError(t, err)
+	resp.Body.Close()
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+}
+
+func TestOAuth2DPoPValidate(t *testing.T) {
+	_, err := LoadHTTPConfig(`
+oauth2:
+  client_id: client
+  token_url: http://localhost
+  token_cache_file: tokens.json
+  dpop: {}
+`)
+	require.EqualError(t, err, "oauth2 token_cache_file requires a dpop private key, as generated keys do not survive restarts")
+
+	_, err = LoadHTTPConfig(`
+oauth2:
+  client_id: client
+  token_url: http://localhost
+  dpop:
+    private_key: key
+    private_key_file: key.pem
+`)
+	require.EqualError(t, err, "at most one of oauth2 dpop private_key, private_key_file & private_key_ref must be configured")
+}
@@ -0,0 +1,112 @@
+// Copyright 2026 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package config
+
+import (
+	"context"
+	"fmt"
+	"sync"
+
+	"golang.org/x/oauth2"
+)
+
+// refreshTokenConfig is the configuration of the refresh_token grant
+// (RFC 6749 section 6).
+type refreshTokenConfig struct {
+	oauth2.Config
+
+	// RefreshToken is the initial refresh token.
+	RefreshToken string
+	// OnRotate, if not nil, is called when the server issues a new refresh
+	// token.
+	OnRotate func(refreshToken string) error
+}
+
+// TokenSource returns a token source refreshing the access token with the
+// latest refresh token issued by the server.
+func (c *refreshTokenConfig) TokenSource(ctx context.Context) oauth2.TokenSource {
+	return &rotatingTokenSource{
+		src:          c.Config.TokenSource(ctx, &oauth2.Token{RefreshToken: c.RefreshToken}),
+		refreshToken: c.RefreshToken,
+		onRotate:     c.OnRotate,
+	}
+}
+
+// rotatingTokenSource reports the refresh tokens rotated by the server.
+type rotatingTokenSource struct {
+	src      oauth2.TokenSource
+	onRotate func(string) error
+
+	mtx          sync.Mutex
+	refreshToken string
+}
+
+// Token implements oauth2.TokenSource.
+func (s *rotatingTokenSource) Token() (*oauth2.Token, error) {
+	t, err := s.src.Token()
+	if err != nil {
+		return nil, err
+	}
+
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+	if t.RefreshToken == "" || t.RefreshToken == s.refreshToken {
+		return t, nil
+	}
+	s.refreshToken = t.RefreshToken
+	if s.onRotate != nil {
+		if err := s.onRotate(t.RefreshToken); err != nil {
+			return nil, err
+		}
+	}
+	return t, nil
+}
+
+// refreshTokenClientSecret returns the optional client secret used with the
+// refresh_token grant.
+func (rt *oauth2RoundTripper) refreshTokenClientSecret(ctx context.Context) (string, error) {
+	if rt.config.ClientSecret == "" && rt.config.ClientSecretFile == "" && rt.config.ClientSecretRef == "" {
+		return "", nil
+	}
+	secret, err := toSecret(rt.opts.secretManager, rt.config.ClientSecret, rt.config.ClientSecretFile, rt.config.ClientSecretRef)
+	if err != nil {
+		return "", fmt.Errorf("unable to use client secret: %w", err)
+	}
+	clientSecret, err := secret.Fetch(ctx)
+	if err != nil {
+		return "", fmt.Errorf("unable to read oauth2 client secret: %w", err)
+	}
+	return clientSecret, nil
+}
+
+// persistRefreshToken writes a rotated refresh token to the refresh token
+// file. The file is written before the token is recorded as the current
+// credential, and both happen under the lock, so that a concurrent request
+// never sees a current credential that differs from the file and rebuilds the
+// token source with a refresh token the server has already revoked.
+//
+// Refresh tokens configured inline or through the secret manager are not
+// persisted: their rotations are only kept in memory.
+func (rt *oauth2RoundTripper) persistRefreshToken(refreshToken string) error {
+	if rt.config.RefreshTokenFile == "" {
+		return nil
+	}
+	rt.mtx.Lock()
+	defer rt.mtx.Unlock()
+	if err := writeFileAtomic(rt.config.RefreshTokenFile, []byte(refreshToken+"\n"), 0o600); err != nil {
+		return fmt.Errorf("unable to persist rotated oauth2 refresh token: %w", err)
+	}
+	rt.lastSecret = refreshToken
+	return nil
+}
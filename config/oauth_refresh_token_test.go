@@ -0,0 +1,289 @@
+// Copyright 2026 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package config
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+// newRotatingTokenServer returns a token server for the refresh_token grant
+// that rotates the refresh token on every use. Access tokens expire
+// immediately so that every request refreshes them.
+func newRotatingTokenServer(t *testing.T, initial string) (*httptest.Server, func(string)) {
+	var (
+		mtx     sync.Mutex
+		current = initial
+		n       int
+	)
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		require.NoError(t, r.ParseForm())
+		require.Equal(t, "refresh_token", r.PostForm.Get("grant_type"))
+		mtx.Lock()
+		defer mtx.Unlock()
+		if r.PostForm.Get("refresh_token") != current {
+			w.Header().Add("Content-Type", "application/json")
+			w.WriteHeader(http.StatusBadRequest)
+			fmt.Fprint(w, `{"error":"invalid_grant"}`)
+			return
+		}
+		n++
+		current = fmt.Sprintf("refresh-%d", n)
+		w.Header().Add("Content-Type", "application/json")
+		fmt.Fprintf(w, `{"access_token":"access-%d","token_type":"Bearer","refresh_token":%q,"expires_in":1}`, n, current)
+	}))
+	return ts, func(s string) {
+		mtx.Lock()
+		current = s
+		mtx.Unlock()
+	}
+}
+
+func TestOAuth2RefreshTokenRotation(t *testing.T) {
+	tokenTS, setCurrent := newRotatingTokenServer(t, "initial")
+	defer tokenTS.Close()
+
+	var auths []string
+	ts := httptest.NewServer(http.HandlerFunc(func(_ http.ResponseWriter, r *http.Request) {
+		auths = append(auths, r.Header.Get("Authorization"))
+	}))
+	defer ts.Close()
+
+	refreshTokenFile := filepath.Join(t.TempDir(), "refresh_token")
+	require.NoError(t, os.WriteFile(refreshTokenFile, []byte("initial\n"), 0o600))
+
+	cfg, err := LoadHTTPConfig(fmt.Sprintf(`
+oauth2:
+  client_id: client
+  token_url: %s
+  grant_type: refresh_token
+  refresh_token_file: %s
+`, tokenTS.URL, refreshTokenFile))
+	require.NoError(t, err)
+	client, err := NewClientFromConfig(*cfg, "test")
+	require.NoError(t, err)
+
+	get := func() {
+		t.Helper()
+		resp, err := client.Get(ts.URL)
+		require.NoError(t, err)
+		resp.Body.Close()
+	}
+
+	get()
+	get()
+	b, err := os.ReadFile(refreshTokenFile)
+	require.NoError(t, err)
+	require.Equal(t, "refresh-2", strings.TrimSpace(string(b)))
+
+	// A refresh token written to the file by someone else is picked up.
+	setCurrent("external")
+	require.NoError(t, os.WriteFile(refreshTokenFile, []byte("external"), 0o600))
+	get()
+	b, err = os.ReadFile(refreshTokenFile)
+	require.NoError(t, err)
+	require.Equal(t, "refresh-3", strings.TrimSpace(string(b)))
+
+	require.Equal(t, []string{"Bearer access-1", "Bearer access-2", "Bearer access-3"}, auths)
+}
+
+func TestOAuth2RefreshTokenRotationConcurrent(t *testing.T) {
+	tokenTS, _ := newRotatingTokenServer(t, "initial")
+	defer tokenTS.Close()
+
+	ts := httptest.NewServer(http.HandlerFunc(func(http.ResponseWriter, *http.Request) {}))
+	defer ts.Close()
+
+	refreshTokenFile := filepath.Join(t.TempDir(), "refresh_token")
+	require.NoError(t, os.WriteFile(refreshTokenFile, []byte("initial\n"), 0o600))
+
+	cfg := HTTPClientConfig{OAuth2: &OAuth2{
+		ClientID:         "client",
+		TokenURL:         tokenTS.URL,
+		GrantType:        grantTypeRefreshToken,
+		RefreshTokenFile: refreshTokenFile,
+	}}
+	client, err := NewClientFromConfig(cfg, "test")
+	require.NoError(t, err)
+
+	// Every request rotates the refresh token while the others read the
+	// refresh token file. None of them may fall back to a revoked token.
+	var wg sync.WaitGroup
+	errs := make(chan error, 8*20)
+	for range 8 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for range 20 {
+				resp, err := client.Get(ts.URL)
+				if err != nil {
+					errs <- err
+					return
+				}
+				resp.Body.Close()
+			}
+		}()
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		require.NoError(t, err)
+	}
+
+	// The rotated token only becomes the current credential once it is in
+	// the file.
+	rt := &oauth2RoundTripper{
+		config:     &OAuth2{RefreshTokenFile: filepath.Join(t.TempDir(), "missing", "refresh_token")},
+		lastSecret: "old",
+	}
+	require.Error(t, rt.persistRefreshToken("new"))
+	require.Equal(t, "old", rt.lastSecret)
+}
+
+// hookedSecret reads a file and calls hook before every read.
+type hookedSecret struct {
+	file string
+	hook func()
+}
+
+func (s *hookedSecret) Fetch(context.Context) (string, error) {
+	if s.hook != nil {
+		s.hook()
+	}
+	b, err := os.ReadFile(s.file)
+	return strings.TrimSpace(string(b)), err
+}
+
+func (*hookedSecret) Description() string { return "hooked" }
+
+func (*hookedSecret) Immutable() bool { return false }
+
+func TestOAuth2RefreshTokenRotationDuringRequest(t *testing.T) {
+	tokenTS, _ := newRotatingTokenServer(t, "initial")
+	defer tokenTS.Close()
+
+	ts := httptest.NewServer(http.HandlerFunc(func(http.ResponseWriter, *http.Request) {}))
+	defer ts.Close()
+
+	refreshTokenFile := filepath.Join(t.TempDir(), "refresh_token")
+	require.NoError(t, os.WriteFile(refreshTokenFile, []byte("initial\n"), 0o600))
+	secret := &hookedSecret{file: refreshTokenFile}
+	rt := NewOAuth2RoundTripper(secret, &OAuth2{
+		ClientID:         "client",
+		TokenURL:         tokenTS.URL,
+		GrantType:        grantTypeRefreshToken,
+		RefreshTokenFile: refreshTokenFile,
+	}, http.DefaultTransport).(*oauth2RoundTripper)
+	client := &http.Client{Transport: rt}
+
+	get := func() {
+		t.Helper()
+		resp, err := client.Get(ts.URL)
+		require.NoError(t, err)
+		resp.Body.Close()
+	}
+	get()
+	source := rt.lastRT.Source
+
+	// Another request rotates the refresh token after this one has read the
+	// current credential, but before it reads the file.
+	secret.hook = func() {
+		secret.hook = nil
+		_, err := source.Token()
+		require.NoError(t, err)
+	}
+	get()
+	// The token source holding the rotated refresh token is kept.
+	require.True(t, source == rt.lastRT.Source)
+
+	// Concurrent requests keep working across the rotations.
+	var wg sync.WaitGroup
+	errs := make(chan error, 4*10)
+	for range 4 {
+		wg.Go(func() {
+			for range 10 {
+				resp, err := client.Get(ts.URL)
+				if err != nil {
+					errs <- err
+					return
+				}
+				resp.Body.Close()
+			}
+		})
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		require.NoError(t, err)
+	}
+}
+
+func TestOAuth2RefreshTokenInline(t *testing.T) {
+	tokenTS, _ := newRotatingTokenServer(t, "initial")
+	defer tokenTS.Close()
+
+	ts := httptest.NewServer(http.HandlerFunc(func(_ http.ResponseWriter, r *http.Request) {
+		require.True(t, strings.HasPrefix(r.Header.Get("Authorization"), "Bearer access-"))
+	}))
+	defer ts.Close()
+
+	cfg := HTTPClientConfig{OAuth2: &OAuth2{
+		ClientID:     "client",
+		TokenURL:     tokenTS.URL,
+		GrantType:    grantTypeRefreshToken,
+		RefreshToken: "initial",
+	}}
+	client, err := NewClientFromConfig(cfg, "test")
+	require.NoError(t, err)
+
+	// The rotated refresh tokens are kept in memory.
+	for range 3 {
+		resp, err := client.Get(ts.URL)
+		require.NoError(t, err)
+		resp.Body.Close()
+		require.Equal(t, http.StatusOK, resp.StatusCode)
+	}
+}
+
+func TestOAuth2RefreshTokenValidate(t *testing.T) {
+	for _, tc := range []struct {
+		config string
+		errMsg string
+	}{
+		{
+			config: "oauth2:\n  client_id: client\n  token_url: http://localhost\n  grant_type: refresh_token\n",
+			errMsg: "exactly one of oauth2 refresh_token, refresh_token_file & refresh_token_ref must be configured using grant-type=refresh_token",
+		},
+		{
+			config: "oauth2:\n  client_id: client\n  token_url: http://localhost\n  grant_type: refresh_token\n  refresh_token: a\n  refresh_token_ref: b\n",
+			errMsg: "exactly one of oauth2 refresh_token, refresh_token_file & refresh_token_ref must be configured using grant-type=refresh_token",
+		},
+		{
+			config: "oauth2:\n  client_id: client\n  token_url: http://localhost\n  refresh_token: a\n",
+			errMsg: "oauth2 refresh_token, refresh_token_file & refresh_token_ref can only be configured using grant-type=refresh_token",
+		},
+	} {
+		_, err := LoadHTTPConfig(tc.config)
+		require.EqualError(t, err, tc.errMsg)
+	}
+}
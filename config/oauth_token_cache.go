@@ -0,0 +1,237 @@
+// Copyright 2026 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package config
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+
+	"golang.org/x/oauth2"
+)
+
+const (
+	// tokenCacheLockTimeout is how long to wait for the lock of a token cache
+	// file held by another process.
+	tokenCacheLockTimeout = 10 * time.Second
+	// tokenCacheStaleLock is the age after which a lock is considered to be
+	// left over by a crashed process and is removed.
+	tokenCacheStaleLock = 30 * time.Second
+)
+
+// cachedToken is the on-disk representation of an OAuth2 token.
+type cachedToken struct {
+	AccessToken  string    `json:"access_token"`
+	TokenType    string    `json:"token_type,omitempty"`
+	RefreshToken string    `json:"refresh_token,omitempty"`
+	Expiry       time.Time `json:"expiry,omitzero"`
+}
+
+// fileTokenSource is an oauth2.TokenSource caching the tokens of another
+// source in a file, so that they survive restarts. A single file can be shared
+// by several clients and processes: the tokens are keyed by the settings of
+// the token request and a hash of the client credential, and updates are
+// serialized with a lock file. Tokens without an expiry are not cached, as
+// they could otherwise be reused forever, even after being revoked.
+type fileTokenSource struct {
+	path string
+	key  string
+	src  oauth2.TokenSource
+}
+
+// newFileTokenSource returns a token source caching the tokens of src in the
+// file at path for the given OAuth2 configuration and client credential.
+func newFileTokenSource(path string, cfg *OAuth2, credential string, src oauth2.TokenSource) oauth2.TokenSource {
+	return oauth2.ReuseTokenSource(nil, &fileTokenSource{
+		path: path,
+		key:  tokenCacheKey(cfg, credential),
+		src:  src,
+	})
+}
+
+// tokenCacheKey returns the key of the tokens of cfg within a cache file. It
+// covers every setting sent to the token endpoint, so that configurations
+// asking for tokens of different audiences or resources never share them.
+func tokenCacheKey(cfg *OAuth2, credential string) string {
+	scopes := slices.Clone(cfg.Scopes)
+	slices.Sort(scopes)
+	// Maps are marshaled with sorted keys.
+	params, _ := json.Marshal(cfg.EndpointParams)
+	claims, _ := json.Marshal(cfg.Claims)
+	credentialHash := sha256.Sum256([]byte(credential))
+	h := sha256.New()
+	for _, s := range []string{
+		cfg.TokenURL, cfg.GrantType, cfg.ClientID, strings.Join(scopes, " "),
+		string(params), cfg.Audience, cfg.Iss, cfg.ClientCertificateKeyID, string(claims),
+		hex.EncodeToString(credentialHash[:]),
+	} {
+		h.Write([]byte(s))
+		h.Write([]byte{0})
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// Token implements oauth2.TokenSource.
+func (s *fileTokenSource) Token() (*oauth2.Token, error) {
+	// Look for a valid token without locking first, the cache file is always
+	// replaced atomically.
+	if t := s.lookup(); t != nil {
+		return t, nil
+	}
+
+	unlock, err := lockTokenCache(s.path)
+	if err != nil {
+		return nil, err
+	}
+	defer unlock()
+
+	// Another process may have fetched a token while we were waiting.
+	if t := s.lookup(); t != nil {
+		return t, nil
+	}
+	t, err := s.src.Token()
+	if err != nil {
+		return nil, err
+	}
+	if t.Expiry.IsZero() {
+		return t, nil
+	}
+	if err := s.store(t); err != nil {
+		return nil, fmt.Errorf("unable to write oauth2 token cache: %w", err)
+	}
+	return t, nil
+}
+
+// lookup returns the cached token if it is still valid.
+func (s *fileTokenSource) lookup() *oauth2.Token {
+	tokens, err := readTokenCache(s.path)
+	if err != nil {
+		return nil
+	}
+	c, ok := tokens[s.key]
+	if !ok || c.Expiry.IsZero() {
+		return nil
+	}
+	t := &oauth2.Token{
+		AccessToken:  c.AccessToken,
+		TokenType:    c.TokenType,
+		RefreshToken: c.RefreshToken,
+		Expiry:       c.Expiry,
+	}
+	if !t.Valid() {
+		return nil
+	}
+	return t
+}
+
+// store writes t to the cache file. The caller must hold the lock.
+func (s *fileTokenSource) store(t *oauth2.Token) error {
+	tokens, err := readTokenCache(s.path)
+	if err != nil {
+		// Replace unreadable or corrupted caches.
+		tokens = map[string]cachedToken{}
+	}
+	now := time.Now()
+	for k, c := range tokens {
+		if c.Expiry.IsZero() || c.Expiry.Before(now) {
+			delete(tokens, k)
+		}
+	}
+	tokens[s.key] = cachedToken{
+		AccessToken:  t.AccessToken,
+		TokenType:    t.TokenType,
+		RefreshToken: t.RefreshToken,
+		Expiry:       t.Expiry,
+	}
+	b, err := json.Marshal(tokens)
+	if err != nil {
+		return err
+	}
+	return writeFileAtomic(s.path, b, 0o600)
+}
+
+// readTokenCache returns the tokens stored in the cache file at path.
+func readTokenCache(path string) (map[string]cachedToken, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var tokens map[string]cachedToken
+	if err := json.Unmarshal(b, &tokens); err != nil {
+		return nil, err
+	}
+	if tokens == nil {
+		tokens = map[string]cachedToken{}
+	}
+	return tokens, nil
+}
+
+// lockTokenCache acquires the lock file of the cache file at path and returns
+// a function releasing it.
+func lockTokenCache(path string) (func(), error) {
+	lock := path + ".lock"
+	deadline := time.Now().Add(tokenCacheLockTimeout)
+	for {
+		f, err := os.OpenFile(lock, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0o600)
+		if err == nil {
+			f.Close()
+			return func() { os.Remove(lock) }, nil
+		}
+		if !errors.Is(err, fs.ErrExist) {
+			return nil, fmt.Errorf("unable to lock oauth2 token cache: %w", err)
+		}
+		if fi, err := os.Stat(lock); err == nil && time.Since(fi.ModTime()) > tokenCacheStaleLock {
+			os.Remove(lock)
+			continue
+		}
+		if time.Now().After(deadline) {
+			return nil, fmt.Errorf("timeout waiting for oauth2 token cache lock %s", lock)
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+}
+
+// writeFileAtomic writes data to a temporary file and renames it to path, so
+// that readers never observe a partially written file.
+func writeFileAtomic(path string, data []byte, perm os.FileMode) error {
+	f, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(f.Name())
+	if _, err := f.Write(data); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Chmod(perm); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Sync(); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	return os.Rename(f.Name(), path)
+}
@@ -0,0 +1,179 @@
+// Copyright 2026 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package config
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"runtime"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"golang.org/x/oauth2"
+)
+
+func TestOAuth2TokenCacheFile(t *testing.T) {
+	var tokenRequests atomic.Int32
+	tokenTS := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		n := tokenRequests.Add(1)
+		w.Header().Add("Content-Type", "application/json")
+		fmt.Fprintf(w, `{"access_token":"token-%d","token_type":"Bearer","expires_in":3600}`, n)
+	}))
+	defer tokenTS.Close()
+
+	var auths []string
+	ts := httptest.NewServer(http.HandlerFunc(func(_ http.ResponseWriter, r *http.Request) {
+		auths = append(auths, r.Header.Get("Authorization"))
+	}))
+	defer ts.Close()
+
+	cacheFile := filepath.Join(t.TempDir(), "tokens.json")
+	get := func(scopes ...string) {
+		t.Helper()
+		cfg := HTTPClientConfig{OAuth2: &OAuth2{
+			ClientID:       "client",
+			ClientSecret:   "secret",
+			TokenURL:       tokenTS.URL,
+			Scopes:         scopes,
+			TokenCacheFile: cacheFile,
+		}}
+		client, err := NewClientFromConfig(cfg, "test")
+		require.NoError(t, err)
+		resp, err := client.Get(ts.URL)
+		require.NoError(t, err)
+		resp.Body.Close()
+	}
+
+	// A new client, as after a restart, reuses the cached token.
+	get("a", "b")
+	get("b", "a")
+	// Other scopes need another token.
+	get("c")
+	get("c")
+
+	require.Equal(t, []string{"Bearer token-1", "Bearer token-1", "Bearer token-2", "Bearer token-2"}, auths)
+	require.EqualValues(t, 2, tokenRequests.Load())
+
+	if runtime.GOOS != "windows" {
+		fi, err := os.Stat(cacheFile)
+		require.NoError(t, err)
+		require.Equal(t, os.FileMode(0o600), fi.Mode().Perm())
+	}
+}
+
+type countingTokenSource struct {
+	mtx    sync.Mutex
+	n      int
+	expiry time.Time
+}
+
+func (s *countingTokenSource) Token() (*oauth2.Token, error) {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+	s.n++
+	return &oauth2.Token{AccessToken: fmt.Sprintf("token-%d", s.n), Expiry: s.expiry}, nil
+}
+
+func TestFileTokenSourceExpiry(t *testing.T) {
+	cacheFile := filepath.Join(t.TempDir(), "tokens.json")
+	cfg := &OAuth2{ClientID: "client", TokenURL: "http://localhost/token"}
+	src := &countingTokenSource{expiry: time.Now().Add(time.Second)}
+
+	// The token expires within the expiry delta of oauth2 and is never reused.
+	tok, err := newFileTokenSource(cacheFile, cfg, "secret", src).Token()
+	require.NoError(t, err)
+	require.Equal(t, "token-1", tok.AccessToken)
+	tok, err = newFileTokenSource(cacheFile, cfg, "secret", src).Token()
+	require.NoError(t, err)
+	require.Equal(t, "token-2", tok.AccessToken)
+
+	src.expiry = time.Now().Add(time.Hour)
+	tok, err = newFileTokenSource(cacheFile, cfg, "secret", src).Token()
+	require.NoError(t, err)
+	require.Equal(t, "token-3", tok.AccessToken)
+	tok, err = newFileTokenSource(cacheFile, cfg, "secret", src).Token()
+	require.NoError(t, err)
+	require.Equal(t, "token-3", tok.AccessToken)
+
+	// A corrupted cache is replaced.
+	require.NoError(t, os.WriteFile(cacheFile, []byte("{"), 0o600))
+	tok, err = newFileTokenSource(cacheFile, cfg, "secret", src).Token()
+	require.NoError(t, err)
+	require.Equal(t, "token-4", tok.AccessToken)
+}
+
+func TestFileTokenSourceKey(t *testing.T) {
+	cacheFile := filepath.Join(t.TempDir(), "tokens.json")
+	src := &countingTokenSource{expiry: time.Now().Add(time.Hour)}
+	token := func(cfg *OAuth2, credential string) string {
+		t.Helper()
+		tok, err := newFileTokenSource(cacheFile, cfg, credential, src).Token()
+		require.NoError(t, err)
+		return tok.AccessToken
+	}
+
+	cfg := &OAuth2{ClientID: "client", TokenURL: "http://localhost/token", EndpointParams: map[string]string{"audience": "a"}}
+	require.Equal(t, "token-1", token(cfg, "secret"))
+	require.Equal(t, "token-1", token(cfg, "secret"))
+	// Another audience or another client secret needs another token.
+	require.Equal(t, "token-2", token(&OAuth2{ClientID: "client", TokenURL: "http://localhost/token", EndpointParams: map[string]string{"audience": "b"}}, "secret"))
+	require.Equal(t, "token-3", token(cfg, "other"))
+	require.Equal(t, "token-1", token(cfg, "secret"))
+}
+
+func TestFileTokenSourceNoExpiry(t *testing.T) {
+	cacheFile := filepath.Join(t.TempDir(), "tokens.json")
+	cfg := &OAuth2{ClientID: "client", TokenURL: "http://localhost/token"}
+	src := &countingTokenSource{}
+
+	// Tokens without expiry are not persisted, so that a revoked token is not
+	// reused after a restart.
+	tok, err := newFileTokenSource(cacheFile, cfg, "secret", src).Token()
+	require.NoError(t, err)
+	require.Equal(t, "token-1", tok.AccessToken)
+	require.NoFileExists(t, cacheFile)
+	tok, err = newFileTokenSource(cacheFile, cfg, "secret", src).Token()
+	require.NoError(t, err)
+	require.Equal(t, "token-2", tok.AccessToken)
+
+	// Entries without expiry written before are ignored.
+	require.NoError(t, os.WriteFile(cacheFile, []byte(`{"`+tokenCacheKey(cfg, "secret")+`":{"access_token":"stale"}}`), 0o600))
+	tok, err = newFileTokenSource(cacheFile, cfg, "secret", src).Token()
+	require.NoError(t, err)
+	require.Equal(t, "token-3", tok.AccessToken)
+}
+
+func TestFileTokenSourceConcurrent(t *testing.T) {
+	cacheFile := filepath.Join(t.TempDir(), "tokens.json")
+	cfg := &OAuth2{ClientID: "client", TokenURL: "http://localhost/token"}
+	src := &countingTokenSource{expiry: time.Now().Add(time.Hour)}
+
+	var wg sync.WaitGroup
+	for range 10 {
+		wg.Go(func() {
+			tok, err := newFileTokenSource(cacheFile, cfg, "secret", src).Token()
+			require.NoError(t, err)
+			require.Equal(t, "token-1", tok.AccessToken)
+		})
+	}
+	wg.Wait()
+	require.Equal(t, 1, src.n)
+	require.NoFileExists(t, cacheFile+".lock")
+}
@@ -0,0 +1,153 @@
+// Copyright 2026 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package config
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"path"
+	"strings"
+)
+
+// DefaultRedirectPolicy is the default redirect policy, which forwards the
+// credentials to subdomains like net/http does.
+var DefaultRedirectPolicy = RedirectPolicy{
+	MaxHops: 10,
+}
+
+// RedirectPolicy configures how redirects are followed when follow_redirects
+// is enabled.
+type RedirectPolicy struct {
+	// MaxHops is the maximum number of redirects followed for a request, 10 if
+	// zero.
+	MaxHops int `yaml:"max_hops,omitempty" json:"max_hops,omitempty"`
+	// AllowedHosts are the patterns, as supported by path.Match, of the hosts
+	// the requests can be redirected to. Any host is allowed if empty.
+	AllowedHosts []string `yaml:"allowed_hosts,omitempty" json:"allowed_hosts,omitempty"`
+	// StripAuthOnSubdomains removes the credentials and the sensitive headers
+	// on redirects to subdomains of the original host, so that they are only
+	// kept on redirects to the original host. By default, they are also kept
+	// on redirects to subdomains, like net/http does.
+	StripAuthOnSubdomains bool `yaml:"strip_auth_on_subdomains,omitempty" json:"strip_auth_on_subdomains,omitempty"`
+	// RefuseHTTPSDowngrade fails the requests redirected from HTTPS to HTTP.
+	RefuseHTTPSDowngrade bool `yaml:"refuse_https_downgrade,omitempty" json:"refuse_https_downgrade,omitempty"`
+}
+
+// UnmarshalYAML implements the yaml.Unmarshaler interface.
+func (p *RedirectPolicy) UnmarshalYAML(unmarshal func(any) error) error {
+	*p = DefaultRedirectPolicy
+	type plain RedirectPolicy
+	if err := unmarshal((*plain)(p)); err != nil {
+		return err
+	}
+	return p.Validate()
+}
+
+// UnmarshalJSON implements the json.Unmarshaler interface.
+func (p *RedirectPolicy) UnmarshalJSON(data []byte) error {
+	*p = DefaultRedirectPolicy
+	type plain RedirectPolicy
+	if err := json.Unmarshal(data, (*plain)(p)); err != nil {
+		return err
+	}
+	return p.Validate()
+}
+
+// Validate validates the RedirectPolicy.
+func (p *RedirectPolicy) Validate() error {
+	if p == nil {
+		return nil
+	}
+	if p.MaxHops < 0 {
+		return errors.New("redirect_policy max_hops must not be negative")
+	}
+	for _, pattern := range p.AllowedHosts {
+		if _, err := path.Match(pattern, ""); err != nil {
+			return fmt.Errorf("invalid redirect_policy allowed_hosts pattern %q: %w", pattern, err)
+		}
+	}
+	return nil
+}
+
+// checkRedirect returns an error if req, the redirect following the requests
+// of via, is not allowed.
+func (p *RedirectPolicy) checkRedirect(req *http.Request, via []*http.Request) error {
+	maxHops := p.MaxHops
+	if maxHops == 0 {
+		maxHops = DefaultRedirectPolicy.MaxHops
+	}
+	if len(via) > maxHops {
+		return fmt.Errorf("stopped after %d redirects", maxHops)
+	}
+	if len(p.AllowedHosts) > 0 && !p.allowsHost(req.URL.Hostname()) {
+		return fmt.Errorf("redirect to host %q is not allowed", req.URL.Hostname())
+	}
+	if p.RefuseHTTPSDowngrade && req.URL.Scheme == "http" && via[len(via)-1].URL.Scheme == "https" {
+		return errors.New("redirect from HTTPS to HTTP is not allowed")
+	}
+	return nil
+}
+
+func (p *RedirectPolicy) allowsHost(host string) bool {
+	host = strings.ToLower(host)
+	for _, pattern := range p.AllowedHosts {
+		if ok, _ := path.Match(strings.ToLower(pattern), host); ok {
+			return true
+		}
+	}
+	return false
+}
+
+type redirectPolicyKey struct{}
+
+// redirectPolicyFromContext returns the redirect policy applying to the
+// request, which is the default one for round trippers not built from an
+// HTTPClientConfig.
+func redirectPolicyFromContext(ctx context.Context) *RedirectPolicy {
+	if p, ok := ctx.Value(redirectPolicyKey{}).(*RedirectPolicy); ok {
+		return p
+	}
+	return &DefaultRedirectPolicy
+}
+
+// redirectPolicyRoundTripper enforces the redirect policy and makes it
+// available to the inner round trippers, which decide from it whether the
+// credentials are forwarded. As it relies on the req.Response chain, it also
+// applies to clients not built with NewClientFromConfig.
+type redirectPolicyRoundTripper struct {
+	policy *RedirectPolicy
+	next   http.RoundTripper
+}
+
+func (rt *redirectPolicyRoundTripper) RoundTrip(req *http.Request) (*http.Response, error) {
+	var via []*http.Request
+	for r := req; r.Response != nil && r.Response.Request != nil; r = r.Response.Request {
+		via = append([]*http.Request{r.Response.Request}, via...)
+	}
+	if len(via) > 0 {
+		if err := rt.policy.checkRedirect(req, via); err != nil {
+			return nil, err
+		}
+	}
+	return rt.next.RoundTrip(req.WithContext(context.WithValue(req.Context(), redirectPolicyKey{}, rt.policy)))
+}
+
+func (rt *redirectPolicyRoundTripper) CloseIdleConnections() {
+	if ci, ok := rt.next.(closeIdler); ok {
+		ci.CloseIdleConnections()
+	}
+}
@@ -0,0 +1,191 @@
+// Copyright 2026 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package config
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestRedirectPolicyMaxHops(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n, _ := strconv.Atoi(strings.TrimPrefix(r.URL.Path, "/"))
+		if n < 12 {
+			http.Redirect(w, r, "/"+strconv.Itoa(n+1), http.StatusFound)
+		}
+	}))
+	defer ts.Close()
+
+	get := func(maxHops int) error {
+		cfg := DefaultHTTPClientConfig
+		cfg.RedirectPolicy = &RedirectPolicy{MaxHops: maxHops}
+		client, err := NewClientFromConfig(cfg, "test")
+		require.NoError(t, err)
+		resp, err := client.Get(ts.URL + "/0")
+		if err != nil {
+			return err
+		}
+		resp.Body.Close()
+		return nil
+	}
+
+	require.ErrorContains(t, get(3), "stopped after 3 redirects")
+	require.ErrorContains(t, get(0), "stopped after 10 redirects")
+	// More hops than the default limit of net/http.
+	require.NoError(t, get(12))
+}
+
+func TestRedirectPolicyAllowedHosts(t *testing.T) {
+	target := httptest.NewServer(http.HandlerFunc(func(http.ResponseWriter, *http.Request) {}))
+	defer target.Close()
+	targetURL := fmt.Sprintf("http://localhost:%d/", target.Listener.Addr().(*net.TCPAddr).Port)
+
+	origin := httptest.NewServer(http.RedirectHandler(targetURL, http.StatusFound))
+	defer origin.Close()
+
+	for _, tc := range []struct {
+		allowedHosts []string
+		errMsg       string
+	}{
+		{allowedHosts: []string{"LOCAL*"}},
+		{allowedHosts: []string{"127.0.0.1", "*.localhost"}, errMsg: `redirect to host "localhost" is not allowed`},
+	} {
+		cfg := DefaultHTTPClientConfig
+		cfg.RedirectPolicy = &RedirectPolicy{AllowedHosts: tc.allowedHosts}
+		// Clients not built with NewClientFromConfig apply the policy too.
+		rt, err := NewRoundTripperFromConfig(cfg, "test")
+		require.NoError(t, err)
+		resp, err := (&http.Client{Transport: rt}).Get(origin.URL)
+		if tc.errMsg != "" {
+			require.ErrorContains(t, err, tc.errMsg)
+			continue
+		}
+		require.NoError(t, err)
+		resp.Body.Close()
+	}
+}
+
+func TestRedirectPolicyHTTPSDowngrade(t *testing.T) {
+	target := httptest.NewServer(http.HandlerFunc(func(http.ResponseWriter, *http.Request) {}))
+	defer target.Close()
+	origin := httptest.NewTLSServer(http.RedirectHandler(target.URL, http.StatusFound))
+	origin.Config.ErrorLog = log.New(io.Discard, "", 0)
+	defer origin.Close()
+
+	for _, refuse := range []bool{false, true} {
+		cfg := DefaultHTTPClientConfig
+		cfg.TLSConfig = TLSConfig{InsecureSkipVerify: true}
+		cfg.RedirectPolicy = &RedirectPolicy{RefuseHTTPSDowngrade: refuse}
+		client, err := NewClientFromConfig(cfg, "test")
+		require.NoError(t, err)
+		resp, err := client.Get(origin.URL)
+		if refuse {
+			require.ErrorContains(t, err, "redirect from HTTPS to HTTP is not allowed")
+			continue
+		}
+		require.NoError(t, err)
+		resp.Body.Close()
+	}
+}
+
+func TestRedirectPolicyStripAuthOnSubdomains(t *testing.T) {
+	var authSeen []string
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		authSeen = append(authSeen, r.Header.Get("Authorization"))
+		if r.Host == "example.org" {
+			http.Redirect(w, r, "http://api.example.org/", http.StatusFound)
+		}
+	}))
+	defer ts.Close()
+	// All the hosts resolve to the test server.
+	dial := func(ctx context.Context, network, _ string) (net.Conn, error) {
+		return (&net.Dialer{}).DialContext(ctx, network, ts.Listener.Addr().String())
+	}
+
+	for _, tc := range []struct {
+		name     string
+		auth     func(*HTTPClientConfig)
+		policy   *RedirectPolicy
+		expected []string
+	}{
+		{
+			name:     "authorization with the default policy",
+			auth:     func(c *HTTPClientConfig) { c.Authorization = &Authorization{Type: "Bearer", Credentials: "token"} },
+			expected: []string{"Bearer token", "Bearer token"},
+		},
+		{
+			name:     "authorization",
+			auth:     func(c *HTTPClientConfig) { c.Authorization = &Authorization{Type: "Bearer", Credentials: "token"} },
+			policy:   &RedirectPolicy{StripAuthOnSubdomains: true},
+			expected: []string{"Bearer token", ""},
+		},
+		{
+			name:     "basic auth",
+			auth:     func(c *HTTPClientConfig) { c.BasicAuth = &BasicAuth{Username: "user", Password: "pass"} },
+			policy:   &RedirectPolicy{StripAuthOnSubdomains: true},
+			expected: []string{"Basic dXNlcjpwYXNz", ""},
+		},
+		{
+			name:     "basic auth with subdomains",
+			auth:     func(c *HTTPClientConfig) { c.BasicAuth = &BasicAuth{Username: "user", Password: "pass"} },
+			policy:   &RedirectPolicy{MaxHops: 3},
+			expected: []string{"Basic dXNlcjpwYXNz", "Basic dXNlcjpwYXNz"},
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			authSeen = nil
+			cfg := DefaultHTTPClientConfig
+			tc.auth(&cfg)
+			cfg.RedirectPolicy = tc.policy
+			client, err := NewClientFromConfig(cfg, "test", WithDialContextFunc(dial))
+			require.NoError(t, err)
+			resp, err := client.Get("http://example.org/")
+			require.NoError(t, err)
+			resp.Body.Close()
+			require.Equal(t, tc.expected, authSeen)
+		})
+	}
+}
+
+func TestRedirectPolicyUnmarshal(t *testing.T) {
+	cfg, err := LoadHTTPConfig("redirect_policy:\n  refuse_https_downgrade: true\n")
+	require.NoError(t, err)
+	require.Equal(t, &RedirectPolicy{MaxHops: 10, RefuseHTTPSDowngrade: true}, cfg.RedirectPolicy)
+
+	// JSON and YAML configurations get the same defaults.
+	var jsonCfg HTTPClientConfig
+	require.NoError(t, json.Unmarshal([]byte(`{"redirect_policy":{"refuse_https_downgrade":true}}`), &jsonCfg))
+	require.Equal(t, cfg.RedirectPolicy, jsonCfg.RedirectPolicy)
+
+	cfg, err = LoadHTTPConfig("redirect_policy:\n  strip_auth_on_subdomains: true\n")
+	require.NoError(t, err)
+	require.Equal(t, &RedirectPolicy{MaxHops: 10, StripAuthOnSubdomains: true}, cfg.RedirectPolicy)
+	jsonCfg = HTTPClientConfig{}
+	require.NoError(t, json.Unmarshal([]byte(`{"redirect_policy":{"strip_auth_on_subdomains":true}}`), &jsonCfg))
+	require.Equal(t, cfg.RedirectPolicy, jsonCfg.RedirectPolicy)
+
+	_, err = LoadHTTPConfig("redirect_policy:\n  allowed_hosts: ['[']\n")
+	require.EqualError(t, err, `invalid redirect_policy allowed_hosts pattern "[": syntax error in pattern`)
+}
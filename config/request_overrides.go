@@ -0,0 +1,114 @@
+// Copyright 2026 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package config
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"slices"
+)
+
+// RequestOverridesConfig lists the per-request overrides, set on the request
+// context with WithRequestHeaders and WithRequestCredentials, that a client
+// honours. This allows a single pooled client to serve several tenants.
+type RequestOverridesConfig struct {
+	// Headers are the names of the headers which can be set per request. They
+	// replace the values of the headers configured in http_headers.
+	Headers []string `yaml:"headers,omitempty" json:"headers,omitempty"`
+	// Credentials allows the authorization credentials to be set per request.
+	Credentials bool `yaml:"credentials,omitempty" json:"credentials,omitempty"`
+}
+
+// Validate validates the RequestOverridesConfig.
+func (c *RequestOverridesConfig) Validate() error {
+	if c == nil {
+		return nil
+	}
+	for _, h := range c.Headers {
+		if _, ok := ReservedHeaders[http.CanonicalHeaderKey(h)]; ok {
+			return fmt.Errorf("overriding header %q is not allowed", http.CanonicalHeaderKey(h))
+		}
+	}
+	return nil
+}
+
+// allowsHeader reports whether the header name can be overridden.
+func (c *RequestOverridesConfig) allowsHeader(name string) bool {
+	return slices.ContainsFunc(c.Headers, func(h string) bool {
+		return http.CanonicalHeaderKey(h) == http.CanonicalHeaderKey(name)
+	})
+}
+
+type requestOverridesKey struct{}
+
+// requestOverrides are the overrides carried by a request context.
+type requestOverrides struct {
+	headers     http.Header
+	credentials *requestCredentials
+}
+
+type requestCredentials struct {
+	authType    string
+	credentials string
+}
+
+func requestOverridesFromContext(ctx context.Context) requestOverrides {
+	o, _ := ctx.Value(requestOverridesKey{}).(requestOverrides)
+	return o
+}
+
+// WithRequestHeaders returns a context whose requests carry the given headers,
+// replacing the configured values. Clients configured with request_overrides
+// fail the requests overriding headers which are not allowed, other clients
+// ignore the overrides.
+func WithRequestHeaders(ctx context.Context, headers http.Header) context.Context {
+	o := requestOverridesFromContext(ctx)
+	merged := o.headers.Clone()
+	if merged == nil {
+		merged = http.Header{}
+	}
+	for name, values := range headers {
+		merged[http.CanonicalHeaderKey(name)] = slices.Clone(values)
+	}
+	o.headers = merged
+	return context.WithValue(ctx, requestOverridesKey{}, o)
+}
+
+// WithRequestCredentials returns a context whose requests are authorized with
+// the given credentials instead of the configured ones. An empty authType
+// keeps the configured type, or "Bearer" if there is none. Clients configured
+// with request_overrides fail the requests if credentials are not allowed,
+// other clients ignore the override.
+func WithRequestCredentials(ctx context.Context, authType, credentials string) context.Context {
+	o := requestOverridesFromContext(ctx)
+	o.credentials = &requestCredentials{authType: authType, credentials: credentials}
+	return context.WithValue(ctx, requestOverridesKey{}, o)
+}
+
+// checkRequestOverrides returns an error if the overrides of ctx are not
+// allowed by cfg.
+func checkRequestOverrides(ctx context.Context, cfg *RequestOverridesConfig) error {
+	o := requestOverridesFromContext(ctx)
+	for name := range o.headers {
+		if !cfg.allowsHeader(name) {
+			return fmt.Errorf("request header override %q is not allowed", name)
+		}
+	}
+	if o.credentials != nil && !cfg.Credentials {
+		return errors.New("request credentials override is not allowed")
+	}
+	return nil
+}
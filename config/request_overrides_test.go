@@ -0,0 +1,133 @@
+// Copyright 2026 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package config
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestRequestOverrides(t *testing.T) {
+	var got http.Header
+	ts := httptest.NewServer(http.HandlerFunc(func(_ http.ResponseWriter, r *http.Request) {
+		got = r.Header
+	}))
+	defer ts.Close()
+
+	cfg, err := LoadHTTPConfig(`
+authorization:
+  credentials: default
+http_headers:
+  X-Scope-OrgID:
+    values: [default]
+request_overrides:
+  headers: [x-scope-orgid]
+  credentials: true
+`)
+	require.NoError(t, err)
+	client, err := NewClientFromConfig(*cfg, "test")
+	require.NoError(t, err)
+
+	get := func(ctx context.Context) error {
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, ts.URL, nil)
+		require.NoError(t, err)
+		resp, err := client.Do(req)
+		if err != nil {
+			return err
+		}
+		resp.Body.Close()
+		return nil
+	}
+
+	require.NoError(t, get(context.Background()))
+	require.Equal(t, []string{"default"}, got.Values("X-Scope-OrgID"))
+	require.Equal(t, "Bearer default", got.Get("Authorization"))
+
+	ctx := WithRequestHeaders(context.Background(), http.Header{"X-Scope-Orgid": {"tenant-a"}})
+	ctx = WithRequestCredentials(ctx, "", "token-a")
+	require.NoError(t, get(ctx))
+	require.Equal(t, []string{"tenant-a"}, got.Values("X-Scope-OrgID"))
+	require.Equal(t, "Bearer token-a", got.Get("Authorization"))
+
+	require.NoError(t, get(WithRequestCredentials(context.Background(), "Custom", "token-b")))
+	require.Equal(t, []string{"default"}, got.Values("X-Scope-OrgID"))
+	require.Equal(t, "Custom token-b", got.Get("Authorization"))
+
+	err = get(WithRequestHeaders(context.Background(), http.Header{"X-Other": {"value"}}))
+	require.ErrorContains(t, err, `request header override "X-Other" is not allowed`)
+}
+
+func TestRequestOverridesCredentialsOnly(t *testing.T) {
+	var got http.Header
+	ts := httptest.NewServer(http.HandlerFunc(func(_ http.ResponseWriter, r *http.Request) {
+		got = r.Header
+	}))
+	defer ts.Close()
+
+	cfg, err := LoadHTTPConfig("request_overrides:\n  credentials: true\n")
+	require.NoError(t, err)
+	client, err := NewClientFromConfig(*cfg, "test")
+	require.NoError(t, err)
+
+	resp, err := client.Get(ts.URL)
+	require.NoError(t, err)
+	resp.Body.Close()
+	require.Empty(t, got.Get("Authorization"))
+
+	req, err := http.NewRequestWithContext(WithRequestCredentials(context.Background(), "", "token"), http.MethodGet, ts.URL, nil)
+	require.NoError(t, err)
+	resp, err = client.Do(req)
+	require.NoError(t, err)
+	resp.Body.Close()
+	require.Equal(t, "Bearer token", got.Get("Authorization"))
+
+	// Headers cannot be overridden.
+	req, err = http.NewRequestWithContext(WithRequestHeaders(context.Background(), http.Header{"X-Scope-OrgID": {"a"}}), http.MethodGet, ts.URL, nil)
+	require.NoError(t, err)
+	_, err = client.Do(req)
+	require.ErrorContains(t, err, `request header override "X-Scope-Orgid" is not allowed`)
+}
+
+func TestRequestOverridesNotConfigured(t *testing.T) {
+	var got http.Header
+	ts := httptest.NewServer(http.HandlerFunc(func(_ http.ResponseWriter, r *http.Request) {
+		got = r.Header
+	}))
+	defer ts.Close()
+
+	cfg, err := LoadHTTPConfig("authorization:\n  credentials: default\n")
+	require.NoError(t, err)
+	client, err := NewClientFromConfig(*cfg, "test")
+	require.NoError(t, err)
+
+	ctx := WithRequestCredentials(context.Background(), "", "token")
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, ts.URL, nil)
+	require.NoError(t, err)
+	resp, err := client.Do(req)
+	require.NoError(t, err)
+	resp.Body.Close()
+	require.Equal(t, "Bearer default", got.Get("Authorization"))
+}
+
+func TestRequestOverridesValidate(t *testing.T) {
+	_, err := LoadHTTPConfig("request_overrides:\n  headers: [authorization]\n")
+	require.EqualError(t, err, `overriding header "Authorization" is not allowed`)
+
+	_, err = LoadHTTPConfig("basic_auth:\n  username: user\nrequest_overrides:\n  credentials: true\n")
+	require.EqualError(t, err, "request_overrides credentials is only compatible with authorization")
+}
@@ -0,0 +1,147 @@
+// Copyright 2026 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package config
+
+import (
+	"compress/gzip"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+)
+
+// ResponseBodyTooLargeError is returned when a response body exceeds the
+// configured maximum size. Use errors.As to detect it.
+type ResponseBodyTooLargeError struct {
+	// Limit is the maximum number of bytes allowed.
+	Limit int64
+}
+
+func (e *ResponseBodyTooLargeError) Error() string {
+	return fmt.Sprintf("response body exceeds the limit of %d bytes", e.Limit)
+}
+
+// NewLimitedReader returns a reader that reads from r and fails with a
+// *ResponseBodyTooLargeError once more than limit bytes have been read.
+func NewLimitedReader(r io.Reader, limit int64) io.Reader {
+	return &limitedReader{r: r, remaining: limit, limit: limit}
+}
+
+type limitedReader struct {
+	r         io.Reader
+	remaining int64
+	limit     int64
+}
+
+func (l *limitedReader) Read(p []byte) (int, error) {
+	if l.remaining < 0 {
+		return 0, &ResponseBodyTooLargeError{Limit: l.limit}
+	}
+	// Read one byte past the limit to tell a body of exactly limit bytes apart
+	// from a larger one.
+	if int64(len(p)) > l.remaining+1 {
+		p = p[:l.remaining+1]
+	}
+	n, err := l.r.Read(p)
+	l.remaining -= int64(n)
+	if l.remaining < 0 {
+		return n + int(l.remaining), &ResponseBodyTooLargeError{Limit: l.limit}
+	}
+	return n, err
+}
+
+type limitedBody struct {
+	io.Reader
+	io.Closer
+}
+
+// NewResponseBodyLimitRoundTripper returns a RoundTripper that fails requests
+// whose response body is larger than limit bytes with a
+// *ResponseBodyTooLargeError, either when the response is received if its
+// Content-Length is known, or when the body is read.
+//
+// Gzip-encoded responses are decompressed, like http.Transport does when it
+// requests compression itself: the Content-Encoding and Content-Length headers
+// are removed, Uncompressed is set, and the limit applies to the compressed
+// bytes received as well as to the decompressed bytes read. Responses with
+// other content encodings are passed through and only their compressed size
+// is limited.
+func NewResponseBodyLimitRoundTripper(limit int64, next http.RoundTripper) http.RoundTripper {
+	return &responseBodyLimitRoundTripper{limit: limit, next: next}
+}
+
+type responseBodyLimitRoundTripper struct {
+	limit int64
+	next  http.RoundTripper
+}
+
+// RoundTrip implements http.RoundTripper.
+func (rt *responseBodyLimitRoundTripper) RoundTrip(req *http.Request) (*http.Response, error) {
+	resp, err := rt.next.RoundTrip(req)
+	if err != nil {
+		return nil, err
+	}
+	if !hasResponseBody(req, resp) {
+		// The Content-Length is that of the body which would have been
+		// sent, if any.
+		return resp, nil
+	}
+	if resp.ContentLength > rt.limit {
+		resp.Body.Close()
+		return nil, &ResponseBodyTooLargeError{Limit: rt.limit}
+	}
+	body := NewLimitedReader(resp.Body, rt.limit)
+	if strings.EqualFold(resp.Header.Get("Content-Encoding"), "gzip") {
+		body = NewLimitedReader(&gzipReader{r: body}, rt.limit)
+		resp.Header.Del("Content-Encoding")
+		resp.Header.Del("Content-Length")
+		resp.ContentLength = -1
+		resp.Uncompressed = true
+	}
+	resp.Body = &limitedBody{
+		Reader: body,
+		Closer: resp.Body,
+	}
+	return resp, nil
+}
+
+// hasResponseBody reports whether resp can have a body.
+func hasResponseBody(req *http.Request, resp *http.Response) bool {
+	return req.Method != http.MethodHead && resp.StatusCode != http.StatusNoContent && resp.StatusCode != http.StatusNotModified
+}
+
+// gzipReader decompresses r lazily, so that the gzip header is not read until
+// the body is.
+type gzipReader struct {
+	r   io.Reader
+	zr  *gzip.Reader
+	err error
+}
+
+func (g *gzipReader) Read(p []byte) (int, error) {
+	if g.zr == nil && g.err == nil {
+		g.zr, g.err = gzip.NewReader(g.r)
+	}
+	if g.err != nil {
+		return 0, g.err
+	}
+	return g.zr.Read(p)
+}
+
+// CloseIdleConnections implements closeIdler.
+func (rt *responseBodyLimitRoundTripper) CloseIdleConnections() {
+	if ci, ok := rt.next.(closeIdler); ok {
+		ci.CloseIdleConnections()
+	}
+}
@@ -0,0 +1,161 @@
+// Copyright 2026 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package config
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestMaxResponseBodyBytes(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body := strings.Repeat("x", 10)
+		if r.URL.Path == "/not-modified" {
+			w.Header().Set("Content-Length", "10")
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		if r.URL.Path == "/chunked" {
+			// Flushing before writing the body prevents the Content-Length
+			// header from being set.
+			w.(http.Flusher).Flush()
+		}
+		_, _ = io.WriteString(w, body)
+	}))
+	defer ts.Close()
+
+	cfg, err := LoadHTTPConfig("max_response_body_bytes: 10\n")
+	require.NoError(t, err)
+	client, err := NewClientFromConfig(*cfg, "test")
+	require.NoError(t, err)
+
+	// A body of exactly the limit is allowed.
+	for _, path := range []string{"/", "/chunked"} {
+		resp, err := client.Get(ts.URL + path)
+		require.NoError(t, err)
+		b, err := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		require.NoError(t, err)
+		require.Len(t, b, 10)
+	}
+
+	cfg.MaxResponseBodyBytes = 9
+	client, err = NewClientFromConfig(*cfg, "test")
+	require.NoError(t, err)
+
+	// The Content-Length is known: the request fails right away.
+	_, err = client.Get(ts.URL)
+	var tooLarge *ResponseBodyTooLargeError
+	require.ErrorAs(t, err, &tooLarge)
+	require.Equal(t, int64(9), tooLarge.Limit)
+
+	// HEAD requests and 304 responses have no body, whatever their
+	// Content-Length.
+	resp, err := client.Head(ts.URL)
+	require.NoError(t, err)
+	resp.Body.Close()
+	require.Equal(t, int64(10), resp.ContentLength)
+	resp, err = client.Get(ts.URL + "/not-modified")
+	require.NoError(t, err)
+	resp.Body.Close()
+	require.Equal(t, http.StatusNotModified, resp.StatusCode)
+
+	// The Content-Length is unknown: reading the body fails.
+	resp, err = client.Get(ts.URL + "/chunked")
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	b, err := io.ReadAll(resp.Body)
+	require.ErrorAs(t, err, &tooLarge)
+	require.Len(t, b, 9)
+}
+
+func TestMaxResponseBodyBytesGzip(t *testing.T) {
+	gzipped := func(n int) []byte {
+		var buf bytes.Buffer
+		gz := gzip.NewWriter(&buf)
+		_, err := gz.Write(bytes.Repeat([]byte{'x'}, n))
+		require.NoError(t, err)
+		require.NoError(t, gz.Close())
+		return buf.Bytes()
+	}
+	small, large := gzipped(1<<10), gzipped(1<<20)
+	require.Less(t, len(large), 1<<16)
+
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Encoding", "gzip")
+		if r.URL.Path == "/large" {
+			_, _ = w.Write(large)
+			return
+		}
+		_, _ = w.Write(small)
+	}))
+	defer ts.Close()
+
+	cfg := HTTPClientConfig{MaxResponseBodyBytes: 1 << 16}
+	client, err := NewClientFromConfig(cfg, "test")
+	require.NoError(t, err)
+
+	get := func(path string) (*http.Response, []byte, error) {
+		req, err := http.NewRequest(http.MethodGet, ts.URL+path, nil)
+		require.NoError(t, err)
+		req.Header.Set("Accept-Encoding", "gzip")
+		resp, err := client.Do(req)
+		require.NoError(t, err)
+		defer resp.Body.Close()
+		b, err := io.ReadAll(resp.Body)
+		return resp, b, err
+	}
+
+	// The body is handed out decompressed.
+	resp, b, err := get("/small")
+	require.NoError(t, err)
+	require.Equal(t, strings.Repeat("x", 1<<10), string(b))
+	require.Empty(t, resp.Header.Get("Content-Encoding"))
+	require.True(t, resp.Uncompressed)
+
+	// The compressed payload is well below the limit, but not once decompressed.
+	_, b, err = get("/large")
+	var tooLarge *ResponseBodyTooLargeError
+	require.ErrorAs(t, err, &tooLarge)
+	require.Len(t, b, 1<<16)
+}
+
+func TestNewLimitedReaderDecompressed(t *testing.T) {
+	var buf bytes.Buffer
+	gz := gzip.NewWriter(&buf)
+	_, err := gz.Write(bytes.Repeat([]byte{0}, 1<<20))
+	require.NoError(t, err)
+	require.NoError(t, gz.Close())
+
+	// The compressed payload is well below the limit, but not once decompressed.
+	compressed := NewLimitedReader(&buf, 1<<16)
+	r, err := gzip.NewReader(compressed)
+	require.NoError(t, err)
+	_, err = io.Copy(io.Discard, NewLimitedReader(r, 1<<16))
+	var tooLarge *ResponseBodyTooLargeError
+	require.ErrorAs(t, err, &tooLarge)
+	require.EqualError(t, err, "response body exceeds the limit of 65536 bytes")
+}
+
+func TestMaxResponseBytesValidate(t *testing.T) {
+	_, err := LoadHTTPConfig("max_response_header_bytes: -1\n")
+	require.EqualError(t, err, "max_response_body_bytes and max_response_header_bytes must not be negative")
+}
@@ -0,0 +1,486 @@
+// Copyright 2026 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package config
+
+import (
+	"context"
+	"crypto/sha256"
+	"crypto/subtle"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"slices"
+	"sync"
+
+	"go.yaml.in/yaml/v2"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// ServerConfig configures the TLS and the authentication of an HTTP server.
+type ServerConfig struct {
+	// TLSConfig enables TLS on the server.
+	TLSConfig *ServerTLSConfig `yaml:"tls_server_config,omitempty" json:"tls_server_config,omitempty"`
+	// BasicAuthUsers maps user names to the bcrypt hashes of their
+	// passwords. When set, requests must be authenticated with one of them.
+	BasicAuthUsers map[string]Secret `yaml:"basic_auth_users,omitempty" json:"basic_auth_users,omitempty"`
+}
+
+// SetDirectory joins any relative file paths with dir.
+func (c *ServerConfig) SetDirectory(dir string) {
+	if c == nil {
+		return
+	}
+	c.TLSConfig.SetDirectory(dir)
+}
+
+// Validate validates the ServerConfig.
+func (c *ServerConfig) Validate() error {
+	if err := c.TLSConfig.Validate(); err != nil {
+		return err
+	}
+	for user, hash := range c.BasicAuthUsers {
+		if _, err := bcrypt.Cost([]byte(hash)); err != nil {
+			return fmt.Errorf("invalid bcrypt hash for basic_auth_users %q: %w", user, err)
+		}
+	}
+	return nil
+}
+
+// UnmarshalYAML implements the yaml.Unmarshaler interface.
+func (c *ServerConfig) UnmarshalYAML(unmarshal func(any) error) error {
+	type plain ServerConfig
+	*c = ServerConfig{}
+	if err := unmarshal((*plain)(c)); err != nil {
+		return err
+	}
+	return c.Validate()
+}
+
+// UnmarshalJSON implements the json.Unmarshaler interface.
+func (c *ServerConfig) UnmarshalJSON(data []byte) error {
+	type plain ServerConfig
+	*c = ServerConfig{}
+	if err := json.Unmarshal(data, (*plain)(c)); err != nil {
+		return err
+	}
+	return c.Validate()
+}
+
+// LoadServerConfig parses the YAML input s into a ServerConfig.
+func LoadServerConfig(s string) (*ServerConfig, error) {
+	cfg := &ServerConfig{}
+	err := yaml.UnmarshalStrict([]byte(s), cfg)
+	if err != nil {
+		return nil, err
+	}
+	return cfg, nil
+}
+
+// LoadServerConfigFile parses the given YAML file into a ServerConfig.
+func LoadServerConfigFile(filename string) (*ServerConfig, []byte, error) {
+	content, err := os.ReadFile(filename)
+	if err != nil {
+		return nil, nil, err
+	}
+	cfg, err := LoadServerConfig(string(content))
+	if err != nil {
+		return nil, nil, err
+	}
+	cfg.SetDirectory(filepath.Dir(filename))
+	return cfg, content, nil
+}
+
+// ClientAuthType is the policy of the server for TLS client certificates.
+type ClientAuthType tls.ClientAuthType
+
+var clientAuthTypes = map[string]ClientAuthType{
+	"NoClientCert":               ClientAuthType(tls.NoClientCert),
+	"RequestClientCert":          ClientAuthType(tls.RequestClientCert),
+	"RequireAnyClientCert":       ClientAuthType(tls.RequireAnyClientCert),
+	"VerifyClientCertIfGiven":    ClientAuthType(tls.VerifyClientCertIfGiven),
+	"RequireAndVerifyClientCert": ClientAuthType(tls.RequireAndVerifyClientCert),
+}
+
+// UnmarshalYAML implements the yaml.Unmarshaler interface.
+func (t *ClientAuthType) UnmarshalYAML(unmarshal func(any) error) error {
+	var s string
+	if err := unmarshal(&s); err != nil {
+		return err
+	}
+	if v, ok := clientAuthTypes[s]; ok {
+		*t = v
+		return nil
+	}
+	return fmt.Errorf("unknown client_auth_type: %s", s)
+}
+
+// MarshalYAML implements the yaml.Marshaler interface.
+func (t ClientAuthType) MarshalYAML() (any, error) {
+	return t.String(), nil
+}
+
+// UnmarshalJSON implements the json.Unmarshaler interface.
+func (t *ClientAuthType) UnmarshalJSON(data []byte) error {
+	var s string
+	if err := json.Unmarshal(data, &s); err != nil {
+		return err
+	}
+	if v, ok := clientAuthTypes[s]; ok {
+		*t = v
+		return nil
+	}
+	return fmt.Errorf("unknown client_auth_type: %s", s)
+}
+
+// MarshalJSON implements the json.Marshaler interface.
+func (t ClientAuthType) MarshalJSON() ([]byte, error) {
+	return json.Marshal(t.String())
+}
+
+// String implements the fmt.Stringer interface.
+func (t ClientAuthType) String() string {
+	for s, v := range clientAuthTypes {
+		if v == t {
+			return s
+		}
+	}
+	return fmt.Sprintf("%d", t)
+}
+
+// ServerTLSConfig configures the TLS connections accepted by a server. The
+// certificate, key and client CA are read again when they change, so that
+// they can be rotated without restarting the server.
+type ServerTLSConfig struct {
+	// Text of the server cert.
+	Cert string `yaml:"cert,omitempty" json:"cert,omitempty"`
+	// Text of the server key.
+	Key Secret `yaml:"key,omitempty" json:"key,omitempty"`
+	// The server cert file.
+	CertFile string `yaml:"cert_file,omitempty" json:"cert_file,omitempty"`
+	// The server key file.
+	KeyFile string `yaml:"key_file,omitempty" json:"key_file,omitempty"`
+	// CertRef is the name of the secret within the secret manager to use as
+	// the server cert.
+	CertRef string `yaml:"cert_ref,omitempty" json:"cert_ref,omitempty"`
+	// KeyRef is the name of the secret within the secret manager to use as
+	// the server key.
+	KeyRef string `yaml:"key_ref,omitempty" json:"key_ref,omitempty"`
+	// ClientAuthType is the policy for client certificates. Default is
+	// RequireAndVerifyClientCert when a client CA is configured, and
+	// NoClientCert otherwise.
+	ClientAuthType *ClientAuthType `yaml:"client_auth_type,omitempty" json:"client_auth_type,omitempty"`
+	// Text of the CA cert used to verify the client certificates.
+	ClientCA string `yaml:"client_ca,omitempty" json:"client_ca,omitempty"`
+	// The CA cert file used to verify the client certificates.
+	ClientCAFile string `yaml:"client_ca_file,omitempty" json:"client_ca_file,omitempty"`
+	// ClientCARef is the name of the secret within the secret manager to use
+	// as the client CA cert.
+	ClientCARef string `yaml:"client_ca_ref,omitempty" json:"client_ca_ref,omitempty"`
+	// ClientAllowedSANs restricts the accepted client certificates to the
+	// ones with one of these DNS names, IP addresses, email addresses or URIs.
+	ClientAllowedSANs []string `yaml:"client_allowed_sans,omitempty" json:"client_allowed_sans,omitempty"`
+	// Minimum TLS version.
+	MinVersion TLSVersion `yaml:"min_version,omitempty" json:"min_version,omitempty"`
+	// Maximum TLS version.
+	MaxVersion TLSVersion `yaml:"max_version,omitempty" json:"max_version,omitempty"`
+}
+
+// SetDirectory joins any relative file paths with dir.
+func (c *ServerTLSConfig) SetDirectory(dir string) {
+	if c == nil {
+		return
+	}
+	c.CertFile = JoinDir(dir, c.CertFile)
+	c.KeyFile = JoinDir(dir, c.KeyFile)
+	c.ClientCAFile = JoinDir(dir, c.ClientCAFile)
+}
+
+// Validate validates the ServerTLSConfig.
+func (c *ServerTLSConfig) Validate() error {
+	if c == nil {
+		return nil
+	}
+	if nonZeroCount(len(c.Cert) > 0, len(c.CertFile) > 0, len(c.CertRef) > 0) != 1 {
+		return errors.New("exactly one of tls_server_config cert, cert_file & cert_ref must be configured")
+	}
+	if nonZeroCount(len(c.Key) > 0, len(c.KeyFile) > 0, len(c.KeyRef) > 0) != 1 {
+		return errors.New("exactly one of tls_server_config key, key_file & key_ref must be configured")
+	}
+	if nonZeroCount(len(c.ClientCA) > 0, len(c.ClientCAFile) > 0, len(c.ClientCARef) > 0) > 1 {
+		return errors.New("at most one of tls_server_config client_ca, client_ca_file & client_ca_ref must be configured")
+	}
+	if c.MaxVersion != 0 && c.MinVersion != 0 && c.MaxVersion < c.MinVersion {
+		return errors.New("tls_server_config max_version must be greater than or equal to min_version if both are specified")
+	}
+	switch c.clientAuth() {
+	case tls.VerifyClientCertIfGiven, tls.RequireAndVerifyClientCert:
+		if !c.usingClientCA() {
+			return errors.New("tls_server_config client_ca must be configured to verify client certificates")
+		}
+	default:
+		if c.usingClientCA() {
+			return errors.New("tls_server_config client_ca is only used with client_auth_type VerifyClientCertIfGiven or RequireAndVerifyClientCert")
+		}
+		if len(c.ClientAllowedSANs) > 0 {
+			return errors.New("tls_server_config client_allowed_sans requires verified client certificates")
+		}
+	}
+	return nil
+}
+
+func (c *ServerTLSConfig) usingClientCA() bool {
+	return len(c.ClientCA) > 0 || len(c.ClientCAFile) > 0 || len(c.ClientCARef) > 0
+}
+
+func (c *ServerTLSConfig) clientAuth() tls.ClientAuthType {
+	if c.ClientAuthType != nil {
+		return tls.ClientAuthType(*c.ClientAuthType)
+	}
+	if c.usingClientCA() {
+		return tls.RequireAndVerifyClientCert
+	}
+	return tls.NoClientCert
+}
+
+// serverTLSState caches the certificate and client CA of a server, which are
+// parsed again only when their content changes.
+type serverTLSState struct {
+	cert, key, clientCA SecretReader
+
+	mtx           sync.Mutex
+	lastCert      string
+	lastKey       string
+	lastClientCA  string
+	certificate   *tls.Certificate
+	clientCAsPool *x509.CertPool
+}
+
+func (s *serverTLSState) getCertificate(ctx context.Context) (*tls.Certificate, error) {
+	certData, err := s.cert.Fetch(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("unable to read server cert: %w", err)
+	}
+	keyData, err := s.key.Fetch(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("unable to read server key: %w", err)
+	}
+
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+	if s.certificate != nil && certData == s.lastCert && keyData == s.lastKey {
+		return s.certificate, nil
+	}
+	cert, err := tls.X509KeyPair([]byte(certData), []byte(keyData))
+	if err != nil {
+		return nil, fmt.Errorf("unable to use server cert (%s) & key (%s): %w", s.cert.Description(), s.key.Description(), err)
+	}
+	s.lastCert, s.lastKey, s.certificate = certData, keyData, &cert
+	return s.certificate, nil
+}
+
+func (s *serverTLSState) getClientCAs(ctx context.Context) (*x509.CertPool, error) {
+	if s.clientCA == nil {
+		return nil, nil
+	}
+	ca, err := s.clientCA.Fetch(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("unable to read client CA cert: %w", err)
+	}
+
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+	if s.clientCAsPool != nil && ca == s.lastClientCA {
+		return s.clientCAsPool, nil
+	}
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM([]byte(ca)) {
+		return nil, fmt.Errorf("unable to use specified client CA cert %s", s.clientCA.Description())
+	}
+	s.lastClientCA, s.clientCAsPool = ca, pool
+	return pool, nil
+}
+
+// NewServerTLSConfig creates a new tls.Config for a server from the given
+// ServerTLSConfig.
+func NewServerTLSConfig(cfg *ServerTLSConfig, optFuncs ...TLSConfigOption) (*tls.Config, error) {
+	return NewServerTLSConfigWithContext(context.Background(), cfg, optFuncs...)
+}
+
+// NewServerTLSConfigWithContext creates a new tls.Config for a server from
+// the given ServerTLSConfig.
+func NewServerTLSConfigWithContext(ctx context.Context, cfg *ServerTLSConfig, optFuncs ...TLSConfigOption) (*tls.Config, error) {
+	opts := tlsConfigOptions{}
+	for _, opt := range optFuncs {
+		opt.applyToTLSConfigOptions(&opts)
+	}
+
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+
+	state := &serverTLSState{}
+	var err error
+	if state.cert, err = toSecret(opts.secretManager, Secret(cfg.Cert), cfg.CertFile, cfg.CertRef); err != nil {
+		return nil, fmt.Errorf("unable to use server cert: %w", err)
+	}
+	if state.key, err = toSecret(opts.secretManager, cfg.Key, cfg.KeyFile, cfg.KeyRef); err != nil {
+		return nil, fmt.Errorf("unable to use server key: %w", err)
+	}
+	if state.clientCA, err = toSecret(opts.secretManager, Secret(cfg.ClientCA), cfg.ClientCAFile, cfg.ClientCARef); err != nil {
+		return nil, fmt.Errorf("unable to use client CA cert: %w", err)
+	}
+
+	// Verify that the files are valid.
+	if _, err := state.getCertificate(ctx); err != nil {
+		return nil, err
+	}
+	if _, err := state.getClientCAs(ctx); err != nil {
+		return nil, err
+	}
+
+	tlsConfig := &tls.Config{
+		MinVersion: uint16(cfg.MinVersion),
+		MaxVersion: uint16(cfg.MaxVersion),
+		ClientAuth: cfg.clientAuth(),
+		GetCertificate: func(hello *tls.ClientHelloInfo) (*tls.Certificate, error) {
+			return state.getCertificate(hello.Context())
+		},
+	}
+	if len(cfg.ClientAllowedSANs) > 0 {
+		tlsConfig.VerifyPeerCertificate = func(_ [][]byte, chains [][]*x509.Certificate) error {
+			for _, chain := range chains {
+				if len(chain) > 0 && certificateHasSAN(chain[0], cfg.ClientAllowedSANs) {
+					return nil
+				}
+			}
+			return errors.New("client certificate has no allowed SAN")
+		}
+	}
+	// Read the certificate and client CA again for each connection, to pick
+	// up rotations. GetCertificate alone would not be used for clients
+	// without SNI when Certificates is set.
+	tlsConfig.GetConfigForClient = func(hello *tls.ClientHelloInfo) (*tls.Config, error) {
+		cert, err := state.getCertificate(hello.Context())
+		if err != nil {
+			return nil, err
+		}
+		pool, err := state.getClientCAs(hello.Context())
+		if err != nil {
+			return nil, err
+		}
+		c := tlsConfig.Clone()
+		c.GetConfigForClient = nil
+		c.GetCertificate = nil
+		c.Certificates = []tls.Certificate{*cert}
+		c.ClientCAs = pool
+		return c, nil
+	}
+	return tlsConfig, nil
+}
+
+// certificateHasSAN returns whether cert has one of the given subject
+// alternative names.
+func certificateHasSAN(cert *x509.Certificate, sans []string) bool {
+	for _, name := range cert.DNSNames {
+		if slices.Contains(sans, name) {
+			return true
+		}
+	}
+	for _, ip := range cert.IPAddresses {
+		if slices.Contains(sans, ip.String()) {
+			return true
+		}
+	}
+	for _, email := range cert.EmailAddresses {
+		if slices.Contains(sans, email) {
+			return true
+		}
+	}
+	for _, uri := range cert.URIs {
+		if slices.Contains(sans, uri.String()) {
+			return true
+		}
+	}
+	return false
+}
+
+// NewServerHandler returns a handler requiring the requests to next to be
+// authenticated with one of the configured basic auth users. It returns next
+// if no user is configured.
+func NewServerHandler(cfg *ServerConfig, next http.Handler) http.Handler {
+	if cfg == nil || len(cfg.BasicAuthUsers) == 0 {
+		return next
+	}
+	return &basicAuthHandler{users: cfg.BasicAuthUsers, next: next}
+}
+
+// dummyBcryptHash returns a hash compared against the passwords of unknown
+// users, so that their requests take as long as the ones of known users.
+var dummyBcryptHash = sync.OnceValue(func() []byte {
+	h, _ := bcrypt.GenerateFromPassword([]byte("dummy"), bcrypt.DefaultCost)
+	return h
+})
+
+type basicAuthHandler struct {
+	users map[string]Secret
+	next  http.Handler
+
+	// cache holds a digest of the credentials already verified for each user,
+	// as bcrypt is slow by design. The passwords themselves are not kept.
+	cache sync.Map
+}
+
+func (h *basicAuthHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	user, pass, ok := r.BasicAuth()
+	if ok && h.authenticate(user, pass) {
+		h.next.ServeHTTP(w, r)
+		return
+	}
+	w.Header().Set("WWW-Authenticate", `Basic charset="UTF-8"`)
+	http.Error(w, http.StatusText(http.StatusUnauthorized), http.StatusUnauthorized)
+}
+
+func (h *basicAuthHandler) authenticate(user, pass string) bool {
+	hash, known := h.users[user]
+	if !known {
+		bcrypt.CompareHashAndPassword(dummyBcryptHash(), []byte(pass))
+		return false
+	}
+	digest := credentialsDigest(user, string(hash), pass)
+	if cached, ok := h.cache.Load(user); ok && subtle.ConstantTimeCompare(cached.([]byte), digest) == 1 {
+		return true
+	}
+	if bcrypt.CompareHashAndPassword([]byte(hash), []byte(pass)) != nil {
+		return false
+	}
+	h.cache.Store(user, digest)
+	return true
+}
+
+// credentialsDigest returns the SHA-256 digest of the user, the bcrypt hash of
+// their password and the password. The hash is included so that a cached
+// digest no longer matches once the password of the user is changed.
+func credentialsDigest(user, hash, pass string) []byte {
+	d := sha256.New()
+	for _, s := range []string{user, hash, pass} {
+		d.Write([]byte(s))
+		d.Write([]byte{0})
+	}
+	return d.Sum(nil)
+}
@@ -0,0 +1,204 @@
+// Copyright 2026 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package config
+
+import (
+	"crypto/tls"
+	"io"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"go.yaml.in/yaml/v2"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// newTLSTestServer starts a server with the TLS configuration of cfg.
+func newTLSTestServer(t *testing.T, cfg *ServerConfig) *httptest.Server {
+	tlsConfig, err := NewServerTLSConfig(cfg.TLSConfig)
+	require.NoError(t, err)
+	ts := httptest.NewUnstartedServer(NewServerHandler(cfg, http.HandlerFunc(func(http.ResponseWriter, *http.Request) {})))
+	ts.TLS = tlsConfig
+	ts.Config.ErrorLog = log.New(io.Discard, "", 0)
+	ts.StartTLS()
+	t.Cleanup(ts.Close)
+	return ts
+}
+
+// getWithClientCert sends a request to ts with the given client certificate.
+func getWithClientCert(t *testing.T, ts *httptest.Server, certFile, keyFile string) error {
+	client, err := NewClientFromConfig(HTTPClientConfig{TLSConfig: TLSConfig{
+		CAFile:   TLSCAChainPath,
+		CertFile: certFile,
+		KeyFile:  keyFile,
+	}}, "test")
+	require.NoError(t, err)
+	resp, err := client.Get(ts.URL)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	return nil
+}
+
+func TestServerTLSConfigClientCA(t *testing.T) {
+	cfg, err := LoadServerConfig(`
+tls_server_config:
+  cert_file: testdata/server.crt
+  key_file: testdata/server.key
+  client_ca_file: testdata/tls-ca-chain.pem
+  min_version: TLS12
+`)
+	require.NoError(t, err)
+	require.Equal(t, tls.RequireAndVerifyClientCert, cfg.TLSConfig.clientAuth())
+	ts := newTLSTestServer(t, cfg)
+
+	require.NoError(t, getWithClientCert(t, ts, ClientCertificatePath, ClientKeyNoPassPath))
+	require.Error(t, getWithClientCert(t, ts, "", ""))
+	require.Error(t, getWithClientCert(t, ts, WrongClientCertPath, WrongClientKeyPath))
+}
+
+func TestServerTLSConfigClientAllowedSANs(t *testing.T) {
+	cfg, err := LoadServerConfig(`
+tls_server_config:
+  cert_file: testdata/server.crt
+  key_file: testdata/server.key
+  client_ca_file: testdata/tls-ca-chain.pem
+  client_allowed_sans: [localhost]
+`)
+	require.NoError(t, err)
+	ts := newTLSTestServer(t, cfg)
+
+	// The client certificate has no SAN, while the server certificate can also
+	// be used for client authentication.
+	require.Error(t, getWithClientCert(t, ts, ClientCertificatePath, ClientKeyNoPassPath))
+	require.NoError(t, getWithClientCert(t, ts, ServerCertificatePath, ServerKeyPath))
+}
+
+func TestServerTLSConfigReload(t *testing.T) {
+	dir := t.TempDir()
+	copyFile := func(src, dst string) {
+		b, err := os.ReadFile(src)
+		require.NoError(t, err)
+		require.NoError(t, os.WriteFile(filepath.Join(dir, dst), b, 0o600))
+	}
+	// The client certificate is not valid for localhost.
+	copyFile(ClientCertificatePath, "server.crt")
+	copyFile(ClientKeyNoPassPath, "server.key")
+
+	cfg := &ServerConfig{TLSConfig: &ServerTLSConfig{
+		CertFile: filepath.Join(dir, "server.crt"),
+		KeyFile:  filepath.Join(dir, "server.key"),
+	}}
+	ts := newTLSTestServer(t, cfg)
+	require.Error(t, getWithClientCert(t, ts, "", ""))
+
+	copyFile(ServerCertificatePath, "server.crt")
+	copyFile(ServerKeyPath, "server.key")
+	require.NoError(t, getWithClientCert(t, ts, "", ""))
+}
+
+func TestServerBasicAuth(t *testing.T) {
+	hash, err := bcrypt.GenerateFromPassword([]byte("secret"), bcrypt.MinCost)
+	require.NoError(t, err)
+	cfg, err := LoadServerConfig("basic_auth_users:\n  alice: " + string(hash) + "\n")
+	require.NoError(t, err)
+
+	ts := httptest.NewServer(NewServerHandler(cfg, http.HandlerFunc(func(http.ResponseWriter, *http.Request) {})))
+	defer ts.Close()
+
+	for _, tc := range []struct {
+		user, pass string
+		status     int
+	}{
+		{"alice", "secret", http.StatusOK},
+		// The second time, the credentials are cached.
+		{"alice", "secret", http.StatusOK},
+		{"alice", "wrong", http.StatusUnauthorized},
+		{"bob", "secret", http.StatusUnauthorized},
+		{"", "", http.StatusUnauthorized},
+	} {
+		req, err := http.NewRequest(http.MethodGet, ts.URL, nil)
+		require.NoError(t, err)
+		if tc.user != "" {
+			req.SetBasicAuth(tc.user, tc.pass)
+		}
+		resp, err := http.DefaultClient.Do(req)
+		require.NoError(t, err)
+		resp.Body.Close()
+		require.Equal(t, tc.status, resp.StatusCode, "user %q, password %q", tc.user, tc.pass)
+	}
+
+	// Only a digest of the verified credentials is cached.
+	h := NewServerHandler(cfg, nil).(*basicAuthHandler)
+	require.True(t, h.authenticate("alice", "secret"))
+	require.True(t, h.authenticate("alice", "secret"))
+	require.False(t, h.authenticate("alice", "wrong"))
+	n := 0
+	h.cache.Range(func(_, v any) bool {
+		n++
+		require.NotContains(t, string(v.([]byte)), "secret")
+		return true
+	})
+	require.Equal(t, 1, n)
+
+	// The hashes are not disclosed.
+	b, err := yaml.Marshal(cfg)
+	require.NoError(t, err)
+	require.Equal(t, "basic_auth_users:\n  alice: <secret>\n", string(b))
+}
+
+func TestServerConfigValidate(t *testing.T) {
+	for _, tc := range []struct {
+		config string
+		errMsg string
+	}{
+		{
+			config: "tls_server_config:\n  key_file: server.key\n",
+			errMsg: "exactly one of tls_server_config cert, cert_file & cert_ref must be configured",
+		},
+		{
+			config: "tls_server_config:\n  cert_file: server.crt\n",
+			errMsg: "exactly one of tls_server_config key, key_file & key_ref must be configured",
+		},
+		{
+			config: "tls_server_config:\n  cert_file: server.crt\n  key_file: server.key\n  client_auth_type: RequireAndVerifyClientCert\n",
+			errMsg: "tls_server_config client_ca must be configured to verify client certificates",
+		},
+		{
+			config: "tls_server_config:\n  cert_file: server.crt\n  key_file: server.key\n  client_auth_type: NoClientCert\n  client_ca_file: ca.crt\n",
+			errMsg: "tls_server_config client_ca is only used with client_auth_type VerifyClientCertIfGiven or RequireAndVerifyClientCert",
+		},
+		{
+			config: "tls_server_config:\n  cert_file: server.crt\n  key_file: server.key\n  client_allowed_sans: [localhost]\n",
+			errMsg: "tls_server_config client_allowed_sans requires verified client certificates",
+		},
+		{
+			config: "tls_server_config:\n  cert_file: server.crt\n  key_file: server.key\n  client_auth_type: Unknown\n",
+			errMsg: "unknown client_auth_type: Unknown",
+		},
+		{
+			config: "basic_auth_users:\n  alice: plaintext\n",
+			errMsg: `invalid bcrypt hash for basic_auth_users "alice": crypto/bcrypt: hashedSecret too short to be a bcrypted password`,
+		},
+	} {
+		_, err := LoadServerConfig(tc.config)
+		require.EqualError(t, err, tc.errMsg)
+	}
+}
@@ -0,0 +1,124 @@
+// Copyright 2026 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package config
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+)
+
+// TLSClientCertificate is a pair of client cert and key. When several pairs
+// are configured, the client presents the first one issued by a CA that the
+// server accepts.
+type TLSClientCertificate struct {
+	// Text of the client cert.
+	Cert string `yaml:"cert,omitempty" json:"cert,omitempty"`
+	// Text of the client key.
+	Key Secret `yaml:"key,omitempty" json:"key,omitempty"`
+	// The client cert file.
+	CertFile string `yaml:"cert_file,omitempty" json:"cert_file,omitempty"`
+	// The client key file.
+	KeyFile string `yaml:"key_file,omitempty" json:"key_file,omitempty"`
+	// CertRef is the name of the secret within the secret manager to use as
+	// the client cert.
+	CertRef string `yaml:"cert_ref,omitempty" json:"cert_ref,omitempty"`
+	// KeyRef is the name of the secret within the secret manager to use as
+	// the client key.
+	KeyRef string `yaml:"key_ref,omitempty" json:"key_ref,omitempty"`
+}
+
+// SetDirectory joins any relative file paths with dir.
+func (c *TLSClientCertificate) SetDirectory(dir string) {
+	c.CertFile = JoinDir(dir, c.CertFile)
+	c.KeyFile = JoinDir(dir, c.KeyFile)
+}
+
+func (c *TLSClientCertificate) validate(i int) error {
+	if nonZeroCount(len(c.Cert) > 0, len(c.CertFile) > 0, len(c.CertRef) > 0) != 1 {
+		return fmt.Errorf("exactly one of client_certificates[%d] cert, cert_file & cert_ref must be configured", i)
+	}
+	if nonZeroCount(len(c.Key) > 0, len(c.KeyFile) > 0, len(c.KeyRef) > 0) != 1 {
+		return fmt.Errorf("exactly one of client_certificates[%d] key, key_file & key_ref must be configured", i)
+	}
+	return nil
+}
+
+func (c *TLSClientCertificate) secrets(secretManager SecretManager) (cert, key SecretReader, err error) {
+	cert, err = toSecret(secretManager, Secret(c.Cert), c.CertFile, c.CertRef)
+	if err != nil {
+		return nil, nil, fmt.Errorf("unable to use client cert: %w", err)
+	}
+	key, err = toSecret(secretManager, c.Key, c.KeyFile, c.KeyRef)
+	if err != nil {
+		return nil, nil, fmt.Errorf("unable to use client key: %w", err)
+	}
+	return cert, key, nil
+}
+
+// load reads the pair of client cert and key and returns a tls.Certificate.
+func (c *TLSClientCertificate) load(ctx context.Context, secretManager SecretManager) (*tls.Certificate, error) {
+	var certData, keyData string
+
+	certSecret, keySecret, err := c.secrets(secretManager)
+	if err != nil {
+		return nil, err
+	}
+	if certSecret != nil {
+		certData, err = certSecret.Fetch(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("unable to read specified client cert: %w", err)
+		}
+	}
+	if keySecret != nil {
+		keyData, err = keySecret.Fetch(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("unable to read specified client key: %w", err)
+		}
+	}
+
+	cert, err := tls.X509KeyPair([]byte(certData), []byte(keyData))
+	if err != nil {
+		return nil, fmt.Errorf("unable to use specified client cert (%s) & key (%s): %w", certSecret.Description(), keySecret.Description(), err)
+	}
+	return &cert, nil
+}
+
+// getClientCertificates reads all the configured client certificates.
+func (c *TLSConfig) getClientCertificates(ctx context.Context, secretManager SecretManager) ([]*tls.Certificate, error) {
+	certs := make([]*tls.Certificate, 0, len(c.ClientCertificates))
+	for i := range c.ClientCertificates {
+		cert, err := c.ClientCertificates[i].load(ctx, secretManager)
+		if err != nil {
+			return nil, fmt.Errorf("client_certificates[%d]: %w", i, err)
+		}
+		certs = append(certs, cert)
+	}
+	return certs, nil
+}
+
+// selectClientCertificate returns the first certificate supported by the
+// server, according to the CAs and signature schemes of its certificate
+// request. Like crypto/tls does for tls.Config.Certificates, it falls back to
+// the first certificate.
+func selectClientCertificate(cri *tls.CertificateRequestInfo, certs []*tls.Certificate) *tls.Certificate {
+	if cri != nil {
+		for _, cert := range certs {
+			if cri.SupportsCertificate(cert) == nil {
+				return cert
+			}
+		}
+	}
+	return certs[0]
+}
@@ -0,0 +1,115 @@
+// Copyright 2026 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package config
+
+import (
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"go.yaml.in/yaml/v2"
+)
+
+func TestTLSClientCertificatesSelection(t *testing.T) {
+	ts := newTLSTestServer(t, &ServerConfig{TLSConfig: &ServerTLSConfig{
+		CertFile:     ServerCertificatePath,
+		KeyFile:      ServerKeyPath,
+		ClientCAFile: TLSCAChainPath,
+	}})
+
+	get := func(certs ...TLSClientCertificate) error {
+		client, err := NewClientFromConfig(HTTPClientConfig{TLSConfig: TLSConfig{
+			CAFile:             TLSCAChainPath,
+			ClientCertificates: certs,
+		}}, "test")
+		require.NoError(t, err)
+		resp, err := client.Get(ts.URL)
+		if err != nil {
+			return err
+		}
+		resp.Body.Close()
+		require.Equal(t, http.StatusOK, resp.StatusCode)
+		return nil
+	}
+
+	selfSigned := TLSClientCertificate{CertFile: WrongClientCertPath, KeyFile: WrongClientKeyPath}
+	client := TLSClientCertificate{CertFile: ClientCertificatePath, KeyFile: ClientKeyNoPassPath}
+
+	require.NoError(t, get(selfSigned, client))
+	require.NoError(t, get(client, selfSigned))
+	// Without an acceptable certificate, the first one is presented.
+	require.Error(t, get(selfSigned))
+}
+
+func TestTLSClientCertificatesReload(t *testing.T) {
+	ts := newTLSTestServer(t, &ServerConfig{TLSConfig: &ServerTLSConfig{
+		CertFile:     ServerCertificatePath,
+		KeyFile:      ServerKeyPath,
+		ClientCAFile: TLSCAChainPath,
+	}})
+
+	dir := t.TempDir()
+	copyFile := func(src, dst string) {
+		b, err := os.ReadFile(src)
+		require.NoError(t, err)
+		require.NoError(t, os.WriteFile(filepath.Join(dir, dst), b, 0o600))
+	}
+	copyFile(ClientCertificatePath, "client.crt")
+	copyFile(ClientKeyNoPassPath, "client.key")
+
+	client, err := NewClientFromConfig(HTTPClientConfig{TLSConfig: TLSConfig{
+		CAFile: TLSCAChainPath,
+		ClientCertificates: []TLSClientCertificate{
+			{CertFile: WrongClientCertPath, KeyFile: WrongClientKeyPath},
+			{CertFile: filepath.Join(dir, "client.crt"), KeyFile: filepath.Join(dir, "client.key")},
+		},
+	}}, "test")
+	require.NoError(t, err)
+
+	resp, err := client.Get(ts.URL)
+	require.NoError(t, err)
+	resp.Body.Close()
+
+	// Once the pair is replaced, the idle connections are closed and the
+	// handshake fails.
+	copyFile(WrongClientCertPath, "client.crt")
+	copyFile(WrongClientKeyPath, "client.key")
+	_, err = client.Get(ts.URL)
+	require.Error(t, err)
+}
+
+func TestTLSClientCertificatesValidate(t *testing.T) {
+	for _, tc := range []struct {
+		config string
+		errMsg string
+	}{
+		{
+			config: "cert_file: a.crt\nkey_file: a.key\nclient_certificates:\n- cert_file: b.crt\n  key_file: b.key\n",
+			errMsg: "client_certificates cannot be used with cert & key",
+		},
+		{
+			config: "client_certificates:\n- cert_file: b.crt\n  key_file: b.key\n- key_file: c.key\n",
+			errMsg: "exactly one of client_certificates[1] cert, cert_file & cert_ref must be configured",
+		},
+		{
+			config: "client_certificates:\n- cert_file: b.crt\n  key_file: b.key\n  key_ref: b\n",
+			errMsg: "exactly one of client_certificates[0] key, key_file & key_ref must be configured",
+		},
+	} {
+		var cfg TLSConfig
+		require.EqualError(t, yaml.UnmarshalStrict([]byte(tc.config), &cfg), tc.errMsg)
+	}
+}
@@ -0,0 +1,155 @@
+// Copyright 2026 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package config
+
+import (
+	"context"
+	"errors"
+	"net"
+	"time"
+
+	"github.com/prometheus/common/model"
+)
+
+// DefaultTransportConfig holds the connection pool and timeout settings used
+// when a TransportConfig field is left unset.
+var DefaultTransportConfig = TransportConfig{
+	MaxIdleConns:          20000,
+	MaxIdleConnsPerHost:   1000, // see https://github.com/golang/go/issues/13801
+	TLSHandshakeTimeout:   model.Duration(10 * time.Second),
+	ExpectContinueTimeout: durationPtr(1 * time.Second),
+	HTTP2ReadIdleTimeout:  durationPtr(time.Minute),
+}
+
+// TransportConfig configures the connection pool and the timeouts of the
+// transport underlying an HTTP client. Zero values fall back to the values in
+// DefaultTransportConfig. ExpectContinueTimeout and HTTP2ReadIdleTimeout, for
+// which zero is meaningful, fall back to the defaults when nil.
+type TransportConfig struct {
+	// MaxIdleConns limits the number of idle connections across all hosts.
+	MaxIdleConns int `yaml:"max_idle_conns,omitempty" json:"max_idle_conns,omitempty"`
+	// MaxIdleConnsPerHost limits the number of idle connections per host.
+	MaxIdleConnsPerHost int `yaml:"max_idle_conns_per_host,omitempty" json:"max_idle_conns_per_host,omitempty"`
+	// MaxConnsPerHost limits the total number of connections per host,
+	// including connections in the dialing, active, and idle states. Zero
+	// means no limit.
+	MaxConnsPerHost int `yaml:"max_conns_per_host,omitempty" json:"max_conns_per_host,omitempty"`
+	// DialTimeout is the maximum amount of time a dial will wait for a
+	// connection to complete. Zero means no timeout other than the one of the
+	// request context.
+	DialTimeout model.Duration `yaml:"dial_timeout,omitempty" json:"dial_timeout,omitempty"`
+	// KeepAlive is the interval between TCP keep-alive probes. Zero uses the
+	// operating system and Go defaults. Only applies to the default dialer.
+	KeepAlive model.Duration `yaml:"keep_alive,omitempty" json:"keep_alive,omitempty"`
+	// TLSHandshakeTimeout is the maximum amount of time to wait for a TLS
+	// handshake.
+	TLSHandshakeTimeout model.Duration `yaml:"tls_handshake_timeout,omitempty" json:"tls_handshake_timeout,omitempty"`
+	// ExpectContinueTimeout is the amount of time to wait for a server's first
+	// response headers after fully writing the request headers if the request
+	// has an "Expect: 100-continue" header. Zero sends the body immediately.
+	ExpectContinueTimeout *model.Duration `yaml:"expect_continue_timeout,omitempty" json:"expect_continue_timeout,omitempty"`
+	// ResponseHeaderTimeout is the amount of time to wait for a server's
+	// response headers after fully writing the request. Zero means no timeout
+	// other than the one of the request context.
+	ResponseHeaderTimeout model.Duration `yaml:"response_header_timeout,omitempty" json:"response_header_timeout,omitempty"`
+	// HTTP2ReadIdleTimeout is the interval after which a health check using a
+	// ping frame is carried out if no frame is received on an HTTP/2
+	// connection. Zero disables the health check.
+	HTTP2ReadIdleTimeout *model.Duration `yaml:"http2_read_idle_timeout,omitempty" json:"http2_read_idle_timeout,omitempty"`
+	// HTTP2PingTimeout is the amount of time after which an HTTP/2 connection
+	// is closed if no response to a ping is received. Zero uses the
+	// golang.org/x/net/http2 default.
+	HTTP2PingTimeout model.Duration `yaml:"http2_ping_timeout,omitempty" json:"http2_ping_timeout,omitempty"`
+}
+
+// Validate validates the TransportConfig to check that no limit or timeout is
+// negative.
+func (c *TransportConfig) Validate() error {
+	if c == nil {
+		return nil
+	}
+	if c.MaxIdleConns < 0 || c.MaxIdleConnsPerHost < 0 || c.MaxConnsPerHost < 0 {
+		return errors.New("transport connection limits must not be negative")
+	}
+	for _, d := range []*model.Duration{
+		&c.DialTimeout,
+		&c.KeepAlive,
+		&c.TLSHandshakeTimeout,
+		c.ExpectContinueTimeout,
+		&c.ResponseHeaderTimeout,
+		c.HTTP2ReadIdleTimeout,
+		&c.HTTP2PingTimeout,
+	} {
+		if d != nil && *d < 0 {
+			return errors.New("transport timeouts must not be negative")
+		}
+	}
+	return nil
+}
+
+// withDefaults returns a copy of the TransportConfig where unset fields are
+// replaced by the values of DefaultTransportConfig.
+func (c *TransportConfig) withDefaults() TransportConfig {
+	if c == nil {
+		return DefaultTransportConfig
+	}
+	tc := *c
+	orDefault(&tc.MaxIdleConns, DefaultTransportConfig.MaxIdleConns)
+	orDefault(&tc.MaxIdleConnsPerHost, DefaultTransportConfig.MaxIdleConnsPerHost)
+	orDefault(&tc.MaxConnsPerHost, DefaultTransportConfig.MaxConnsPerHost)
+	orDefault(&tc.DialTimeout, DefaultTransportConfig.DialTimeout)
+	orDefault(&tc.KeepAlive, DefaultTransportConfig.KeepAlive)
+	orDefault(&tc.TLSHandshakeTimeout, DefaultTransportConfig.TLSHandshakeTimeout)
+	orDefault(&tc.ExpectContinueTimeout, DefaultTransportConfig.ExpectContinueTimeout)
+	orDefault(&tc.ResponseHeaderTimeout, DefaultTransportConfig.ResponseHeaderTimeout)
+	orDefault(&tc.HTTP2ReadIdleTimeout, DefaultTransportConfig.HTTP2ReadIdleTimeout)
+	orDefault(&tc.HTTP2PingTimeout, DefaultTransportConfig.HTTP2PingTimeout)
+	return tc
+}
+
+// dialer returns the net.Dialer configured by the TransportConfig, or nil if
+// the default dialer can be used.
+func (c *TransportConfig) dialer() *net.Dialer {
+	if c.DialTimeout == 0 && c.KeepAlive == 0 {
+		return nil
+	}
+	return &net.Dialer{
+		Timeout:   time.Duration(c.DialTimeout),
+		KeepAlive: time.Duration(c.KeepAlive),
+	}
+}
+
+// durationPtr returns a pointer to d as a model.Duration.
+func durationPtr(d time.Duration) *model.Duration {
+	md := model.Duration(d)
+	return &md
+}
+
+// orDefault sets *v to def if *v is the zero value.
+func orDefault[T comparable](v *T, def T) {
+	var zero T
+	if *v == zero {
+		*v = def
+	}
+}
+
+// dialContextWithTimeout returns a DialContextFunc that bounds every dial of fn
+// by timeout.
+func dialContextWithTimeout(fn DialContextFunc, timeout time.Duration) DialContextFunc {
+	return func(ctx context.Context, network, addr string) (net.Conn, error) {
+		ctx, cancel := context.WithTimeout(ctx, timeout)
+		defer cancel()
+		return fn(ctx, network, addr)
+	}
+}
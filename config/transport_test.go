@@ -0,0 +1,156 @@
+// Copyright 2026 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package config
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"go.yaml.in/yaml/v2"
+
+	"github.com/prometheus/common/model"
+)
+
+func TestTransportConfigDefaults(t *testing.T) {
+	rt, err := NewRoundTripperFromConfig(HTTPClientConfig{}, "test")
+	require.NoError(t, err)
+
+	transport, ok := rt.(*http.Transport)
+	require.Truef(t, ok, "Unexpected transport: %T", rt)
+
+	require.Equal(t, 20000, transport.MaxIdleConns)
+	require.Equal(t, 1000, transport.MaxIdleConnsPerHost)
+	require.Zero(t, transport.MaxConnsPerHost)
+	require.Equal(t, 10*time.Second, transport.TLSHandshakeTimeout)
+	require.Equal(t, time.Second, transport.ExpectContinueTimeout)
+	require.Zero(t, transport.ResponseHeaderTimeout)
+}
+
+func TestTransportConfig(t *testing.T) {
+	cfg, err := LoadHTTPConfig(`
+transport:
+  max_idle_conns: 100
+  max_idle_conns_per_host: 2
+  max_conns_per_host: 4
+  dial_timeout: 3s
+  keep_alive: 20s
+  tls_handshake_timeout: 5s
+  response_header_timeout: 30s
+  http2_read_idle_timeout: 15s
+  http2_ping_timeout: 5s
+`)
+	require.NoError(t, err)
+	require.Equal(t, &TransportConfig{
+		MaxIdleConns:          100,
+		MaxIdleConnsPerHost:   2,
+		MaxConnsPerHost:       4,
+		DialTimeout:           model.Duration(3 * time.Second),
+		KeepAlive:             model.Duration(20 * time.Second),
+		TLSHandshakeTimeout:   model.Duration(5 * time.Second),
+		ResponseHeaderTimeout: model.Duration(30 * time.Second),
+		HTTP2ReadIdleTimeout:  durationPtr(15 * time.Second),
+		HTTP2PingTimeout:      model.Duration(5 * time.Second),
+	}, cfg.Transport)
+
+	rt, err := NewRoundTripperFromConfig(*cfg, "test")
+	require.NoError(t, err)
+
+	transport, ok := rt.(*http.Transport)
+	require.Truef(t, ok, "Unexpected transport: %T", rt)
+
+	require.Equal(t, 100, transport.MaxIdleConns)
+	require.Equal(t, 2, transport.MaxIdleConnsPerHost)
+	require.Equal(t, 4, transport.MaxConnsPerHost)
+	require.Equal(t, 5*time.Second, transport.TLSHandshakeTimeout)
+	// Unset fields keep their default value.
+	require.Equal(t, time.Second, transport.ExpectContinueTimeout)
+	require.Equal(t, 30*time.Second, transport.ResponseHeaderTimeout)
+
+	out, err := yaml.Marshal(cfg.Transport)
+	require.NoError(t, err)
+	require.Contains(t, string(out), "dial_timeout: 3s")
+}
+
+func TestTransportConfigExplicitZero(t *testing.T) {
+	fromYAML, err := LoadHTTPConfig(`
+transport:
+  expect_continue_timeout: 0s
+  http2_read_idle_timeout: 0s
+`)
+	require.NoError(t, err)
+	var fromJSON HTTPClientConfig
+	require.NoError(t, json.Unmarshal([]byte(`{"transport":{"expect_continue_timeout":"0s","http2_read_idle_timeout":"0s"}}`), &fromJSON))
+
+	for _, cfg := range []*HTTPClientConfig{fromYAML, &fromJSON} {
+		require.Equal(t, &TransportConfig{ExpectContinueTimeout: durationPtr(0), HTTP2ReadIdleTimeout: durationPtr(0)}, cfg.Transport)
+
+		rt, err := NewRoundTripperFromConfig(*cfg, "test")
+		require.NoError(t, err)
+		transport, ok := rt.(*http.Transport)
+		require.Truef(t, ok, "Unexpected transport: %T", rt)
+		require.Zero(t, transport.ExpectContinueTimeout)
+		require.Equal(t, 10*time.Second, transport.TLSHandshakeTimeout)
+	}
+
+	// The explicit zeros survive a round trip.
+	out, err := yaml.Marshal(fromYAML.Transport)
+	require.NoError(t, err)
+	require.Equal(t, "expect_continue_timeout: 0s\nhttp2_read_idle_timeout: 0s\n", string(out))
+	var reloaded TransportConfig
+	require.NoError(t, yaml.UnmarshalStrict(out, &reloaded))
+	require.Equal(t, fromYAML.Transport, &reloaded)
+
+	// Configurations built in Go fall back to the defaults when unset.
+	tc := (&TransportConfig{MaxIdleConns: 50}).withDefaults()
+	require.Equal(t, 50, tc.MaxIdleConns)
+	require.Equal(t, DefaultTransportConfig.ExpectContinueTimeout, tc.ExpectContinueTimeout)
+	require.Equal(t, DefaultTransportConfig.HTTP2ReadIdleTimeout, tc.HTTP2ReadIdleTimeout)
+}
+
+func TestTransportConfigDialTimeout(t *testing.T) {
+	var deadline time.Time
+	dialFn := func(ctx context.Context, _, _ string) (net.Conn, error) {
+		deadline, _ = ctx.Deadline()
+		return nil, errors.New(ExpectedError)
+	}
+
+	cfg := HTTPClientConfig{
+		Transport: &TransportConfig{DialTimeout: model.Duration(time.Hour)},
+	}
+	client, err := NewClientFromConfig(cfg, "test", WithDialContextFunc(dialFn))
+	require.NoError(t, err)
+
+	_, err = client.Get("http://localhost")
+	require.ErrorContains(t, err, ExpectedError)
+	require.WithinDuration(t, time.Now().Add(time.Hour), deadline, time.Minute)
+}
+
+func TestTransportConfigValidate(t *testing.T) {
+	_, err := LoadHTTPConfig(`
+transport:
+  max_conns_per_host: -1
+`)
+	require.EqualError(t, err, "transport connection limits must not be negative")
+
+	cfg := HTTPClientConfig{
+		Transport: &TransportConfig{ResponseHeaderTimeout: model.Duration(-time.Second)},
+	}
+	require.EqualError(t, cfg.Validate(), "transport timeouts must not be negative")
+}
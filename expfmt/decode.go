@@ -17,9 +17,12 @@ import (
 	"bufio"
 	"fmt"
 	"io"
+	"maps"
 	"math"
 	"mime"
 	"net/http"
+	"slices"
+	"strings"
 
 	dto "github.com/prometheus/client_model/go"
 	"google.golang.org/protobuf/encoding/protodelim"
@@ -36,8 +39,27 @@ type Decoder interface {
 type DecodeOptions struct {
 	// Timestamp is added to each value from the stream that has no explicit timestamp set.
 	Timestamp model.Time
+	// Histograms selects which samples are extracted from histograms. The
+	// zero value extracts classic histogram series only.
+	Histograms HistogramExtraction
 }
 
+// HistogramExtraction selects how histograms are turned into samples.
+type HistogramExtraction int
+
+const (
+	// ClassicHistograms extracts _bucket, _sum and _count series and ignores
+	// native histogram fields.
+	ClassicHistograms HistogramExtraction = iota
+	// NativeHistograms extracts a single sample with Histogram set for every
+	// native histogram. Histograms without native data are still extracted
+	// as classic series.
+	NativeHistograms
+	// ClassicAndNativeHistograms extracts both the classic series and, for
+	// native histograms, the sample with Histogram set.
+	ClassicAndNativeHistograms
+)
+
 // ResponseFormat extracts the correct format from a HTTP response header.
 // If no matching format can be found FormatUnknown is returned.
 func ResponseFormat(h http.Header) Format {
@@ -65,6 +87,23 @@ func ResponseFormat(h http.Header) Format {
 			return FmtUnknown
 		}
 		return FmtText
+
+	case OpenMetricsType:
+		if c, ok := params["charset"]; ok && c != "utf-8" {
+			return FmtUnknown
+		}
+		v, ok := params["version"]
+		if !ok {
+			return FmtOpenMetrics_1_0_0
+		}
+		switch v {
+		case OpenMetricsVersion_1_0_0:
+			return FmtOpenMetrics_1_0_0
+		case OpenMetricsVersion_0_0_1:
+			return FmtOpenMetrics_0_0_1
+		case OpenMetricsVersion_2_0_0:
+			return fmtOpenMetrics_2_0_0
+		}
 	}
 
 	return FmtUnknown
@@ -73,12 +112,16 @@ func ResponseFormat(h http.Header) Format {
 // NewDecoder returns a new decoder based on the given input format. Metric
 // names are validated based on the provided Format -- if the format requires
 // escaping, raditional Prometheues validity checking is used. Otherwise, names
-// are checked for UTF-8 validity. Supported formats include delimited protobuf
-// and Prometheus text format.  For historical reasons, this decoder fallbacks
-// to classic text decoding for any other format. This decoder does not fully
-// support OpenMetrics although it may often succeed due to the similarities
-// between the formats. This decoder may not support the latest features of
-// Prometheus text format and is not intended for high-performance applications.
+// are checked for UTF-8 validity. Supported formats include delimited protobuf,
+// Prometheus text format and OpenMetrics 1.0 and 2.0 text formats. For
+// historical reasons, this decoder fallbacks to classic text decoding for any
+// other format. This decoder may not support the latest features of Prometheus
+// text format and is not intended for high-performance applications.
+//
+// The Prometheus text format is parsed entirely on the first Decode call, and
+// the families are then returned sorted by name, with the samples of a family
+// merged even if they are not contiguous in the input. Use
+// TextParser.MetricFamilies to stream large inputs instead.
 // See: https://github.com/prometheus/common/issues/812
 func NewDecoder(r io.Reader, format Format) Decoder {
 	scheme := model.LegacyValidation
@@ -90,6 +133,13 @@ func NewDecoder(r io.Reader, format Format) Decoder {
 		return &protoDecoder{r: bufio.NewReader(r), s: scheme}
 	case TypeProtoText, TypeProtoCompact:
 		return &errDecoder{err: fmt.Errorf("format %s not supported for decoding", format)}
+	case TypeOpenMetrics:
+		p := NewOpenMetricsParser(scheme)
+		if _, params, err := mime.ParseMediaType(string(format)); err == nil && params["version"] == OpenMetricsVersion_2_0_0 {
+			p = NewOpenMetrics20Parser(scheme)
+		}
+		p.reset(r)
+		return &openMetricsDecoder{p: &p}
 	}
 	return &textDecoder{r: r, s: scheme}
 }
@@ -142,34 +192,61 @@ func (d *errDecoder) Decode(*dto.MetricFamily) error {
 // textDecoder implements the Decoder interface for the text protocol.
 type textDecoder struct {
 	r    io.Reader
-	fams map[string]*dto.MetricFamily
+	fams []*dto.MetricFamily
 	s    model.ValidationScheme
 	err  error
 }
 
-// Decode implements the Decoder interface.
+// Decode implements the Decoder interface. The whole input is parsed on the
+// first call, so that the families which are not contiguous in the input are
+// merged like TextToMetricFamilies does.
 func (d *textDecoder) Decode(v *dto.MetricFamily) error {
 	if d.err == nil {
 		// Read all metrics in one shot.
 		p := NewTextParser(d.s)
-		d.fams, d.err = p.TextToMetricFamilies(d.r)
+		var fams map[string]*dto.MetricFamily
+		fams, d.err = p.TextToMetricFamilies(d.r)
 		// If we don't get an error, store io.EOF for the end.
 		if d.err == nil {
 			d.err = io.EOF
 		}
+		d.fams = slices.SortedFunc(maps.Values(fams), func(a, b *dto.MetricFamily) int {
+			return strings.Compare(a.GetName(), b.GetName())
+		})
 	}
 	// Pick off one MetricFamily per Decode until there's nothing left.
-	for key, fam := range d.fams {
+	if len(d.fams) > 0 {
+		fam := d.fams[0]
+		d.fams = d.fams[1:]
 		v.Name = fam.Name
 		v.Help = fam.Help
 		v.Type = fam.Type
 		v.Metric = fam.Metric
-		delete(d.fams, key)
 		return nil
 	}
 	return d.err
 }
 
+// openMetricsDecoder implements the Decoder interface for the OpenMetrics text
+// format.
+type openMetricsDecoder struct {
+	p *OpenMetricsParser
+}
+
+// Decode implements the Decoder interface.
+func (d *openMetricsDecoder) Decode(v *dto.MetricFamily) error {
+	fam, err := d.p.next()
+	if err != nil {
+		return err
+	}
+	v.Name = fam.Name
+	v.Help = fam.Help
+	v.Type = fam.Type
+	v.Unit = fam.Unit
+	v.Metric = fam.Metric
+	return nil
+}
+
 // SampleDecoder wraps a Decoder to extract samples from the metric families
 // decoded by the wrapped Decoder.
 type SampleDecoder struct {
@@ -181,6 +258,8 @@ type SampleDecoder struct {
 
 // Decode calls the Decode method of the wrapped Decoder and then extracts the
 // samples from the decoded MetricFamily into the provided model.Vector.
+// Depending on Opts.Histograms, the vector contains float samples, native
+// histogram samples (with Histogram set) or both.
 func (sd *SampleDecoder) Decode(s *model.Vector) error {
 	err := sd.Dec.Decode(&sd.f)
 	if err != nil {
@@ -221,7 +300,7 @@ func extractSamples(f *dto.MetricFamily, o *DecodeOptions) (model.Vector, error)
 	case dto.MetricType_UNTYPED:
 		return extractUntyped(o, f), nil
 	case dto.MetricType_HISTOGRAM, dto.MetricType_GAUGE_HISTOGRAM:
-		return extractHistogram(o, f), nil
+		return extractHistogram(o, f)
 	}
 	return nil, fmt.Errorf("expfmt.extractSamples: unknown metric family type %v", f.GetType())
 }
@@ -376,7 +455,7 @@ func extractSummary(o *DecodeOptions, f *dto.MetricFamily) model.Vector {
 	return samples
 }
 
-func extractHistogram(o *DecodeOptions, f *dto.MetricFamily) model.Vector {
+func extractHistogram(o *DecodeOptions, f *dto.MetricFamily) (model.Vector, error) {
 	samples := make(model.Vector, 0, len(f.Metric))
 
 	for _, m := range f.Metric {
@@ -389,76 +468,246 @@ func extractHistogram(o *DecodeOptions, f *dto.MetricFamily) model.Vector {
 			timestamp = model.TimeFromUnixNano(*m.TimestampMs * 1000000)
 		}
 
-		infSeen := false
-
-		for _, q := range m.Histogram.Bucket {
-			lset := make(model.LabelSet, len(m.Label)+2)
+		native := isNativeHistogram(m.Histogram)
+		if native && o.Histograms != ClassicHistograms {
+			h, err := sampleHistogram(m.Histogram)
+			if err != nil {
+				return nil, fmt.Errorf("expfmt.extractSamples: histogram %q: %w", f.GetName(), err)
+			}
+			lset := make(model.LabelSet, len(m.Label)+1)
 			for _, p := range m.Label {
 				lset[model.LabelName(p.GetName())] = model.LabelValue(p.GetValue())
 			}
-			lset[model.LabelName(model.BucketLabel)] = model.LabelValue(fmt.Sprint(q.GetUpperBound()))
-			lset[model.MetricNameLabel] = model.LabelValue(f.GetName() + "_bucket")
-
-			if math.IsInf(q.GetUpperBound(), +1) {
-				infSeen = true
-			}
+			lset[model.MetricNameLabel] = model.LabelValue(f.GetName())
 
-			v := q.GetCumulativeCountFloat()
-			if v <= 0 {
-				v = float64(q.GetCumulativeCount())
-			}
 			samples = append(samples, &model.Sample{
 				Metric:    model.Metric(lset),
-				Value:     model.SampleValue(v),
 				Timestamp: timestamp,
+				Histogram: h,
 			})
+			if o.Histograms == NativeHistograms {
+				continue
+			}
 		}
 
-		lset := make(model.LabelSet, len(m.Label)+1)
+		samples = appendClassicHistogram(samples, f, m, timestamp)
+	}
+
+	return samples, nil
+}
+
+func appendClassicHistogram(samples model.Vector, f *dto.MetricFamily, m *dto.Metric, timestamp model.Time) model.Vector {
+	infSeen := false
+
+	for _, q := range m.Histogram.Bucket {
+		lset := make(model.LabelSet, len(m.Label)+2)
 		for _, p := range m.Label {
 			lset[model.LabelName(p.GetName())] = model.LabelValue(p.GetValue())
 		}
-		lset[model.MetricNameLabel] = model.LabelValue(f.GetName() + "_sum")
+		lset[model.LabelName(model.BucketLabel)] = model.LabelValue(fmt.Sprint(q.GetUpperBound()))
+		lset[model.MetricNameLabel] = model.LabelValue(f.GetName() + "_bucket")
 
+		if math.IsInf(q.GetUpperBound(), +1) {
+			infSeen = true
+		}
+
+		v := q.GetCumulativeCountFloat()
+		if v <= 0 {
+			v = float64(q.GetCumulativeCount())
+		}
 		samples = append(samples, &model.Sample{
 			Metric:    model.Metric(lset),
-			Value:     model.SampleValue(m.Histogram.GetSampleSum()),
+			Value:     model.SampleValue(v),
 			Timestamp: timestamp,
 		})
+	}
 
-		lset = make(model.LabelSet, len(m.Label)+1)
+	lset := make(model.LabelSet, len(m.Label)+1)
+	for _, p := range m.Label {
+		lset[model.LabelName(p.GetName())] = model.LabelValue(p.GetValue())
+	}
+	lset[model.MetricNameLabel] = model.LabelValue(f.GetName() + "_sum")
+
+	samples = append(samples, &model.Sample{
+		Metric:    model.Metric(lset),
+		Value:     model.SampleValue(m.Histogram.GetSampleSum()),
+		Timestamp: timestamp,
+	})
+
+	lset = make(model.LabelSet, len(m.Label)+1)
+	for _, p := range m.Label {
+		lset[model.LabelName(p.GetName())] = model.LabelValue(p.GetValue())
+	}
+	lset[model.MetricNameLabel] = model.LabelValue(f.GetName() + "_count")
+
+	v := m.Histogram.GetSampleCountFloat()
+	if v <= 0 {
+		v = float64(m.Histogram.GetSampleCount())
+	}
+	count := &model.Sample{
+		Metric:    model.Metric(lset),
+		Value:     model.SampleValue(v),
+		Timestamp: timestamp,
+	}
+	samples = append(samples, count)
+
+	if !infSeen {
+		// Append an infinity bucket sample.
+		lset := make(model.LabelSet, len(m.Label)+2)
 		for _, p := range m.Label {
 			lset[model.LabelName(p.GetName())] = model.LabelValue(p.GetValue())
 		}
-		lset[model.MetricNameLabel] = model.LabelValue(f.GetName() + "_count")
+		lset[model.LabelName(model.BucketLabel)] = model.LabelValue("+Inf")
+		lset[model.MetricNameLabel] = model.LabelValue(f.GetName() + "_bucket")
 
-		v := m.Histogram.GetSampleCountFloat()
-		if v <= 0 {
-			v = float64(m.Histogram.GetSampleCount())
-		}
-		count := &model.Sample{
+		samples = append(samples, &model.Sample{
 			Metric:    model.Metric(lset),
-			Value:     model.SampleValue(v),
+			Value:     count.Value,
 			Timestamp: timestamp,
-		}
-		samples = append(samples, count)
+		})
+	}
 
-		if !infSeen {
-			// Append an infinity bucket sample.
-			lset := make(model.LabelSet, len(m.Label)+2)
-			for _, p := range m.Label {
-				lset[model.LabelName(p.GetName())] = model.LabelValue(p.GetValue())
-			}
-			lset[model.LabelName(model.BucketLabel)] = model.LabelValue("+Inf")
-			lset[model.MetricNameLabel] = model.LabelValue(f.GetName() + "_bucket")
+	return samples
+}
 
-			samples = append(samples, &model.Sample{
-				Metric:    model.Metric(lset),
-				Value:     count.Value,
-				Timestamp: timestamp,
-			})
+// isNativeHistogram reports whether h carries native histogram data. An empty
+// native histogram is still recognized by its schema.
+func isNativeHistogram(h *dto.Histogram) bool {
+	return h.Schema != nil ||
+		h.GetZeroThreshold() > 0 ||
+		h.GetZeroCount() > 0 ||
+		h.GetZeroCountFloat() > 0 ||
+		len(h.GetNegativeSpan()) > 0 ||
+		len(h.GetPositiveSpan()) > 0
+}
+
+// sampleHistogram converts the native part of h into a model.SampleHistogram.
+// Buckets are ordered from the lowest negative bucket over the zero bucket to
+// the highest positive bucket. Empty buckets are omitted.
+func sampleHistogram(h *dto.Histogram) (*model.SampleHistogram, error) {
+	schema := h.GetSchema()
+	if schema < -4 || schema > 8 {
+		return nil, fmt.Errorf("unsupported native histogram schema %d", schema)
+	}
+	negative, err := nativeBuckets(h.GetNegativeSpan(), h.GetNegativeDelta(), h.GetNegativeCount())
+	if err != nil {
+		return nil, fmt.Errorf("negative buckets: %w", err)
+	}
+	positive, err := nativeBuckets(h.GetPositiveSpan(), h.GetPositiveDelta(), h.GetPositiveCount())
+	if err != nil {
+		return nil, fmt.Errorf("positive buckets: %w", err)
+	}
+
+	count := h.GetSampleCountFloat()
+	if count <= 0 {
+		count = float64(h.GetSampleCount())
+	}
+	zeroCount := h.GetZeroCountFloat()
+	if zeroCount <= 0 {
+		zeroCount = float64(h.GetZeroCount())
+	}
+
+	sh := &model.SampleHistogram{
+		Count:   model.FloatString(count),
+		Sum:     model.FloatString(h.GetSampleSum()),
+		Buckets: make(model.HistogramBuckets, 0, len(negative)+len(positive)+1),
+	}
+	for i := len(negative) - 1; i >= 0; i-- {
+		b := negative[i]
+		sh.Buckets = append(sh.Buckets, &model.HistogramBucket{
+			Boundaries: 1, // [lower,upper)
+			Lower:      model.FloatString(-nativeBucketBound(b.index, schema)),
+			Upper:      model.FloatString(-nativeBucketBound(b.index-1, schema)),
+			Count:      model.FloatString(b.count),
+		})
+	}
+	if zeroCount > 0 {
+		zt := h.GetZeroThreshold()
+		sh.Buckets = append(sh.Buckets, &model.HistogramBucket{
+			Boundaries: 3, // [lower,upper]
+			Lower:      model.FloatString(-zt),
+			Upper:      model.FloatString(zt),
+			Count:      model.FloatString(zeroCount),
+		})
+	}
+	for _, b := range positive {
+		sh.Buckets = append(sh.Buckets, &model.HistogramBucket{
+			Boundaries: 0, // (lower,upper]
+			Lower:      model.FloatString(nativeBucketBound(b.index-1, schema)),
+			Upper:      model.FloatString(nativeBucketBound(b.index, schema)),
+			Count:      model.FloatString(b.count),
+		})
+	}
+	return sh, nil
+}
+
+type nativeBucket struct {
+	index int32
+	count float64
+}
+
+// nativeBuckets resolves the spans of one side of a native histogram into
+// indexed buckets with absolute counts, taken from deltas for integer
+// histograms and from counts for float histograms. Empty buckets are skipped.
+func nativeBuckets(spans []*dto.BucketSpan, deltas []int64, counts []float64) ([]nativeBucket, error) {
+	n := 0
+	for _, s := range spans {
+		n += int(s.GetLength())
+	}
+	isFloat := len(deltas) == 0 && len(counts) > 0
+	if given := max(len(deltas), len(counts)); given != n || (len(deltas) > 0 && len(counts) > 0) {
+		return nil, fmt.Errorf("spans cover %d buckets, but %d deltas and %d counts are given", n, len(deltas), len(counts))
+	}
+
+	var (
+		buckets = make([]nativeBucket, 0, n)
+		index   int32
+		current int64
+		i       int
+	)
+	for j, s := range spans {
+		if j == 0 {
+			index = s.GetOffset()
+		} else {
+			index += s.GetOffset()
+		}
+		for k := uint32(0); k < s.GetLength(); k++ {
+			var count float64
+			if isFloat {
+				count = counts[i]
+			} else {
+				current += deltas[i]
+				if current < 0 {
+					return nil, fmt.Errorf("negative count in bucket %d", index)
+				}
+				count = float64(current)
+			}
+			if count != 0 {
+				buckets = append(buckets, nativeBucket{index: index, count: count})
+			}
+			index++
+			i++
 		}
 	}
+	return buckets, nil
+}
 
-	return samples
+// nativeBucketBound returns the upper bound of the bucket with the given index
+// for an exponential native histogram schema.
+func nativeBucketBound(index, schema int32) float64 {
+	if schema < 0 {
+		exp := int(index) << -schema
+		if exp == 1024 {
+			// The bucket would end at 2^1024, which is not representable.
+			return math.MaxFloat64
+		}
+		return math.Ldexp(1, exp)
+	}
+	fracIndex := index & (1<<schema - 1)
+	frac := math.Exp2(float64(fracIndex)/float64(int32(1)<<schema)) / 2
+	exp := (int(index) >> schema) + 1
+	if fracIndex == 0 && exp == 1025 {
+		return math.MaxFloat64
+	}
+	return math.Ldexp(frac, exp)
 }
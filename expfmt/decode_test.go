@@ -454,6 +454,26 @@ func testDiscriminatorHTTPHeader(t testing.TB) {
 			input:  map[string]string{"Content-Type": `text/plain; version=0.0.3`},
 			output: FmtUnknown,
 		},
+		{
+			input:  map[string]string{"Content-Type": `application/openmetrics-text; version=1.0.0; charset=utf-8`},
+			output: FmtOpenMetrics_1_0_0,
+		},
+		{
+			input:  map[string]string{"Content-Type": `application/openmetrics-text`},
+			output: FmtOpenMetrics_1_0_0,
+		},
+		{
+			input:  map[string]string{"Content-Type": `application/openmetrics-text; version=0.0.1`},
+			output: FmtOpenMetrics_0_0_1,
+		},
+		{
+			input:  map[string]string{"Content-Type": `application/openmetrics-text; version=2.0.0; charset=utf-8`},
+			output: fmtOpenMetrics_2_0_0,
+		},
+		{
+			input:  map[string]string{"Content-Type": `application/openmetrics-text; version=1.0.0; charset=latin1`},
+			output: FmtUnknown,
+		},
 	}
 
 	for i, scenario := range scenarios {
@@ -550,6 +570,113 @@ func TestExtractSamples(t *testing.T) {
 	}
 }
 
+func TestExtractNativeHistograms(t *testing.T) {
+	fam := &dto.MetricFamily{
+		Name: proto.String("latency_seconds"),
+		Type: dto.MetricType_HISTOGRAM.Enum(),
+		Metric: []*dto.Metric{
+			{
+				Label: []*dto.LabelPair{{Name: proto.String("job"), Value: proto.String("a")}},
+				Histogram: &dto.Histogram{
+					SampleCount:   proto.Uint64(8),
+					SampleSum:     proto.Float64(10),
+					Bucket:        []*dto.Bucket{{UpperBound: proto.Float64(1), CumulativeCount: proto.Uint64(4)}},
+					Schema:        proto.Int32(0),
+					ZeroThreshold: proto.Float64(0.001),
+					ZeroCount:     proto.Uint64(1),
+					NegativeSpan:  []*dto.BucketSpan{{Offset: proto.Int32(0), Length: proto.Uint32(1)}},
+					NegativeDelta: []int64{2},
+					PositiveSpan: []*dto.BucketSpan{
+						{Offset: proto.Int32(0), Length: proto.Uint32(2)},
+						{Offset: proto.Int32(1), Length: proto.Uint32(1)},
+					},
+					PositiveDelta: []int64{1, -1, 3},
+				},
+				TimestampMs: proto.Int64(1000),
+			},
+			{
+				Histogram: &dto.Histogram{
+					SampleCountFloat: proto.Float64(2.5),
+					SampleSum:        proto.Float64(3),
+					Schema:           proto.Int32(-1),
+					PositiveSpan:     []*dto.BucketSpan{{Offset: proto.Int32(1), Length: proto.Uint32(1)}},
+					PositiveCount:    []float64{2.5},
+				},
+			},
+		},
+	}
+
+	native := model.Vector{
+		{
+			Metric:    model.Metric{model.MetricNameLabel: "latency_seconds", "job": "a"},
+			Timestamp: 1000,
+			Histogram: &model.SampleHistogram{
+				Count: 8,
+				Sum:   10,
+				Buckets: model.HistogramBuckets{
+					{Boundaries: 1, Lower: -1, Upper: -0.5, Count: 2},
+					{Boundaries: 3, Lower: -0.001, Upper: 0.001, Count: 1},
+					{Boundaries: 0, Lower: 0.5, Upper: 1, Count: 1},
+					{Boundaries: 0, Lower: 4, Upper: 8, Count: 3},
+				},
+			},
+		},
+		{
+			Metric:    model.Metric{model.MetricNameLabel: "latency_seconds"},
+			Timestamp: 42,
+			Histogram: &model.SampleHistogram{
+				Count: 2.5,
+				Sum:   3,
+				Buckets: model.HistogramBuckets{
+					{Boundaries: 0, Lower: 1, Upper: 4, Count: 2.5},
+				},
+			},
+		},
+	}
+
+	got, err := ExtractSamples(&DecodeOptions{Timestamp: 42, Histograms: NativeHistograms}, fam)
+	require.NoError(t, err)
+	require.Equal(t, native, got)
+
+	got, err = ExtractSamples(&DecodeOptions{Timestamp: 42}, fam)
+	require.NoError(t, err)
+	require.Len(t, got, 7)
+	for _, s := range got {
+		require.Nil(t, s.Histogram)
+	}
+
+	got, err = ExtractSamples(&DecodeOptions{Timestamp: 42, Histograms: ClassicAndNativeHistograms}, fam)
+	require.NoError(t, err)
+	require.Len(t, got, 9)
+	require.Equal(t, native[0], got[0])
+	require.Equal(t, model.LabelValue("latency_seconds_bucket"), got[1].Metric[model.MetricNameLabel])
+	require.Equal(t, native[1], got[5])
+
+	fam.Metric[0].Histogram.PositiveDelta = []int64{1}
+	_, err = ExtractSamples(&DecodeOptions{Histograms: NativeHistograms}, fam)
+	require.EqualError(t, err, `expfmt.extractSamples: histogram "latency_seconds": positive buckets: spans cover 3 buckets, but 1 deltas and 0 counts are given`)
+}
+
+func TestNativeBucketBound(t *testing.T) {
+	scenarios := []struct {
+		index, schema int32
+		want          float64
+	}{
+		{index: 0, schema: 0, want: 1},
+		{index: -1, schema: 0, want: 0.5},
+		{index: 3, schema: 0, want: 8},
+		{index: 1, schema: 1, want: math.Sqrt2},
+		{index: -3, schema: 2, want: 0.5 * math.Pow(2, 0.25)},
+		{index: 1, schema: -1, want: 4},
+		{index: -1, schema: -2, want: 1.0 / 16},
+		{index: 256, schema: -2, want: math.MaxFloat64},
+		{index: 1024, schema: 0, want: math.MaxFloat64},
+	}
+	for _, s := range scenarios {
+		require.InDeltaf(t, s.want, nativeBucketBound(s.index, s.schema), 1e-12*s.want, "index %d, schema %d", s.index, s.schema)
+	}
+}
+
 func TestTextDecoderWithBufioReader(t *testing.T) {
 	example := `
 	# TYPE foo gauge
@@ -577,3 +704,64 @@ func TestTextDecoderWithBufioReader(t *testing.T) {
 	}
 	require.Truef(t, decoded, "Metric foo not decoded")
 }
+
+func TestTextDecoderMergesFamilies(t *testing.T) {
+	dec := NewDecoder(strings.NewReader("b 2\na 1\nc 3\na{x=\"1\"} 4\n"), FmtText)
+	var (
+		names   []string
+		metrics []int
+	)
+	for {
+		var mf dto.MetricFamily
+		err := dec.Decode(&mf)
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		require.NoError(t, err)
+		names = append(names, mf.GetName())
+		metrics = append(metrics, len(mf.GetMetric()))
+	}
+	// The samples of a are merged, and the families are sorted by name.
+	require.Equal(t, []string{"a", "b", "c"}, names)
+	require.Equal(t, []int{2, 1, 1}, metrics)
+
+	readErr := errors.New("connection reset")
+	r := io.MultiReader(strings.NewReader("# TYPE a counter\na 1\nb 2\n"), &errReader{err: readErr})
+	dec = NewDecoder(r, FmtText)
+	// The families read before the error are returned first.
+	var mf dto.MetricFamily
+	require.NoError(t, dec.Decode(&mf))
+	require.Equal(t, "a", mf.GetName())
+	require.NoError(t, dec.Decode(&mf))
+	require.Equal(t, "b", mf.GetName())
+	require.ErrorIs(t, dec.Decode(&mf), readErr)
+	require.ErrorIs(t, dec.Decode(&mf), readErr)
+}
+
+func TestOpenMetricsDecoder(t *testing.T) {
+	in := `# TYPE foo counter
+foo_total 1.0
+# TYPE bar gauge
+bar{a="b"} 2.0
+bar{a="c"} 3.0
+# EOF
+`
+	dec := NewDecoder(strings.NewReader(in), FmtOpenMetrics_1_0_0)
+	var names []string
+	for {
+		var mf dto.MetricFamily
+		err := dec.Decode(&mf)
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		require.NoError(t, err)
+		names = append(names, mf.GetName())
+	}
+	// The families are decoded in the order of the input.
+	require.Equal(t, []string{"foo_total", "bar"}, names)
+
+	dec = NewDecoder(strings.NewReader("foo 1\n"), FmtOpenMetrics_1_0_0)
+	var mf dto.MetricFamily
+	require.EqualError(t, dec.Decode(&mf), "text format parsing error in line 2: unexpected end of input stream, missing # EOF")
+	require.EqualError(t, dec.Decode(&mf), "text format parsing error in line 2: unexpected end of input stream, missing # EOF")
+}
@@ -0,0 +1,463 @@
+// Copyright 2026 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package expfmt
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"slices"
+	"strconv"
+
+	dto "github.com/prometheus/client_model/go"
+	"google.golang.org/protobuf/proto"
+
+	"github.com/prometheus/common/model"
+)
+
+// NewOpenMetrics20Parser returns a new OpenMetricsParser for the OpenMetrics
+// 2.0 text format with the provided nameValidationScheme.
+//
+// In OpenMetrics 2.0, the sample names are the family names, without suffix.
+// Counters carry their created timestamp as a `st@` start timestamp, and
+// summaries and histograms are written on a single line with a composite
+// value:
+//
+//	foo {count:17,sum:324.5,bucket:[0.1:8,1:10,+Inf:17]} st@1520430000
+//	bar {count:3,sum:1.5,schema:0,zero_threshold:1e-128,zero_count:1,positive_spans:[0:2],positive_buckets:[1,1]}
+//	baz {count:17,sum:324.5,quantile:[0.5:1.2,0.99:3.4]}
+//
+// The bucket counts of native histograms are absolute counts, which are
+// delta-encoded in the MetricFamily. Histograms with non-integer counts are
+// returned as float histograms.
+//
+// MetricFamilyToOpenMetrics20 does not write summaries and histograms yet, so
+// only counters, gauges and untyped metrics round-trip through the writer and
+// this parser.
+//
+// NOTE: Like MetricFamilyToOpenMetrics20, this implements OpenMetrics 2.0-rc.0
+// which is experimental. Breaking changes might happen in the future.
+func NewOpenMetrics20Parser(nameValidationScheme model.ValidationScheme) OpenMetricsParser {
+	return OpenMetricsParser{scheme: nameValidationScheme, v2: true}
+}
+
+// omComposite is the composite value of an OpenMetrics 2.0 summary or
+// histogram.
+type omComposite struct {
+	fields []string
+	count  *float64
+	sum    *float64
+	// quantiles and buckets are pairs of quantiles and values, and of upper
+	// bounds and cumulative counts.
+	quantiles [][2]float64
+	buckets   [][2]float64
+	// The fields of native histograms.
+	schema          *int32
+	zeroThreshold   *float64
+	zeroCount       *float64
+	negativeSpans   []*dto.BucketSpan
+	negativeBuckets []float64
+	positiveSpans   []*dto.BucketSpan
+	positiveBuckets []float64
+}
+
+// readComposite reads a composite value.
+func (l *omLexer) readComposite() (*omComposite, error) {
+	c := &omComposite{}
+	l.consume('{')
+	if l.consume('}') {
+		return c, nil
+	}
+	for {
+		field := l.readBareName(false)
+		if field == "" {
+			return nil, fmt.Errorf("invalid field name start %q", l.peek())
+		}
+		if slices.Contains(c.fields, field) {
+			return nil, fmt.Errorf("duplicate field %q", field)
+		}
+		c.fields = append(c.fields, field)
+		if !l.consume(':') {
+			return nil, fmt.Errorf("missing value for field %q", field)
+		}
+		var err error
+		switch field {
+		case "count":
+			c.count, err = l.readCompositeFloat()
+		case "sum":
+			c.sum, err = l.readCompositeFloat()
+		case "zero_threshold":
+			c.zeroThreshold, err = l.readCompositeFloat()
+		case "zero_count":
+			c.zeroCount, err = l.readCompositeFloat()
+		case "schema":
+			var schema int64
+			if schema, err = strconv.ParseInt(l.readCompositeToken(), 10, 32); err == nil {
+				c.schema = proto.Int32(int32(schema))
+			}
+		case "quantile":
+			c.quantiles, err = l.readCompositePairs()
+		case "bucket":
+			c.buckets, err = l.readCompositePairs()
+		case "negative_spans":
+			c.negativeSpans, err = l.readCompositeSpans()
+		case "positive_spans":
+			c.positiveSpans, err = l.readCompositeSpans()
+		case "negative_buckets":
+			c.negativeBuckets, err = l.readCompositeBuckets()
+		case "positive_buckets":
+			c.positiveBuckets, err = l.readCompositeBuckets()
+		default:
+			return nil, fmt.Errorf("unknown field %q", field)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("invalid value for field %q: %w", field, err)
+		}
+		if l.consume('}') {
+			return c, nil
+		}
+		if !l.consume(',') {
+			return nil, errors.New("expected ',' between fields or '}' at the end of the value")
+		}
+	}
+}
+
+// readCompositeToken reads a number within a composite value.
+func (l *omLexer) readCompositeToken() string {
+	start := l.pos
+	for !l.done() {
+		switch l.s[l.pos] {
+		case ',', ':', ']', '}', ' ':
+			return l.s[start:l.pos]
+		}
+		l.pos++
+	}
+	return l.s[start:l.pos]
+}
+
+func (l *omLexer) readCompositeFloat() (*float64, error) {
+	v, err := parseOpenMetricsFloat(l.readCompositeToken())
+	if err != nil {
+		return nil, err
+	}
+	return &v, nil
+}
+
+// readCompositeList reads a bracketed list, calling item for each of its
+// items.
+func (l *omLexer) readCompositeList(item func() error) error {
+	if !l.consume('[') {
+		return errors.New("expected '['")
+	}
+	if l.consume(']') {
+		return nil
+	}
+	for {
+		if err := item(); err != nil {
+			return err
+		}
+		if l.consume(']') {
+			return nil
+		}
+		if !l.consume(',') {
+			return errors.New("expected ',' between items or ']' at the end of the list")
+		}
+	}
+}
+
+// readCompositePairs reads a list of colon-separated pairs of numbers.
+func (l *omLexer) readCompositePairs() ([][2]float64, error) {
+	var pairs [][2]float64
+	err := l.readCompositeList(func() error {
+		k, err := parseOpenMetricsFloat(l.readCompositeToken())
+		if err != nil {
+			return err
+		}
+		if !l.consume(':') {
+			return errors.New("expected ':' within pair")
+		}
+		v, err := parseOpenMetricsFloat(l.readCompositeToken())
+		if err != nil {
+			return err
+		}
+		pairs = append(pairs, [2]float64{k, v})
+		return nil
+	})
+	return pairs, err
+}
+
+// readCompositeSpans reads a list of offset:length bucket spans.
+func (l *omLexer) readCompositeSpans() ([]*dto.BucketSpan, error) {
+	var spans []*dto.BucketSpan
+	err := l.readCompositeList(func() error {
+		offset, err := strconv.ParseInt(l.readCompositeToken(), 10, 32)
+		if err != nil {
+			return err
+		}
+		if !l.consume(':') {
+			return errors.New("expected ':' within span")
+		}
+		length, err := strconv.ParseUint(l.readCompositeToken(), 10, 32)
+		if err != nil {
+			return err
+		}
+		spans = append(spans, &dto.BucketSpan{Offset: proto.Int32(int32(offset)), Length: proto.Uint32(uint32(length))})
+		return nil
+	})
+	return spans, err
+}
+
+// readCompositeBuckets reads a list of bucket counts.
+func (l *omLexer) readCompositeBuckets() ([]float64, error) {
+	var counts []float64
+	err := l.readCompositeList(func() error {
+		v, err := parseOpenMetricsFloat(l.readCompositeToken())
+		if err != nil {
+			return err
+		}
+		counts = append(counts, v)
+		return nil
+	})
+	return counts, err
+}
+
+// allow returns an error if the composite value has other fields than the
+// given ones.
+func (c *omComposite) allow(typ string, fields ...string) error {
+	for _, f := range c.fields {
+		if !slices.Contains(fields, f) {
+			return fmt.Errorf("unexpected field %q in %s value", f, typ)
+		}
+	}
+	return nil
+}
+
+func (c *omComposite) summary() (*dto.Summary, error) {
+	if err := c.allow("summary", "count", "sum", "quantile"); err != nil {
+		return nil, err
+	}
+	if c.count == nil {
+		return nil, errors.New("missing count in summary value")
+	}
+	if !isIntegerCount(*c.count) {
+		return nil, fmt.Errorf("summary count must be a non-negative integer, not %v", *c.count)
+	}
+	s := &dto.Summary{
+		SampleCount: proto.Uint64(uint64(*c.count)),
+		SampleSum:   c.sum,
+	}
+	for _, q := range c.quantiles {
+		if q[0] < 0 || q[0] > 1 {
+			return nil, fmt.Errorf("invalid quantile %v", q[0])
+		}
+		s.Quantile = append(s.Quantile, &dto.Quantile{Quantile: proto.Float64(q[0]), Value: proto.Float64(q[1])})
+	}
+	return s, nil
+}
+
+func (c *omComposite) histogram() (*dto.Histogram, error) {
+	err := c.allow("histogram", "count", "sum", "bucket", "schema", "zero_threshold", "zero_count",
+		"negative_spans", "negative_buckets", "positive_spans", "positive_buckets")
+	if err != nil {
+		return nil, err
+	}
+	if c.count == nil {
+		return nil, errors.New("missing count in histogram value")
+	}
+	if c.schema == nil && (c.zeroThreshold != nil || c.zeroCount != nil || c.negativeSpans != nil ||
+		c.negativeBuckets != nil || c.positiveSpans != nil || c.positiveBuckets != nil) {
+		return nil, errors.New("native histogram fields without schema")
+	}
+
+	// The histogram is an integer one if all its counts are integers.
+	counts := []float64{*c.count}
+	for _, b := range c.buckets {
+		counts = append(counts, b[1])
+	}
+	if c.zeroCount != nil {
+		counts = append(counts, *c.zeroCount)
+	}
+	counts = append(counts, c.negativeBuckets...)
+	counts = append(counts, c.positiveBuckets...)
+	isInt := true
+	for _, v := range counts {
+		if v < 0 || math.IsNaN(v) || math.IsInf(v, 0) {
+			return nil, fmt.Errorf("invalid count %v in histogram value", v)
+		}
+		isInt = isInt && v == math.Trunc(v)
+	}
+
+	h := &dto.Histogram{SampleSum: c.sum}
+	if isInt {
+		h.SampleCount = proto.Uint64(uint64(*c.count))
+	} else {
+		h.SampleCountFloat = c.count
+	}
+
+	for i, b := range c.buckets {
+		if i > 0 && (b[0] <= c.buckets[i-1][0] || b[1] < c.buckets[i-1][1]) {
+			return nil, errors.New("histogram buckets out of order or decreasing")
+		}
+		bucket := &dto.Bucket{UpperBound: proto.Float64(b[0])}
+		if isInt {
+			bucket.CumulativeCount = proto.Uint64(uint64(b[1]))
+		} else {
+			bucket.CumulativeCountFloat = proto.Float64(b[1])
+		}
+		h.Bucket = append(h.Bucket, bucket)
+	}
+	if n := len(c.buckets); n > 0 {
+		if inf := c.buckets[n-1]; !math.IsInf(inf[0], +1) {
+			return nil, errors.New("histogram has no +Inf bucket")
+		} else if inf[1] != *c.count {
+			return nil, fmt.Errorf("histogram count %v differs from its +Inf bucket count %v", *c.count, inf[1])
+		}
+	}
+
+	if c.schema == nil {
+		return h, nil
+	}
+	h.Schema = c.schema
+	h.ZeroThreshold = c.zeroThreshold
+	if c.zeroCount != nil {
+		if isInt {
+			h.ZeroCount = proto.Uint64(uint64(*c.zeroCount))
+		} else {
+			h.ZeroCountFloat = c.zeroCount
+		}
+	}
+	h.NegativeSpan = c.negativeSpans
+	h.PositiveSpan = c.positiveSpans
+	if err := checkSpans("negative", c.negativeSpans, c.negativeBuckets); err != nil {
+		return nil, err
+	}
+	if err := checkSpans("positive", c.positiveSpans, c.positiveBuckets); err != nil {
+		return nil, err
+	}
+	if isInt {
+		h.NegativeDelta = deltas(c.negativeBuckets)
+		h.PositiveDelta = deltas(c.positiveBuckets)
+	} else {
+		h.NegativeCount = c.negativeBuckets
+		h.PositiveCount = c.positiveBuckets
+	}
+	return h, nil
+}
+
+// checkSpans checks that the bucket spans cover the given buckets.
+func checkSpans(sign string, spans []*dto.BucketSpan, buckets []float64) error {
+	var n uint32
+	for _, s := range spans {
+		n += s.GetLength()
+	}
+	if int(n) != len(buckets) {
+		return fmt.Errorf("%s spans cover %d buckets, but %d are given", sign, n, len(buckets))
+	}
+	return nil
+}
+
+// deltas delta-encodes the absolute bucket counts.
+func deltas(counts []float64) []int64 {
+	if counts == nil {
+		return nil
+	}
+	d := make([]int64, len(counts))
+	var prev int64
+	for i, c := range counts {
+		d[i] = int64(c) - prev
+		prev = int64(c)
+	}
+	return d
+}
+
+func isIntegerCount(v float64) bool {
+	return v >= 0 && v == math.Trunc(v) && !math.IsInf(v, 0)
+}
+
+// addSample20 adds an OpenMetrics 2.0 sample, which is a full metric point.
+func (f *omFamily) addSample20(s *omSample) error {
+	if err := f.checkDuplicate(s); err != nil {
+		return err
+	}
+	composite := f.typ == "summary" || f.typ == "histogram" || f.typ == "gaugehistogram"
+	switch {
+	case composite && s.composite == nil:
+		return fmt.Errorf("%s %q must have a composite value", f.typ, s.name)
+	case !composite && s.composite != nil:
+		return fmt.Errorf("%s %q cannot have a composite value", f.typ, s.name)
+	case s.st != nil && f.typ != "counter" && f.typ != "summary" && f.typ != "histogram":
+		return fmt.Errorf("start timestamps are only allowed on counters, summaries and histograms, not on metric %q", s.name)
+	case len(s.exemplars) > 0 && f.typ != "counter" && f.typ != "histogram" && f.typ != "gaugehistogram":
+		return fmt.Errorf("exemplars are only allowed on counters and histograms, not on metric %q", s.name)
+	case len(s.exemplars) > 1 && f.typ == "counter":
+		return fmt.Errorf("counter %q has more than one exemplar", s.name)
+	}
+
+	m := &dto.Metric{Label: s.labels, TimestampMs: s.ts}
+	switch f.typ {
+	case "counter":
+		if s.value < 0 || math.IsNaN(s.value) {
+			return fmt.Errorf("counter %q has invalid value %v", s.name, s.value)
+		}
+		m.Counter = &dto.Counter{Value: proto.Float64(s.value), CreatedTimestamp: s.st}
+		if len(s.exemplars) > 0 {
+			m.Counter.Exemplar = s.exemplars[0]
+		}
+	case "gauge", "info", "stateset":
+		if err := f.checkGauge(s); err != nil {
+			return err
+		}
+		m.Gauge = &dto.Gauge{Value: proto.Float64(s.value)}
+	case "unknown":
+		m.Untyped = &dto.Untyped{Value: proto.Float64(s.value)}
+	case "summary":
+		summary, err := s.composite.summary()
+		if err != nil {
+			return fmt.Errorf("summary %q: %w", s.name, err)
+		}
+		summary.CreatedTimestamp = s.st
+		m.Summary = summary
+	case "histogram", "gaugehistogram":
+		h, err := s.composite.histogram()
+		if err != nil {
+			return fmt.Errorf("%s %q: %w", f.typ, s.name, err)
+		}
+		h.CreatedTimestamp = s.st
+		addHistogramExemplars(h, s.exemplars)
+		m.Histogram = h
+	}
+	f.mf.Metric = append(f.mf.Metric, m)
+	return nil
+}
+
+// addHistogramExemplars attaches the exemplars of a classic histogram to the
+// first bucket they fall into, and the ones of a native histogram or not
+// fitting in a bucket to the histogram itself.
+func addHistogramExemplars(h *dto.Histogram, exemplars []*dto.Exemplar) {
+	for _, e := range exemplars {
+		if h.Schema == nil {
+			i, _ := slices.BinarySearchFunc(h.Bucket, e.GetValue(), func(b *dto.Bucket, v float64) int {
+				if b.GetUpperBound() < v {
+					return -1
+				}
+				return 1
+			})
+			if i < len(h.Bucket) && h.Bucket[i].Exemplar == nil {
+				h.Bucket[i].Exemplar = e
+				continue
+			}
+		}
+		h.Exemplars = append(h.Exemplars, e)
+	}
+}
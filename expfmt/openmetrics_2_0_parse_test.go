@@ -0,0 +1,302 @@
+// Copyright 2026 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package expfmt
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"math"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	dto "github.com/prometheus/client_model/go"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/timestamppb"
+
+	"github.com/prometheus/common/model"
+)
+
+func TestOpenMetrics20RoundTrip(t *testing.T) {
+	fams := []*dto.MetricFamily{
+		{
+			Name: proto.String("requests_total"),
+			Help: proto.String("Requests \"served\"\nso far."),
+			Type: dto.MetricType_COUNTER.Enum(),
+			Metric: []*dto.Metric{
+				{
+					Label: []*dto.LabelPair{{Name: proto.String("code"), Value: proto.String("200")}},
+					Counter: &dto.Counter{
+						Value: proto.Float64(10),
+						Exemplar: &dto.Exemplar{
+							Label:     []*dto.LabelPair{{Name: proto.String("trace_id"), Value: proto.String("abc")}},
+							Value:     proto.Float64(1.5),
+							Timestamp: &timestamppb.Timestamp{Seconds: 1520879607, Nanos: 123456789},
+						},
+						CreatedTimestamp: &timestamppb.Timestamp{Seconds: 1520430000, Nanos: 1000},
+					},
+					TimestampMs: proto.Int64(1520879607789),
+				},
+				{
+					Label:   []*dto.LabelPair{{Name: proto.String("code"), Value: proto.String("500")}},
+					Counter: &dto.Counter{Value: proto.Float64(0)},
+				},
+			},
+		},
+		{
+			Name: proto.String("temperature_celsius"),
+			Type: dto.MetricType_GAUGE.Enum(),
+			Unit: proto.String("celsius"),
+			Metric: []*dto.Metric{
+				{Gauge: &dto.Gauge{Value: proto.Float64(-3.5)}},
+			},
+		},
+		{
+			Name: proto.String("my.metric"),
+			Type: dto.MetricType_UNTYPED.Enum(),
+			Metric: []*dto.Metric{
+				{
+					Label:   []*dto.LabelPair{{Name: proto.String("service.name"), Value: proto.String("a\\b")}},
+					Untyped: &dto.Untyped{Value: proto.Float64(math.Inf(-1))},
+				},
+			},
+		},
+	}
+
+	var buf bytes.Buffer
+	for _, mf := range fams {
+		_, err := MetricFamilyToOpenMetrics20(&buf, mf)
+		require.NoError(t, err)
+	}
+	_, err := FinalizeOpenMetrics(&buf)
+	require.NoError(t, err)
+
+	dec := NewDecoder(&buf, fmtOpenMetrics_2_0_0.WithEscapingScheme(model.NoEscaping))
+	for _, expected := range fams {
+		var mf dto.MetricFamily
+		require.NoError(t, dec.Decode(&mf))
+		require.Truef(t, proto.Equal(expected, &mf), "expected %s, got %s", expected, &mf)
+	}
+	var mf dto.MetricFamily
+	require.ErrorIs(t, dec.Decode(&mf), io.EOF)
+}
+
+// TestOpenMetrics20Golden parses the examples of the OpenMetrics 2.0
+// specification in testdata/openmetrics2 and compares the result with the
+// metric families in the JSON file of the same name.
+func TestOpenMetrics20Golden(t *testing.T) {
+	files, err := filepath.Glob("testdata/openmetrics2/*.txt")
+	require.NoError(t, err)
+	require.NotEmpty(t, files)
+	for _, file := range files {
+		t.Run(filepath.Base(file), func(t *testing.T) {
+			in, err := os.ReadFile(file)
+			require.NoError(t, err)
+			golden, err := os.ReadFile(strings.TrimSuffix(file, ".txt") + ".json")
+			require.NoError(t, err)
+
+			var raw []json.RawMessage
+			require.NoError(t, json.Unmarshal(golden, &raw))
+			expected := make([]*dto.MetricFamily, 0, len(raw))
+			for _, r := range raw {
+				mf := &dto.MetricFamily{}
+				require.NoError(t, protojson.Unmarshal(r, mf))
+				expected = append(expected, mf)
+			}
+
+			p := NewOpenMetrics20Parser(model.UTF8Validation)
+			fams, err := p.OpenMetricsToMetricFamilies(bytes.NewReader(in))
+			require.NoError(t, err)
+			requireMetricFamilies(t, expected, fams)
+		})
+	}
+}
+
+func TestOpenMetrics20ParseComposite(t *testing.T) {
+	in := `# TYPE rpc_seconds summary
+rpc_seconds{job="a"} {count:17,sum:324.5,quantile:[0.5:1.2,0.99:3.4]} 100 st@50.5
+# TYPE latency_seconds histogram
+latency_seconds {count:17,sum:324.5,bucket:[0.1:8,1:10,+Inf:17]} # {trace_id="a"} 0.05 90 # {trace_id="b"} 5 91
+# TYPE size_bytes histogram
+size_bytes {count:6,sum:12.5,schema:1,zero_threshold:0.001,zero_count:1,negative_spans:[0:1],negative_buckets:[1],positive_spans:[0:2,3:1],positive_buckets:[1,2,1]} st@10 # {trace_id="c"} 3 95
+# TYPE weights gaugehistogram
+weights {count:2.5,sum:3,bucket:[1:0.5,+Inf:2.5]}
+# EOF
+`
+	p := NewOpenMetrics20Parser(model.LegacyValidation)
+	fams, err := p.OpenMetricsToMetricFamilies(strings.NewReader(in))
+	require.NoError(t, err)
+	requireMetricFamilies(t, []*dto.MetricFamily{
+		{
+			Name: proto.String("rpc_seconds"),
+			Type: dto.MetricType_SUMMARY.Enum(),
+			Metric: []*dto.Metric{
+				{
+					Label: []*dto.LabelPair{{Name: proto.String("job"), Value: proto.String("a")}},
+					Summary: &dto.Summary{
+						SampleCount: proto.Uint64(17),
+						SampleSum:   proto.Float64(324.5),
+						Quantile: []*dto.Quantile{
+							{Quantile: proto.Float64(0.5), Value: proto.Float64(1.2)},
+							{Quantile: proto.Float64(0.99), Value: proto.Float64(3.4)},
+						},
+						CreatedTimestamp: &timestamppb.Timestamp{Seconds: 50, Nanos: 500000000},
+					},
+					TimestampMs: proto.Int64(100000),
+				},
+			},
+		},
+		{
+			Name: proto.String("latency_seconds"),
+			Type: dto.MetricType_HISTOGRAM.Enum(),
+			Metric: []*dto.Metric{
+				{
+					Histogram: &dto.Histogram{
+						SampleCount: proto.Uint64(17),
+						SampleSum:   proto.Float64(324.5),
+						Bucket: []*dto.Bucket{
+							{
+								UpperBound:      proto.Float64(0.1),
+								CumulativeCount: proto.Uint64(8),
+								Exemplar: &dto.Exemplar{
+									Label:     []*dto.LabelPair{{Name: proto.String("trace_id"), Value: proto.String("a")}},
+									Value:     proto.Float64(0.05),
+									Timestamp: &timestamppb.Timestamp{Seconds: 90},
+								},
+							},
+							{UpperBound: proto.Float64(1), CumulativeCount: proto.Uint64(10)},
+							{
+								UpperBound:      proto.Float64(math.Inf(+1)),
+								CumulativeCount: proto.Uint64(17),
+								Exemplar: &dto.Exemplar{
+									Label:     []*dto.LabelPair{{Name: proto.String("trace_id"), Value: proto.String("b")}},
+									Value:     proto.Float64(5),
+									Timestamp: &timestamppb.Timestamp{Seconds: 91},
+								},
+							},
+						},
+					},
+				},
+			},
+		},
+		{
+			Name: proto.String("size_bytes"),
+			Type: dto.MetricType_HISTOGRAM.Enum(),
+			Metric: []*dto.Metric{
+				{
+					Histogram: &dto.Histogram{
+						SampleCount:   proto.Uint64(6),
+						SampleSum:     proto.Float64(12.5),
+						Schema:        proto.Int32(1),
+						ZeroThreshold: proto.Float64(0.001),
+						ZeroCount:     proto.Uint64(1),
+						NegativeSpan:  []*dto.BucketSpan{{Offset: proto.Int32(0), Length: proto.Uint32(1)}},
+						NegativeDelta: []int64{1},
+						PositiveSpan: []*dto.BucketSpan{
+							{Offset: proto.Int32(0), Length: proto.Uint32(2)},
+							{Offset: proto.Int32(3), Length: proto.Uint32(1)},
+						},
+						PositiveDelta:    []int64{1, 1, -1},
+						CreatedTimestamp: &timestamppb.Timestamp{Seconds: 10},
+						Exemplars: []*dto.Exemplar{
+							{
+								Label:     []*dto.LabelPair{{Name: proto.String("trace_id"), Value: proto.String("c")}},
+								Value:     proto.Float64(3),
+								Timestamp: &timestamppb.Timestamp{Seconds: 95},
+							},
+						},
+					},
+				},
+			},
+		},
+		{
+			Name: proto.String("weights"),
+			Type: dto.MetricType_GAUGE_HISTOGRAM.Enum(),
+			Metric: []*dto.Metric{
+				{
+					Histogram: &dto.Histogram{
+						SampleCountFloat: proto.Float64(2.5),
+						SampleSum:        proto.Float64(3),
+						Bucket: []*dto.Bucket{
+							{UpperBound: proto.Float64(1), CumulativeCountFloat: proto.Float64(0.5)},
+							{UpperBound: proto.Float64(math.Inf(+1)), CumulativeCountFloat: proto.Float64(2.5)},
+						},
+					},
+				},
+			},
+		},
+	}, fams)
+}
+
+func TestOpenMetrics20ParseError(t *testing.T) {
+	scenarios := []struct {
+		in  string
+		err string
+	}{
+		{
+			in:  "# TYPE foo counter\nfoo 1 st@1\nfoo 2 st@1\n# EOF\n",
+			err: `text format parsing error in line 3: duplicate sample for metric "foo"`,
+		},
+		{
+			in:  "# TYPE foo_total counter\nfoo_total 1 # {a=\"b\"} 1\n# EOF\n",
+			err: "text format parsing error in line 2: missing exemplar timestamp",
+		},
+		{
+			in:  "# TYPE foo gauge\nfoo 1 st@10\n# EOF\n",
+			err: `text format parsing error in line 2: start timestamps are only allowed on counters, summaries and histograms, not on metric "foo"`,
+		},
+		{
+			in:  "# TYPE foo histogram\nfoo 1\n# EOF\n",
+			err: `text format parsing error in line 2: histogram "foo" must have a composite value`,
+		},
+		{
+			in:  "# TYPE foo gauge\nfoo {count:1}\n# EOF\n",
+			err: `text format parsing error in line 2: gauge "foo" cannot have a composite value`,
+		},
+		{
+			in:  "# TYPE foo histogram\nfoo {count:2,bucket:[1:1]}\n# EOF\n",
+			err: `text format parsing error in line 2: histogram "foo": histogram has no +Inf bucket`,
+		},
+		{
+			in:  "# TYPE foo histogram\nfoo {count:2,schema:0,positive_spans:[0:2],positive_buckets:[1]}\n# EOF\n",
+			err: `text format parsing error in line 2: histogram "foo": positive spans cover 2 buckets, but 1 are given`,
+		},
+		{
+			in:  "# TYPE foo histogram\nfoo {count:2,zero_count:1}\n# EOF\n",
+			err: `text format parsing error in line 2: histogram "foo": native histogram fields without schema`,
+		},
+		{
+			in:  "# TYPE foo summary\nfoo {count:2,bucket:[+Inf:2]}\n# EOF\n",
+			err: `text format parsing error in line 2: summary "foo": unexpected field "bucket" in summary value`,
+		},
+		{
+			in:  "# TYPE foo summary\nfoo {count:2,count:3}\n# EOF\n",
+			err: `text format parsing error in line 2: invalid composite value for metric "foo": duplicate field "count"`,
+		},
+		{
+			in:  "# TYPE foo summary\nfoo {count:2,sum:1\n# EOF\n",
+			err: `text format parsing error in line 2: invalid composite value for metric "foo": expected ',' between fields or '}' at the end of the value`,
+		},
+	}
+
+	for _, s := range scenarios {
+		p := NewOpenMetrics20Parser(model.LegacyValidation)
+		_, err := p.OpenMetricsToMetricFamilies(strings.NewReader(s.in))
+		require.EqualErrorf(t, err, s.err, "input %q", s.in)
+	}
+}
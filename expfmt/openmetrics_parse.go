@@ -0,0 +1,1004 @@
+// Copyright 2026 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package expfmt
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	dto "github.com/prometheus/client_model/go"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/timestamppb"
+
+	"github.com/prometheus/common/model"
+)
+
+// maxExemplarLabelRunes is the maximum combined length, in runes, of the names
+// and values of the labels of an exemplar.
+const maxExemplarLabelRunes = 128
+
+// OpenMetricsParser is used to parse the OpenMetrics 1.0 text format, or the
+// 2.0 one if created with NewOpenMetrics20Parser.
+//
+// OpenMetricsParser instances must be created with NewOpenMetricsParser or
+// NewOpenMetrics20Parser, the zero value of OpenMetricsParser is invalid.
+type OpenMetricsParser struct {
+	scheme model.ValidationScheme
+	v2     bool
+
+	buf       *bufio.Reader
+	lineCount int
+	// pending is a line read while completing the previous family, which
+	// starts the next one.
+	pending    string
+	hasPending bool
+	eof        bool
+	err        error
+	// seen are the names of the families already returned.
+	seen map[string]struct{}
+}
+
+// NewOpenMetricsParser returns a new OpenMetricsParser with the provided
+// nameValidationScheme.
+func NewOpenMetricsParser(nameValidationScheme model.ValidationScheme) OpenMetricsParser {
+	return OpenMetricsParser{scheme: nameValidationScheme}
+}
+
+// OpenMetricsToMetricFamilies reads 'in' as the OpenMetrics text format and
+// creates MetricFamily proto messages. It returns the MetricFamily proto
+// messages in a map where the metric names are the keys, along with any error
+// encountered.
+//
+// The OpenMetrics 1.0 types are mapped to the MetricFamily types the way
+// MetricFamilyToOpenMetrics maps them the other way round: the name of a
+// counter family gets its `_total` suffix, `_created` samples become created
+// timestamps and unknown families are untyped. The types without a protobuf
+// equivalent are converted to gauges: an info family becomes a gauge named
+// with its `_info` suffix, and a stateset becomes a gauge with one metric per
+// state. The `_gcount` and `_gsum` samples of gauge histograms become their
+// sample count and sum.
+//
+// The input must end with a `# EOF` line. As required by OpenMetrics, the
+// samples of a family must not be interleaved with the ones of another family.
+//
+// This method must not be called concurrently. If you want to parse different
+// input concurrently, instantiate a separate Parser for each goroutine.
+func (p *OpenMetricsParser) OpenMetricsToMetricFamilies(in io.Reader) (map[string]*dto.MetricFamily, error) {
+	p.reset(in)
+	fams := map[string]*dto.MetricFamily{}
+	for {
+		mf, err := p.next()
+		if errors.Is(err, io.EOF) {
+			return fams, nil
+		}
+		if err != nil {
+			return fams, err
+		}
+		fams[mf.GetName()] = mf
+	}
+}
+
+func (p *OpenMetricsParser) reset(in io.Reader) {
+	if p.buf == nil {
+		p.buf = bufio.NewReader(in)
+	} else {
+		p.buf.Reset(in)
+	}
+	p.lineCount = 0
+	p.pending, p.hasPending = "", false
+	p.eof = false
+	p.err = nil
+	p.seen = map[string]struct{}{}
+}
+
+// next returns the next metric family of the input, or io.EOF once the
+// `# EOF` line is reached. Families without samples are skipped.
+func (p *OpenMetricsParser) next() (*dto.MetricFamily, error) {
+	for p.err == nil {
+		var mf *dto.MetricFamily
+		mf, p.err = p.readFamily()
+		if p.err == nil && len(mf.GetMetric()) > 0 {
+			return mf, nil
+		}
+	}
+	return nil, p.err
+}
+
+// readFamily reads the lines of the next family.
+func (p *OpenMetricsParser) readFamily() (*dto.MetricFamily, error) {
+	var f *omFamily
+	for {
+		line, err := p.readLine()
+		if err != nil {
+			return nil, err
+		}
+		if line == "# EOF" {
+			if err := p.checkEOF(); err != nil {
+				return nil, err
+			}
+			if f == nil {
+				return nil, io.EOF
+			}
+			return p.finish(f)
+		}
+
+		if strings.HasPrefix(line, "#") {
+			kind, name, text, err := p.parseDescriptor(line)
+			if err != nil {
+				return nil, err
+			}
+			if f == nil {
+				if f, err = p.newFamily(name); err != nil {
+					return nil, err
+				}
+			} else if name != f.name {
+				p.pending, p.hasPending = line, true
+				p.lineCount--
+				return p.finish(f)
+			}
+			if err := f.setDescriptor(kind, text); err != nil {
+				return nil, p.parseError(err.Error())
+			}
+			continue
+		}
+
+		s, err := p.parseSample(line)
+		if err != nil {
+			return nil, err
+		}
+		if f != nil && !f.owns(s.name) {
+			p.pending, p.hasPending = line, true
+			p.lineCount--
+			return p.finish(f)
+		}
+		if f == nil {
+			if f, err = p.newFamily(s.name); err != nil {
+				return nil, err
+			}
+		}
+		if err := f.addSample(s); err != nil {
+			return nil, p.parseError(err.Error())
+		}
+	}
+}
+
+func (p *OpenMetricsParser) finish(f *omFamily) (*dto.MetricFamily, error) {
+	mf, err := f.finish()
+	if err != nil {
+		return nil, p.parseError(err.Error())
+	}
+	return mf, nil
+}
+
+// readLine returns the next line of the input, without its trailing newline.
+func (p *OpenMetricsParser) readLine() (string, error) {
+	p.lineCount++
+	if p.hasPending {
+		p.hasPending = false
+		return p.pending, nil
+	}
+	if p.eof {
+		return "", io.EOF
+	}
+	line, err := p.buf.ReadString('\n')
+	switch {
+	case err == nil:
+		line = line[:len(line)-1]
+	case errors.Is(err, io.EOF):
+		// Only the final `# EOF` line may miss its newline.
+		if line != "# EOF" {
+			return "", p.parseError("unexpected end of input stream, missing # EOF")
+		}
+	default:
+		return "", err
+	}
+	if line == "" {
+		return "", p.parseError("unexpected empty line")
+	}
+	return line, nil
+}
+
+// checkEOF checks that nothing follows the `# EOF` line.
+func (p *OpenMetricsParser) checkEOF() error {
+	p.eof = true
+	if _, err := p.buf.ReadByte(); !errors.Is(err, io.EOF) {
+		if err != nil {
+			return err
+		}
+		return p.parseError("unexpected content after # EOF")
+	}
+	return nil
+}
+
+func (p *OpenMetricsParser) parseError(msg string) error {
+	return ParseError{Line: p.lineCount, Msg: msg}
+}
+
+func (p *OpenMetricsParser) newFamily(name string) (*omFamily, error) {
+	if _, ok := p.seen[name]; ok {
+		return nil, p.parseError(fmt.Sprintf("metric family %q appears more than once, its lines must be contiguous", name))
+	}
+	p.seen[name] = struct{}{}
+	return &omFamily{
+		v2:      p.v2,
+		name:    name,
+		typ:     "unknown",
+		last:    map[uint64]*dto.Metric{},
+		samples: map[string]struct{}{},
+	}, nil
+}
+
+// parseDescriptor parses a `# HELP`, `# TYPE` or `# UNIT` line.
+func (p *OpenMetricsParser) parseDescriptor(line string) (kind, name, text string, err error) {
+	l := omLexer{s: line}
+	if !l.consume('#') || !l.consume(' ') {
+		return "", "", "", p.parseError(fmt.Sprintf("invalid comment line %q", line))
+	}
+	kind = l.readUntil(' ')
+	if kind != "HELP" && kind != "TYPE" && kind != "UNIT" {
+		return "", "", "", p.parseError(fmt.Sprintf("unsupported comment %q", line))
+	}
+	if !l.consume(' ') {
+		return "", "", "", p.parseError(fmt.Sprintf("missing metric name in %s line", kind))
+	}
+	if name, err = l.readName(); err != nil {
+		return "", "", "", p.parseError(err.Error())
+	}
+	if !p.scheme.IsValidMetricName(name) {
+		return "", "", "", p.parseError(fmt.Sprintf("invalid metric name %q", name))
+	}
+	switch {
+	case l.done():
+		if kind != "HELP" {
+			return "", "", "", p.parseError(fmt.Sprintf("missing value in %s line", kind))
+		}
+	case !l.consume(' '):
+		return "", "", "", p.parseError(fmt.Sprintf("invalid %s line %q", kind, line))
+	case kind == "HELP":
+		text = unescapeHelp(l.rest())
+	default:
+		text = l.rest()
+	}
+	return kind, name, text, nil
+}
+
+// omSample is a sample line.
+type omSample struct {
+	name     string
+	labels   []*dto.LabelPair
+	value    float64
+	rawValue string
+	ts       *int64
+	st       *timestamppb.Timestamp
+	// composite is the composite value of an OpenMetrics 2.0 summary or
+	// histogram.
+	composite *omComposite
+	exemplars []*dto.Exemplar
+}
+
+// parseSample parses a sample line, made of a metric name, optional labels, a
+// value, an optional timestamp and an optional exemplar. The OpenMetrics 2.0
+// samples can also have a composite value, a start timestamp and several
+// exemplars.
+func (p *OpenMetricsParser) parseSample(line string) (*omSample, error) {
+	l := omLexer{s: line}
+	s := &omSample{}
+	var err error
+	if l.peek() != '{' {
+		if s.name, err = l.readName(); err != nil {
+			return nil, p.parseError(err.Error())
+		}
+	}
+	if l.peek() == '{' {
+		var name string
+		if name, s.labels, err = l.readLabels(s.name == ""); err != nil {
+			return nil, p.parseError(err.Error())
+		}
+		if s.name == "" {
+			s.name = name
+		}
+	}
+	if !p.scheme.IsValidMetricName(s.name) {
+		return nil, p.parseError(fmt.Sprintf("invalid metric name %q", s.name))
+	}
+	for _, lp := range s.labels {
+		if !p.scheme.IsValidLabelName(lp.GetName()) {
+			return nil, p.parseError(fmt.Sprintf("invalid label name %q", lp.GetName()))
+		}
+	}
+
+	if !l.consume(' ') {
+		return nil, p.parseError(fmt.Sprintf("missing value for metric %q", s.name))
+	}
+	if p.v2 && l.peek() == '{' {
+		if s.composite, err = l.readComposite(); err != nil {
+			return nil, p.parseError(fmt.Sprintf("invalid composite value for metric %q: %s", s.name, err))
+		}
+	} else {
+		s.rawValue = l.readUntil(' ')
+		if s.value, err = parseOpenMetricsFloat(s.rawValue); err != nil {
+			return nil, p.parseError(fmt.Sprintf("invalid value for metric %q: %s", s.name, err))
+		}
+	}
+	for l.consume(' ') {
+		switch {
+		case l.peek() == '#':
+			e, err := p.parseExemplar(&l)
+			if err != nil {
+				return nil, err
+			}
+			s.exemplars = append(s.exemplars, e)
+		case s.ts == nil && s.st == nil && len(s.exemplars) == 0 && !strings.HasPrefix(l.s[l.pos:], "st@"):
+			ts, err := parseOpenMetricsTimestamp(l.readUntil(' '))
+			if err != nil {
+				return nil, p.parseError(fmt.Sprintf("invalid timestamp for metric %q: %s", s.name, err))
+			}
+			ms := int64(math.Round(ts * 1000))
+			s.ts = &ms
+		case p.v2 && s.st == nil && len(s.exemplars) == 0 && strings.HasPrefix(l.s[l.pos:], "st@"):
+			l.pos += len("st@")
+			if s.st, err = parseProtoTimestamp(l.readUntil(' ')); err != nil {
+				return nil, p.parseError(fmt.Sprintf("invalid start timestamp for metric %q: %s", s.name, err))
+			}
+		default:
+			return nil, p.parseError(fmt.Sprintf("unexpected content %q after the value of metric %q", l.rest(), s.name))
+		}
+	}
+	if !l.done() {
+		return nil, p.parseError(fmt.Sprintf("unexpected content %q after the value of metric %q", l.rest(), s.name))
+	}
+	if !p.v2 && len(s.exemplars) > 1 {
+		return nil, p.parseError(fmt.Sprintf("metric %q has more than one exemplar", s.name))
+	}
+	return s, nil
+}
+
+// parseExemplar parses the exemplar following ` # ` at the end of a sample
+// line.
+func (p *OpenMetricsParser) parseExemplar(l *omLexer) (*dto.Exemplar, error) {
+	if !l.consume('#') || !l.consume(' ') || l.peek() != '{' {
+		return nil, p.parseError("invalid exemplar, expected labels after #")
+	}
+	_, labels, err := l.readLabels(false)
+	if err != nil {
+		return nil, p.parseError(fmt.Sprintf("invalid exemplar: %s", err))
+	}
+	runes := 0
+	for _, lp := range labels {
+		if !p.scheme.IsValidLabelName(lp.GetName()) {
+			return nil, p.parseError(fmt.Sprintf("invalid exemplar label name %q", lp.GetName()))
+		}
+		runes += utf8.RuneCountInString(lp.GetName()) + utf8.RuneCountInString(lp.GetValue())
+	}
+	if runes > maxExemplarLabelRunes {
+		return nil, p.parseError(fmt.Sprintf("exemplar labels have %d runes, exceeding the limit of %d", runes, maxExemplarLabelRunes))
+	}
+	e := &dto.Exemplar{Label: labels}
+	if !l.consume(' ') {
+		return nil, p.parseError("missing exemplar value")
+	}
+	v, err := parseOpenMetricsFloat(l.readUntil(' '))
+	if err != nil {
+		return nil, p.parseError(fmt.Sprintf("invalid exemplar value: %s", err))
+	}
+	e.Value = proto.Float64(v)
+	// The space may also start the next exemplar.
+	if rest := l.s[l.pos:]; strings.HasPrefix(rest, " ") && !strings.HasPrefix(rest, " #") {
+		l.consume(' ')
+		if e.Timestamp, err = parseProtoTimestamp(l.readUntil(' ')); err != nil {
+			return nil, p.parseError(fmt.Sprintf("invalid exemplar timestamp: %s", err))
+		}
+	}
+	if p.v2 && e.Timestamp == nil {
+		return nil, p.parseError("missing exemplar timestamp")
+	}
+	return e, nil
+}
+
+// omFamily accumulates the lines of a metric family.
+type omFamily struct {
+	v2   bool
+	name string
+	typ  string
+	mf   dto.MetricFamily
+	// last are the latest metrics by label signature, which the following
+	// samples of the same series are added to.
+	last map[uint64]*dto.Metric
+	// samples are the samples already seen, to detect duplicates.
+	samples map[string]struct{}
+}
+
+// omTypes are the OpenMetrics types with the sample name suffixes they
+// allow.
+var omTypes = map[string][]string{
+	"counter":        {"_total", "_created"},
+	"gauge":          {""},
+	"unknown":        {""},
+	"stateset":       {""},
+	"info":           {"_info"},
+	"summary":        {"", "_sum", "_count", "_created"},
+	"histogram":      {"_bucket", "_sum", "_count", "_created"},
+	"gaugehistogram": {"_bucket", "_gsum", "_gcount", "_sum", "_count"},
+}
+
+// owns reports whether the sample name belongs to the family. The
+// OpenMetrics 2.0 sample names have no suffix.
+func (f *omFamily) owns(name string) bool {
+	if f.v2 {
+		return name == f.name
+	}
+	for _, suffix := range omTypes[f.typ] {
+		if name == f.name+suffix {
+			return true
+		}
+	}
+	return false
+}
+
+// suffix returns the suffix of a sample name owned by the family.
+func (f *omFamily) suffix(name string) string {
+	return name[len(f.name):]
+}
+
+func (f *omFamily) setDescriptor(kind, text string) error {
+	if len(f.mf.Metric) > 0 {
+		return fmt.Errorf("%s line for metric %q after its samples", kind, f.name)
+	}
+	switch kind {
+	case "HELP":
+		if f.mf.Help != nil {
+			return fmt.Errorf("second HELP line for metric %q", f.name)
+		}
+		f.mf.Help = proto.String(text)
+	case "UNIT":
+		if f.mf.Unit != nil {
+			return fmt.Errorf("second UNIT line for metric %q", f.name)
+		}
+		if text != "" && !strings.HasSuffix(f.name, text) {
+			return fmt.Errorf("unit %q is not a suffix of metric %q", text, f.name)
+		}
+		f.mf.Unit = proto.String(text)
+	case "TYPE":
+		if f.mf.Type != nil {
+			return fmt.Errorf("second TYPE line for metric %q", f.name)
+		}
+		if _, ok := omTypes[text]; !ok {
+			return fmt.Errorf("unknown metric type %q", text)
+		}
+		f.typ = text
+		f.mf.Type = omMetricType(text).Enum()
+	}
+	return nil
+}
+
+func omMetricType(typ string) dto.MetricType {
+	switch typ {
+	case "counter":
+		return dto.MetricType_COUNTER
+	case "gauge", "stateset", "info":
+		return dto.MetricType_GAUGE
+	case "summary":
+		return dto.MetricType_SUMMARY
+	case "histogram":
+		return dto.MetricType_HISTOGRAM
+	case "gaugehistogram":
+		return dto.MetricType_GAUGE_HISTOGRAM
+	default:
+		return dto.MetricType_UNTYPED
+	}
+}
+
+func (f *omFamily) addSample(s *omSample) error {
+	if !f.owns(s.name) {
+		return fmt.Errorf("unexpected sample %q in %s family %q", s.name, f.typ, f.name)
+	}
+	if f.v2 {
+		return f.addSample20(s)
+	}
+	suffix := f.suffix(s.name)
+	if s.composite != nil || s.st != nil {
+		return fmt.Errorf("unexpected OpenMetrics 2.0 value for metric %q", s.name)
+	}
+	var exemplar *dto.Exemplar
+	if len(s.exemplars) > 0 {
+		exemplar = s.exemplars[0]
+	}
+
+	// Pick the quantile and le labels out of summaries and histograms.
+	var (
+		extraName string
+		extra     float64
+		labels    = s.labels
+	)
+	switch {
+	case f.typ == "summary" && suffix == "":
+		extraName = model.QuantileLabel
+	case (f.typ == "histogram" || f.typ == "gaugehistogram") && suffix == "_bucket":
+		extraName = model.BucketLabel
+	}
+	if extraName != "" {
+		labels = nil
+		found := false
+		for _, lp := range s.labels {
+			if lp.GetName() != extraName {
+				labels = append(labels, lp)
+				continue
+			}
+			v, err := parseOpenMetricsFloat(lp.GetValue())
+			if err != nil {
+				return fmt.Errorf("invalid %s label value %q for metric %q", extraName, lp.GetValue(), s.name)
+			}
+			extra, found = v, true
+		}
+		if !found {
+			return fmt.Errorf("missing %s label for metric %q", extraName, s.name)
+		}
+	}
+
+	if err := f.checkDuplicate(s); err != nil {
+		return err
+	}
+
+	if exemplar != nil && suffix != "_total" && suffix != "_bucket" {
+		return fmt.Errorf("exemplars are only allowed on counter totals and histogram buckets, not on metric %q", s.name)
+	}
+
+	var err error
+	m := f.metric(labels, s.ts, suffix == "_created")
+	switch f.typ {
+	case "counter":
+		if m.Counter == nil {
+			m.Counter = &dto.Counter{}
+		}
+		if suffix == "_created" {
+			if m.Counter.CreatedTimestamp, err = parseProtoTimestamp(s.rawValue); err != nil {
+				return fmt.Errorf("invalid created timestamp for metric %q: %w", s.name, err)
+			}
+			break
+		}
+		if s.value < 0 || math.IsNaN(s.value) {
+			return fmt.Errorf("counter %q has invalid value %v", s.name, s.value)
+		}
+		m.Counter.Value = proto.Float64(s.value)
+		m.Counter.Exemplar = exemplar
+	case "gauge", "info", "stateset":
+		if err := f.checkGauge(s); err != nil {
+			return err
+		}
+		m.Gauge = &dto.Gauge{Value: proto.Float64(s.value)}
+	case "unknown":
+		m.Untyped = &dto.Untyped{Value: proto.Float64(s.value)}
+	case "summary":
+		if m.Summary == nil {
+			m.Summary = &dto.Summary{}
+		}
+		switch suffix {
+		case "":
+			m.Summary.Quantile = append(m.Summary.Quantile, &dto.Quantile{
+				Quantile: proto.Float64(extra),
+				Value:    proto.Float64(s.value),
+			})
+		case "_sum":
+			m.Summary.SampleSum = proto.Float64(s.value)
+		case "_count":
+			count, err := omCount(s)
+			if err != nil {
+				return err
+			}
+			m.Summary.SampleCount = proto.Uint64(count)
+		case "_created":
+			if m.Summary.CreatedTimestamp, err = parseProtoTimestamp(s.rawValue); err != nil {
+				return fmt.Errorf("invalid created timestamp for metric %q: %w", s.name, err)
+			}
+		}
+	case "histogram", "gaugehistogram":
+		if m.Histogram == nil {
+			m.Histogram = &dto.Histogram{}
+		}
+		switch suffix {
+		case "_bucket":
+			count, err := omCount(s)
+			if err != nil {
+				return err
+			}
+			m.Histogram.Bucket = append(m.Histogram.Bucket, &dto.Bucket{
+				UpperBound:      proto.Float64(extra),
+				CumulativeCount: proto.Uint64(count),
+				Exemplar:        exemplar,
+			})
+		case "_sum", "_gsum":
+			m.Histogram.SampleSum = proto.Float64(s.value)
+		case "_count", "_gcount":
+			count, err := omCount(s)
+			if err != nil {
+				return err
+			}
+			m.Histogram.SampleCount = proto.Uint64(count)
+		case "_created":
+			if m.Histogram.CreatedTimestamp, err = parseProtoTimestamp(s.rawValue); err != nil {
+				return fmt.Errorf("invalid created timestamp for metric %q: %w", s.name, err)
+			}
+		}
+	}
+	return nil
+}
+
+// checkDuplicate returns an error if the family already has a sample with
+// the same name, labels and timestamp.
+func (f *omFamily) checkDuplicate(s *omSample) error {
+	key := s.name + "\xff" + strconv.FormatUint(labelPairsSignature(s.labels), 16)
+	if s.ts != nil {
+		key += "\xff" + strconv.FormatInt(*s.ts, 10)
+	}
+	if _, ok := f.samples[key]; ok {
+		return fmt.Errorf("duplicate sample for metric %q", s.name)
+	}
+	f.samples[key] = struct{}{}
+	return nil
+}
+
+// checkGauge checks the values of the info and stateset samples, which are
+// converted to gauges.
+func (f *omFamily) checkGauge(s *omSample) error {
+	switch {
+	case f.typ == "info" && s.value != 1:
+		return fmt.Errorf("info metric %q must have the value 1, not %v", s.name, s.value)
+	case f.typ == "stateset" && s.value != 0 && s.value != 1:
+		return fmt.Errorf("stateset metric %q must have the value 0 or 1, not %v", s.name, s.value)
+	case f.typ == "stateset" && !hasLabel(s.labels, f.name):
+		return fmt.Errorf("stateset metric %q misses its %q label", s.name, f.name)
+	}
+	return nil
+}
+
+// metric returns the metric a sample with the given labels and timestamp is
+// added to. A sample with another timestamp than the latest metric of its
+// series starts a new one, except for the `_created` samples, which are
+// usually written without the timestamp of the other samples.
+func (f *omFamily) metric(labels []*dto.LabelPair, ts *int64, created bool) *dto.Metric {
+	sig := labelPairsSignature(labels)
+	if m, ok := f.last[sig]; ok {
+		if (created && ts == nil) || (ts == nil && m.TimestampMs == nil) || (ts != nil && m.TimestampMs != nil && *ts == *m.TimestampMs) {
+			return m
+		}
+	}
+	m := &dto.Metric{Label: labels, TimestampMs: ts}
+	f.mf.Metric = append(f.mf.Metric, m)
+	f.last[sig] = m
+	return m
+}
+
+// finish validates the family and returns it.
+func (f *omFamily) finish() (*dto.MetricFamily, error) {
+	f.mf.Name = proto.String(f.name)
+	switch {
+	case f.v2:
+	case f.typ == "counter":
+		f.mf.Name = proto.String(f.name + "_total")
+	case f.typ == "info":
+		f.mf.Name = proto.String(f.name + "_info")
+	}
+	if f.mf.Type == nil {
+		f.mf.Type = dto.MetricType_UNTYPED.Enum()
+	}
+	for _, m := range f.mf.Metric {
+		switch {
+		case m.Counter != nil && m.Counter.Value == nil:
+			return nil, fmt.Errorf("counter %q has a _created sample without _total sample", f.name)
+		case m.Histogram != nil && !f.v2:
+			if err := validateOpenMetricsHistogram(f.name, m.Histogram); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return &f.mf, nil
+}
+
+func validateOpenMetricsHistogram(name string, h *dto.Histogram) error {
+	buckets := h.GetBucket()
+	if len(buckets) == 0 || !math.IsInf(buckets[len(buckets)-1].GetUpperBound(), +1) {
+		return fmt.Errorf("histogram %q has no +Inf bucket", name)
+	}
+	for i := 1; i < len(buckets); i++ {
+		if buckets[i].GetUpperBound() <= buckets[i-1].GetUpperBound() {
+			return fmt.Errorf("histogram %q has buckets out of order", name)
+		}
+		if buckets[i].GetCumulativeCount() < buckets[i-1].GetCumulativeCount() {
+			return fmt.Errorf("histogram %q has decreasing bucket counts", name)
+		}
+	}
+	infCount := buckets[len(buckets)-1].GetCumulativeCount()
+	if h.SampleCount == nil {
+		h.SampleCount = proto.Uint64(infCount)
+	} else if h.GetSampleCount() != infCount {
+		return fmt.Errorf("histogram %q has a count of %d different from its +Inf bucket count of %d", name, h.GetSampleCount(), infCount)
+	}
+	return nil
+}
+
+// omCount returns the value of a count sample, which must be a non-negative
+// integer.
+func omCount(s *omSample) (uint64, error) {
+	if !isIntegerCount(s.value) {
+		return 0, fmt.Errorf("count of metric %q must be a non-negative integer, not %v", s.name, s.value)
+	}
+	return uint64(s.value), nil
+}
+
+func hasLabel(labels []*dto.LabelPair, name string) bool {
+	for _, lp := range labels {
+		if lp.GetName() == name {
+			return true
+		}
+	}
+	return false
+}
+
+func labelPairsSignature(labels []*dto.LabelPair) uint64 {
+	m := make(map[string]string, len(labels))
+	for _, lp := range labels {
+		m[lp.GetName()] = lp.GetValue()
+	}
+	return model.LabelsToSignature(m)
+}
+
+// parseOpenMetricsFloat parses a number, which OpenMetrics restricts to
+// decimal notation and the NaN, +Inf and -Inf special values.
+func parseOpenMetricsFloat(s string) (float64, error) {
+	if strings.ContainsAny(s, "xX") {
+		return 0, errors.New("unsupported character in float")
+	}
+	switch strings.ToLower(strings.TrimLeft(s, "+-")) {
+	case "inf", "infinity", "nan":
+		if s != "+Inf" && s != "-Inf" && s != "NaN" {
+			return 0, fmt.Errorf("invalid special value %q", s)
+		}
+	}
+	return parseFloat(s)
+}
+
+// parseOpenMetricsTimestamp parses a timestamp in seconds.
+func parseOpenMetricsTimestamp(s string) (float64, error) {
+	ts, err := parseOpenMetricsFloat(s)
+	if err != nil {
+		return 0, err
+	}
+	if math.IsNaN(ts) || math.IsInf(ts, 0) {
+		return 0, fmt.Errorf("invalid timestamp %q", s)
+	}
+	return ts, nil
+}
+
+// parseProtoTimestamp parses a timestamp in seconds. The decimal timestamps
+// are parsed exactly, without float rounding.
+func parseProtoTimestamp(s string) (*timestamppb.Timestamp, error) {
+	f, err := parseOpenMetricsTimestamp(s)
+	if err != nil {
+		return nil, err
+	}
+	sec, frac, _ := strings.Cut(strings.TrimPrefix(s, "+"), ".")
+	if len(frac) > 9 || strings.ContainsAny(s, "eE") {
+		return floatToTimestamp(f), nil
+	}
+	seconds, err := strconv.ParseInt(sec, 10, 64)
+	if err != nil {
+		return floatToTimestamp(f), nil
+	}
+	var nanos int64
+	if frac != "" {
+		if nanos, err = strconv.ParseInt(frac+strings.Repeat("0", 9-len(frac)), 10, 32); err != nil {
+			return nil, err
+		}
+	}
+	if strings.HasPrefix(sec, "-") && nanos > 0 {
+		seconds--
+		nanos = 1e9 - nanos
+	}
+	return &timestamppb.Timestamp{Seconds: seconds, Nanos: int32(nanos)}, nil
+}
+
+// floatToTimestamp converts a timestamp in seconds.
+func floatToTimestamp(f float64) *timestamppb.Timestamp {
+	sec := math.Floor(f)
+	nanos := math.Round((f - sec) * 1e9)
+	if nanos >= 1e9 {
+		sec++
+		nanos -= 1e9
+	}
+	return &timestamppb.Timestamp{Seconds: int64(sec), Nanos: int32(nanos)}
+}
+
+// unescapeHelp unescapes the text of a HELP line, leaving the invalid escape
+// sequences as they are.
+func unescapeHelp(s string) string {
+	if !strings.Contains(s, `\`) {
+		return s
+	}
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		if s[i] == '\\' && i+1 < len(s) {
+			switch s[i+1] {
+			case '\\', '"':
+				b.WriteByte(s[i+1])
+				i++
+				continue
+			case 'n':
+				b.WriteByte('\n')
+				i++
+				continue
+			}
+		}
+		b.WriteByte(s[i])
+	}
+	return b.String()
+}
+
+// omLexer reads the tokens of a line of the OpenMetrics text format.
+type omLexer struct {
+	s   string
+	pos int
+}
+
+func (l *omLexer) done() bool {
+	return l.pos >= len(l.s)
+}
+
+func (l *omLexer) peek() byte {
+	if l.done() {
+		return 0
+	}
+	return l.s[l.pos]
+}
+
+func (l *omLexer) consume(b byte) bool {
+	if l.done() || l.s[l.pos] != b {
+		return false
+	}
+	l.pos++
+	return true
+}
+
+func (l *omLexer) rest() string {
+	r := l.s[l.pos:]
+	l.pos = len(l.s)
+	return r
+}
+
+// readUntil reads up to the next occurrence of b or the end of the line.
+func (l *omLexer) readUntil(b byte) string {
+	start := l.pos
+	for !l.done() && l.s[l.pos] != b {
+		l.pos++
+	}
+	return l.s[start:l.pos]
+}
+
+// readName reads a metric name, either bare or quoted.
+func (l *omLexer) readName() (string, error) {
+	if l.peek() == '"' {
+		return l.readQuoted()
+	}
+	name := l.readBareName(true)
+	if name == "" {
+		return "", fmt.Errorf("invalid metric name start %q", l.peek())
+	}
+	return name, nil
+}
+
+// readBareName reads an unquoted metric name, allowing colons, or label name.
+func (l *omLexer) readBareName(metric bool) string {
+	start := l.pos
+	for ; !l.done(); l.pos++ {
+		b := l.s[l.pos]
+		if (b < 'a' || b > 'z') && (b < 'A' || b > 'Z') && b != '_' &&
+			(b < '0' || b > '9' || l.pos == start) && (b != ':' || !metric) {
+			break
+		}
+	}
+	return l.s[start:l.pos]
+}
+
+// readLabels reads a label set. If allowName is set, the first term may be a
+// quoted metric name, which is returned.
+func (l *omLexer) readLabels(allowName bool) (string, []*dto.LabelPair, error) {
+	var (
+		name   string
+		labels []*dto.LabelPair
+		names  = map[string]struct{}{}
+	)
+	l.consume('{')
+	if l.consume('}') {
+		return "", nil, nil
+	}
+	for first := true; ; first = false {
+		var (
+			label string
+			err   error
+		)
+		switch c := l.peek(); {
+		case c == '"':
+			if label, err = l.readQuoted(); err != nil {
+				return "", nil, err
+			}
+			if l.peek() != '=' {
+				if !first || !allowName {
+					return "", nil, fmt.Errorf("unexpected quoted name %q in label set", label)
+				}
+				name = label
+				label = ""
+			}
+		default:
+			if label = l.readBareName(false); label == "" {
+				return "", nil, fmt.Errorf("invalid label name start %q", c)
+			}
+		}
+		if label != "" {
+			if !l.consume('=') || l.peek() != '"' {
+				return "", nil, fmt.Errorf("expected quoted value for label %q", label)
+			}
+			value, err := l.readQuoted()
+			if err != nil {
+				return "", nil, err
+			}
+			if _, ok := names[label]; ok {
+				return "", nil, fmt.Errorf("duplicate label name %q", label)
+			}
+			names[label] = struct{}{}
+			labels = append(labels, &dto.LabelPair{Name: proto.String(label), Value: proto.String(value)})
+		}
+		if l.consume('}') {
+			return name, labels, nil
+		}
+		if !l.consume(',') || l.peek() == '}' {
+			return "", nil, errors.New("expected ',' between labels or '}' at the end of the label set")
+		}
+	}
+}
+
+// readQuoted reads a double-quoted string, unescaping it.
+func (l *omLexer) readQuoted() (string, error) {
+	l.consume('"')
+	var b strings.Builder
+	for !l.done() {
+		c := l.s[l.pos]
+		l.pos++
+		switch c {
+		case '"':
+			s := b.String()
+			if !utf8.ValidString(s) {
+				return "", fmt.Errorf("invalid UTF-8 in %q", s)
+			}
+			return s, nil
+		case '\\':
+			switch l.peek() {
+			case '\\', '"':
+				b.WriteByte(l.s[l.pos])
+			case 'n':
+				b.WriteByte('\n')
+			default:
+				return "", fmt.Errorf("invalid escape sequence '\\%c'", l.peek())
+			}
+			l.pos++
+		default:
+			b.WriteByte(c)
+		}
+	}
+	return "", errors.New("unterminated quoted string")
+}
@@ -0,0 +1,376 @@
+// Copyright 2026 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package expfmt
+
+import (
+	"bytes"
+	"math"
+	"strings"
+	"testing"
+
+	dto "github.com/prometheus/client_model/go"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/timestamppb"
+
+	"github.com/prometheus/common/model"
+)
+
+func requireMetricFamilies(t *testing.T, expected []*dto.MetricFamily, got map[string]*dto.MetricFamily) {
+	t.Helper()
+	require.Len(t, got, len(expected))
+	for _, mf := range expected {
+		require.Containsf(t, got, mf.GetName(), "missing metric family %q", mf.GetName())
+		require.Truef(t, proto.Equal(mf, got[mf.GetName()]), "expected %s, got %s", mf, got[mf.GetName()])
+	}
+}
+
+func TestOpenMetricsParse(t *testing.T) {
+	in := `# HELP requests Requests served.
+# TYPE requests counter
+requests_total{code="200"} 10.0 1520879607.789 # {trace_id="abc"} 1.0 1520879607.5
+requests_created{code="200"} 1520430000.123
+requests_total{code="500"} 2.0 1520879607.789
+# TYPE temperature_celsius gauge
+# UNIT temperature_celsius celsius
+# HELP temperature_celsius Temperature with "quotes" and \\ and\nnewline.
+temperature_celsius{"room.name"="a\"b\\c\nd"} -3.5
+# TYPE rpc_duration_seconds summary
+rpc_duration_seconds{quantile="0.5"} 0.05
+rpc_duration_seconds{quantile="0.99"} NaN
+rpc_duration_seconds_sum 17.0
+rpc_duration_seconds_count 42
+# TYPE latency histogram
+latency_bucket{le="0.1"} 1 # {} 0.05
+latency_bucket{le="1"} 3
+latency_bucket{le="+Inf"} 4
+latency_sum 2.5
+latency_count 4
+latency_created 1520430000.0
+# TYPE queue gaugehistogram
+queue_bucket{le="+Inf"} 5
+queue_gsum 10
+queue_gcount 5
+# TYPE build info
+build_info{version="1.0"} 1
+# TYPE state stateset
+state{state="up"} 1
+state{state="down"} 0
+{"my.metric",job="a"} 1
+# EOF
+`
+	p := NewOpenMetricsParser(model.UTF8Validation)
+	fams, err := p.OpenMetricsToMetricFamilies(strings.NewReader(in))
+	require.NoError(t, err)
+	requireMetricFamilies(t, []*dto.MetricFamily{
+		{
+			Name: proto.String("requests_total"),
+			Help: proto.String("Requests served."),
+			Type: dto.MetricType_COUNTER.Enum(),
+			Metric: []*dto.Metric{
+				{
+					Label: []*dto.LabelPair{{Name: proto.String("code"), Value: proto.String("200")}},
+					Counter: &dto.Counter{
+						Value: proto.Float64(10),
+						Exemplar: &dto.Exemplar{
+							Label:     []*dto.LabelPair{{Name: proto.String("trace_id"), Value: proto.String("abc")}},
+							Value:     proto.Float64(1),
+							Timestamp: &timestamppb.Timestamp{Seconds: 1520879607, Nanos: 500000000},
+						},
+						CreatedTimestamp: &timestamppb.Timestamp{Seconds: 1520430000, Nanos: 123000000},
+					},
+					TimestampMs: proto.Int64(1520879607789),
+				},
+				{
+					Label:       []*dto.LabelPair{{Name: proto.String("code"), Value: proto.String("500")}},
+					Counter:     &dto.Counter{Value: proto.Float64(2)},
+					TimestampMs: proto.Int64(1520879607789),
+				},
+			},
+		},
+		{
+			Name: proto.String("temperature_celsius"),
+			Help: proto.String("Temperature with \"quotes\" and \\ and\nnewline."),
+			Type: dto.MetricType_GAUGE.Enum(),
+			Unit: proto.String("celsius"),
+			Metric: []*dto.Metric{
+				{
+					Label: []*dto.LabelPair{{Name: proto.String("room.name"), Value: proto.String("a\"b\\c\nd")}},
+					Gauge: &dto.Gauge{Value: proto.Float64(-3.5)},
+				},
+			},
+		},
+		{
+			Name: proto.String("rpc_duration_seconds"),
+			Type: dto.MetricType_SUMMARY.Enum(),
+			Metric: []*dto.Metric{
+				{
+					Summary: &dto.Summary{
+						Quantile: []*dto.Quantile{
+							{Quantile: proto.Float64(0.5), Value: proto.Float64(0.05)},
+							{Quantile: proto.Float64(0.99), Value: proto.Float64(math.NaN())},
+						},
+						SampleSum:   proto.Float64(17),
+						SampleCount: proto.Uint64(42),
+					},
+				},
+			},
+		},
+		{
+			Name: proto.String("latency"),
+			Type: dto.MetricType_HISTOGRAM.Enum(),
+			Metric: []*dto.Metric{
+				{
+					Histogram: &dto.Histogram{
+						Bucket: []*dto.Bucket{
+							{UpperBound: proto.Float64(0.1), CumulativeCount: proto.Uint64(1), Exemplar: &dto.Exemplar{Value: proto.Float64(0.05)}},
+							{UpperBound: proto.Float64(1), CumulativeCount: proto.Uint64(3)},
+							{UpperBound: proto.Float64(math.Inf(+1)), CumulativeCount: proto.Uint64(4)},
+						},
+						SampleSum:        proto.Float64(2.5),
+						SampleCount:      proto.Uint64(4),
+						CreatedTimestamp: &timestamppb.Timestamp{Seconds: 1520430000},
+					},
+				},
+			},
+		},
+		{
+			Name: proto.String("queue"),
+			Type: dto.MetricType_GAUGE_HISTOGRAM.Enum(),
+			Metric: []*dto.Metric{
+				{
+					Histogram: &dto.Histogram{
+						Bucket:      []*dto.Bucket{{UpperBound: proto.Float64(math.Inf(+1)), CumulativeCount: proto.Uint64(5)}},
+						SampleSum:   proto.Float64(10),
+						SampleCount: proto.Uint64(5),
+					},
+				},
+			},
+		},
+		{
+			Name: proto.String("build_info"),
+			Type: dto.MetricType_GAUGE.Enum(),
+			Metric: []*dto.Metric{
+				{
+					Label: []*dto.LabelPair{{Name: proto.String("version"), Value: proto.String("1.0")}},
+					Gauge: &dto.Gauge{Value: proto.Float64(1)},
+				},
+			},
+		},
+		{
+			Name: proto.String("state"),
+			Type: dto.MetricType_GAUGE.Enum(),
+			Metric: []*dto.Metric{
+				{
+					Label: []*dto.LabelPair{{Name: proto.String("state"), Value: proto.String("up")}},
+					Gauge: &dto.Gauge{Value: proto.Float64(1)},
+				},
+				{
+					Label: []*dto.LabelPair{{Name: proto.String("state"), Value: proto.String("down")}},
+					Gauge: &dto.Gauge{Value: proto.Float64(0)},
+				},
+			},
+		},
+		{
+			Name: proto.String("my.metric"),
+			Type: dto.MetricType_UNTYPED.Enum(),
+			Metric: []*dto.Metric{
+				{
+					Label:   []*dto.LabelPair{{Name: proto.String("job"), Value: proto.String("a")}},
+					Untyped: &dto.Untyped{Value: proto.Float64(1)},
+				},
+			},
+		},
+	}, fams)
+}
+
+func TestOpenMetricsParseTimestamps(t *testing.T) {
+	// A series can have several points, each with its own timestamp.
+	in := `# TYPE foo gauge
+foo 1 10
+foo 2 20.5
+# EOF`
+	p := NewOpenMetricsParser(model.LegacyValidation)
+	fams, err := p.OpenMetricsToMetricFamilies(strings.NewReader(in))
+	require.NoError(t, err)
+	requireMetricFamilies(t, []*dto.MetricFamily{
+		{
+			Name: proto.String("foo"),
+			Type: dto.MetricType_GAUGE.Enum(),
+			Metric: []*dto.Metric{
+				{Gauge: &dto.Gauge{Value: proto.Float64(1)}, TimestampMs: proto.Int64(10000)},
+				{Gauge: &dto.Gauge{Value: proto.Float64(2)}, TimestampMs: proto.Int64(20500)},
+			},
+		},
+	}, fams)
+}
+
+func TestOpenMetricsParseError(t *testing.T) {
+	scenarios := []struct {
+		in  string
+		err string
+	}{
+		{
+			in:  "foo 1\n",
+			err: "text format parsing error in line 2: unexpected end of input stream, missing # EOF",
+		},
+		{
+			in:  "foo 1\n# EOF\nbar 1\n",
+			err: "text format parsing error in line 2: unexpected content after # EOF",
+		},
+		{
+			in:  "foo 1\n\n# EOF\n",
+			err: "text format parsing error in line 2: unexpected empty line",
+		},
+		{
+			in:  "# a comment\n# EOF\n",
+			err: `text format parsing error in line 1: unsupported comment "# a comment"`,
+		},
+		{
+			in:  "foo 1\nbar 1\nfoo 2\n# EOF\n",
+			err: `text format parsing error in line 3: metric family "foo" appears more than once, its lines must be contiguous`,
+		},
+		{
+			in:  "# TYPE foo gauge\nfoo 1\nfoo 2\n# EOF\n",
+			err: `text format parsing error in line 3: duplicate sample for metric "foo"`,
+		},
+		{
+			in:  "# TYPE foo gauge\nfoo 1\n# HELP foo help\n# EOF\n",
+			err: `text format parsing error in line 3: HELP line for metric "foo" after its samples`,
+		},
+		{
+			in:  "# TYPE foo counter\nfoo_total -1\n# EOF\n",
+			err: `text format parsing error in line 2: counter "foo_total" has invalid value -1`,
+		},
+		{
+			in:  "# TYPE foo gauge\nfoo 1 # {a=\"b\"} 1\n# EOF\n",
+			err: `text format parsing error in line 2: exemplars are only allowed on counter totals and histogram buckets, not on metric "foo"`,
+		},
+		{
+			in:  "# TYPE foo counter\nfoo_total 1 # {a=\"" + strings.Repeat("x", 128) + "\"} 1\n# EOF\n",
+			err: "text format parsing error in line 2: exemplar labels have 129 runes, exceeding the limit of 128",
+		},
+		{
+			in:  "# TYPE foo histogram\nfoo_bucket{le=\"1\"} 1\nfoo_count 1\n# EOF\n",
+			err: `text format parsing error in line 4: histogram "foo" has no +Inf bucket`,
+		},
+		{
+			in:  "# TYPE foo histogram\nfoo_bucket{le=\"+Inf\"} 1.5\n# EOF\n",
+			err: `text format parsing error in line 2: count of metric "foo_bucket" must be a non-negative integer, not 1.5`,
+		},
+		{
+			in:  "# TYPE foo_seconds gauge\n# UNIT foo_seconds bytes\n# EOF\n",
+			err: `text format parsing error in line 2: unit "bytes" is not a suffix of metric "foo_seconds"`,
+		},
+		{
+			in:  "# TYPE foo stateset\nfoo{a=\"b\"} 1\n# EOF\n",
+			err: `text format parsing error in line 2: stateset metric "foo" misses its "foo" label`,
+		},
+		{
+			in:  "foo{a=\"b\",} 1\n# EOF\n",
+			err: "text format parsing error in line 1: expected ',' between labels or '}' at the end of the label set",
+		},
+		{
+			in:  "foo{a=\"b\",a=\"c\"} 1\n# EOF\n",
+			err: `text format parsing error in line 1: duplicate label name "a"`,
+		},
+		{
+			in:  "foo 0x10\n# EOF\n",
+			err: `text format parsing error in line 1: invalid value for metric "foo": unsupported character in float`,
+		},
+		{
+			in:  "foo inf\n# EOF\n",
+			err: `text format parsing error in line 1: invalid value for metric "foo": invalid special value "inf"`,
+		},
+		{
+			in:  "{\"foo.bar\"} 1\n# EOF\n",
+			err: `text format parsing error in line 1: invalid metric name "foo.bar"`,
+		},
+	}
+
+	for _, s := range scenarios {
+		p := NewOpenMetricsParser(model.LegacyValidation)
+		_, err := p.OpenMetricsToMetricFamilies(strings.NewReader(s.in))
+		require.EqualErrorf(t, err, s.err, "input %q", s.in)
+	}
+}
+
+func TestOpenMetricsRoundTrip(t *testing.T) {
+	fams := []*dto.MetricFamily{
+		{
+			Name: proto.String("requests_total"),
+			Help: proto.String("Requests\nserved."),
+			Type: dto.MetricType_COUNTER.Enum(),
+			Metric: []*dto.Metric{
+				{
+					Label: []*dto.LabelPair{{Name: proto.String("code"), Value: proto.String("200")}},
+					Counter: &dto.Counter{
+						Value: proto.Float64(10),
+						Exemplar: &dto.Exemplar{
+							Label:     []*dto.LabelPair{{Name: proto.String("trace_id"), Value: proto.String("abc")}},
+							Value:     proto.Float64(1),
+							Timestamp: &timestamppb.Timestamp{Seconds: 1520879607, Nanos: 500000000},
+						},
+						CreatedTimestamp: &timestamppb.Timestamp{Seconds: 1520430000},
+					},
+					TimestampMs: proto.Int64(1520879607789),
+				},
+			},
+		},
+		{
+			Name: proto.String("size_bytes"),
+			Type: dto.MetricType_HISTOGRAM.Enum(),
+			Unit: proto.String("bytes"),
+			Metric: []*dto.Metric{
+				{
+					Histogram: &dto.Histogram{
+						Bucket: []*dto.Bucket{
+							{UpperBound: proto.Float64(100), CumulativeCount: proto.Uint64(1)},
+							{UpperBound: proto.Float64(math.Inf(+1)), CumulativeCount: proto.Uint64(2)},
+						},
+						SampleSum:   proto.Float64(300),
+						SampleCount: proto.Uint64(2),
+					},
+				},
+			},
+		},
+		{
+			Name: proto.String("rpc.duration"),
+			Type: dto.MetricType_SUMMARY.Enum(),
+			Metric: []*dto.Metric{
+				{
+					Label: []*dto.LabelPair{{Name: proto.String("service.name"), Value: proto.String("api")}},
+					Summary: &dto.Summary{
+						Quantile:    []*dto.Quantile{{Quantile: proto.Float64(0.5), Value: proto.Float64(0.25)}},
+						SampleSum:   proto.Float64(1),
+						SampleCount: proto.Uint64(4),
+					},
+				},
+			},
+		},
+	}
+
+	var buf bytes.Buffer
+	for _, mf := range fams {
+		_, err := MetricFamilyToOpenMetrics(&buf, mf, WithCreatedLines())
+		require.NoError(t, err)
+	}
+	_, err := FinalizeOpenMetrics(&buf)
+	require.NoError(t, err)
+
+	p := NewOpenMetricsParser(model.UTF8Validation)
+	got, err := p.OpenMetricsToMetricFamilies(&buf)
+	require.NoError(t, err)
+	requireMetricFamilies(t, fams, got)
+}
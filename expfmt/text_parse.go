@@ -19,6 +19,7 @@ import (
 	"errors"
 	"fmt"
 	"io"
+	"iter"
 	"math"
 	"strconv"
 	"strings"
@@ -83,6 +84,12 @@ type TextParser struct {
 	// scheme sets the desired ValidationScheme for names. Defaults to the invalid
 	// UnsetValidation.
 	scheme model.ValidationScheme
+
+	// The remaining member variables are only used while streaming.
+	streaming bool
+	state     stateFn                      // Next state to execute.
+	emitted   map[string]*dto.MetricFamily // Name, help and type of families already completed.
+	completed []*dto.MetricFamily          // Families completed but not yet handed out.
 }
 
 // NewTextParser returns a new TextParser with the provided nameValidationScheme.
@@ -111,6 +118,9 @@ func NewTextParser(nameValidationScheme model.ValidationScheme) TextParser {
 // summaries and histograms if they are presented in exactly the way the
 // text.Create function creates them.
 //
+// The whole input is held in memory until the end of the input is reached. Use
+// MetricFamilies to process large inputs one family at a time.
+//
 // This method must not be called concurrently. If you want to parse different
 // input concurrently, instantiate a separate Parser for each goroutine.
 func (p *TextParser) TextToMetricFamilies(in io.Reader) (map[string]*dto.MetricFamily, error) {
@@ -137,6 +147,91 @@ func (p *TextParser) TextToMetricFamilies(in io.Reader) (map[string]*dto.MetricF
 	return p.metricFamiliesByName, p.err
 }
 
+// MetricFamilies reads 'in' as the simple and flat text-based exchange format
+// and returns an iterator over the MetricFamily proto messages found in it.
+// Each family is yielded as soon as the input moves on to another family, so
+// only the family currently being parsed is held in memory, plus the name,
+// help and type of every family seen so far.
+//
+// Unlike TextToMetricFamilies, families are not merged: if the samples of a
+// family are interleaved with those of other families, or a family appears
+// more than once, every contiguous run of lines is yielded as a separate
+// MetricFamily carrying the same name, help and type. Consequently, the lines
+// of a single summary or histogram must be contiguous to end up in the same
+// Metric. Families without any metrics are not yielded.
+//
+// Iteration stops after the first error, which is yielded with a nil
+// MetricFamily. Families completed before the error are yielded first.
+//
+// This method must not be called concurrently, and the parser must not be
+// used for anything else until the iteration has finished.
+func (p *TextParser) MetricFamilies(in io.Reader) iter.Seq2[*dto.MetricFamily, error] {
+	return func(yield func(*dto.MetricFamily, error) bool) {
+		p.resetStream(in)
+		for {
+			mf, err := p.next()
+			if errors.Is(err, io.EOF) {
+				return
+			}
+			if !yield(mf, err) || err != nil {
+				return
+			}
+		}
+	}
+}
+
+func (p *TextParser) resetStream(in io.Reader) {
+	p.reset(in)
+	p.streaming = true
+	p.state = p.startOfLine
+	p.emitted = map[string]*dto.MetricFamily{}
+	p.completed = nil
+}
+
+// next advances the state machine until a family is complete and returns it.
+// It returns io.EOF once the input is exhausted.
+func (p *TextParser) next() (*dto.MetricFamily, error) {
+	for len(p.completed) == 0 && p.state != nil {
+		if p.state = p.state(); p.state != nil {
+			continue
+		}
+		if errors.Is(p.err, io.EOF) {
+			p.parseError("unexpected end of input stream")
+		}
+		if p.err == nil {
+			p.completeCurrentMF()
+		}
+	}
+	if len(p.completed) > 0 {
+		mf := p.completed[0]
+		p.completed[0] = nil
+		p.completed = p.completed[1:]
+		return mf, nil
+	}
+	if p.err != nil {
+		return nil, p.err
+	}
+	return nil, io.EOF
+}
+
+// completeCurrentMF hands the family being parsed over to p.completed while
+// streaming and forgets everything that refers to its metrics.
+func (p *TextParser) completeCurrentMF() {
+	for name, mf := range p.metricFamiliesByName {
+		delete(p.metricFamiliesByName, name)
+		p.emitted[name] = &dto.MetricFamily{Name: mf.Name, Help: mf.Help, Type: mf.Type}
+		if len(mf.GetMetric()) == 0 {
+			continue
+		}
+		for _, m := range mf.GetMetric() {
+			normalizeHistogram(m.GetHistogram())
+		}
+		p.completed = append(p.completed, mf)
+	}
+	clear(p.summaries)
+	clear(p.histograms)
+}
+
 // normalizeHistogram makes sure that all the buckets and the count in each
 // histogram is either completely float or completely integer.
 func normalizeHistogram(histogram *dto.Histogram) {
@@ -188,6 +283,10 @@ func (p *TextParser) reset(in io.Reader) {
 	p.currentQuantile = math.NaN()
 	p.currentBucket = math.NaN()
 	p.currentMF = nil
+	p.streaming = false
+	p.state = nil
+	p.emitted = nil
+	p.completed = nil
 }
 
 // startOfLine represents the state where the next byte read from p.buf is the
@@ -910,37 +1009,66 @@ func (p *TextParser) setOrCreateCurrentMF() {
 		p.parseError(fmt.Sprintf("invalid metric name %q", name))
 		return
 	}
-	if p.currentMF = p.metricFamiliesByName[name]; p.currentMF != nil {
+	if mf := p.lookupMF(name); mf != nil {
+		p.useMF(mf)
 		return
 	}
 	// Try out if this is a _sum or _count for a summary/histogram.
 	summaryName := summaryMetricName(name)
-	if p.currentMF = p.metricFamiliesByName[summaryName]; p.currentMF != nil {
-		if p.currentMF.GetType() == dto.MetricType_SUMMARY {
+	if mf := p.lookupMF(summaryName); mf != nil {
+		if mf.GetType() == dto.MetricType_SUMMARY {
 			if isCount(name) {
 				p.currentIsSummaryCount = true
 			}
 			if isSum(name) {
 				p.currentIsSummarySum = true
 			}
+			p.useMF(mf)
 			return
 		}
 	}
 	histogramName := histogramMetricName(name)
-	if p.currentMF = p.metricFamiliesByName[histogramName]; p.currentMF != nil {
-		if p.currentMF.GetType() == dto.MetricType_HISTOGRAM ||
-			p.currentMF.GetType() == dto.MetricType_GAUGE_HISTOGRAM {
+	if mf := p.lookupMF(histogramName); mf != nil {
+		if mf.GetType() == dto.MetricType_HISTOGRAM ||
+			mf.GetType() == dto.MetricType_GAUGE_HISTOGRAM {
 			if isCount(name) {
 				p.currentIsHistogramCount = true
 			}
 			if isSum(name) {
 				p.currentIsHistogramSum = true
 			}
+			p.useMF(mf)
 			return
 		}
 	}
-	p.currentMF = &dto.MetricFamily{Name: proto.String(name)}
-	p.metricFamiliesByName[name] = p.currentMF
+	p.useMF(&dto.MetricFamily{Name: proto.String(name)})
+}
+
+// lookupMF returns the family with the given name. While streaming, this is
+// either the family currently being parsed or the name, help and type of a
+// family completed earlier.
+func (p *TextParser) lookupMF(name string) *dto.MetricFamily {
+	if mf := p.metricFamiliesByName[name]; mf != nil {
+		return mf
+	}
+	return p.emitted[name]
+}
+
+// useMF makes mf the current family. While streaming, switching to another
+// family completes the one parsed so far, and a family completed earlier is
+// continued in a new MetricFamily with the same name, help and type.
+func (p *TextParser) useMF(mf *dto.MetricFamily) {
+	name := mf.GetName()
+	if p.metricFamiliesByName[name] != mf {
+		if p.streaming {
+			p.completeCurrentMF()
+			if p.emitted[name] == mf {
+				mf = &dto.MetricFamily{Name: mf.Name, Help: mf.Help, Type: mf.Type}
+			}
+		}
+		p.metricFamiliesByName[name] = mf
+	}
+	p.currentMF = mf
 }
 
 func isValidLabelNameStart(b byte) bool {
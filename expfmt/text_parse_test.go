@@ -1353,6 +1353,14 @@ request_duration_microseconds_count 2693
 			)
 		}
 
+		var streamErr error
+		for _, err := range parser.MetricFamilies(strings.NewReader(scenario.in)) {
+			streamErr = err
+		}
+		if (err == nil) != (streamErr == nil) || (err != nil && err.Error() != streamErr.Error()) {
+			t.Errorf("%d. expected streaming error %v, got %v", i, err, streamErr)
+		}
+
 		parser.scheme = model.LegacyValidation
 		_, err = parser.TextToMetricFamilies(strings.NewReader(scenario.in))
 		if err == nil {
@@ -1384,6 +1392,120 @@ func BenchmarkParseError(b *testing.B) {
 	}
 }
 
+func TestTextParserMetricFamilies(t *testing.T) {
+	in := `
+# HELP a Help for a.
+# TYPE a counter
+a{x="1"} 1
+# TYPE empty gauge
+# TYPE h histogram
+h_bucket{le="1"} 1.5
+h_bucket{le="+Inf"} 2
+h_count 2
+h_sum 3
+a{x="2"} 2
+b 3
+a{x="3"} 3
+`
+	expected := []*dto.MetricFamily{
+		{
+			Name: proto.String("a"),
+			Help: proto.String("Help for a."),
+			Type: dto.MetricType_COUNTER.Enum(),
+			Metric: []*dto.Metric{{
+				Label:   []*dto.LabelPair{{Name: proto.String("x"), Value: proto.String("1")}},
+				Counter: &dto.Counter{Value: proto.Float64(1)},
+			}},
+		},
+		{
+			Name: proto.String("h"),
+			Type: dto.MetricType_HISTOGRAM.Enum(),
+			Metric: []*dto.Metric{{
+				Histogram: &dto.Histogram{
+					SampleCountFloat: proto.Float64(2),
+					SampleSum:        proto.Float64(3),
+					Bucket: []*dto.Bucket{
+						{UpperBound: proto.Float64(1), CumulativeCountFloat: proto.Float64(1.5)},
+						{UpperBound: proto.Float64(math.Inf(+1)), CumulativeCountFloat: proto.Float64(2)},
+					},
+				},
+			}},
+		},
+		{
+			Name: proto.String("a"),
+			Help: proto.String("Help for a."),
+			Type: dto.MetricType_COUNTER.Enum(),
+			Metric: []*dto.Metric{{
+				Label:   []*dto.LabelPair{{Name: proto.String("x"), Value: proto.String("2")}},
+				Counter: &dto.Counter{Value: proto.Float64(2)},
+			}},
+		},
+		{
+			Name: proto.String("b"),
+			Type: dto.MetricType_UNTYPED.Enum(),
+			Metric: []*dto.Metric{{
+				Untyped: &dto.Untyped{Value: proto.Float64(3)},
+			}},
+		},
+		{
+			Name: proto.String("a"),
+			Help: proto.String("Help for a."),
+			Type: dto.MetricType_COUNTER.Enum(),
+			Metric: []*dto.Metric{{
+				Label:   []*dto.LabelPair{{Name: proto.String("x"), Value: proto.String("3")}},
+				Counter: &dto.Counter{Value: proto.Float64(3)},
+			}},
+		},
+	}
+
+	p := NewTextParser(model.UTF8Validation)
+	var got []*dto.MetricFamily
+	for mf, err := range p.MetricFamilies(strings.NewReader(in)) {
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		got = append(got, mf)
+	}
+	if len(got) != len(expected) {
+		t.Fatalf("Expected %d MetricFamilies, got %d", len(expected), len(got))
+	}
+	for i := range expected {
+		if !proto.Equal(expected[i], got[i]) {
+			t.Errorf("%d. expected MetricFamily %s, got %s", i, expected[i], got[i])
+		}
+	}
+
+	t.Run("Error", func(t *testing.T) {
+		in := "a 1\nb 2\n# TYPE a gauge\nc 3\n"
+		var (
+			names []string
+			errs  int
+		)
+		for mf, err := range p.MetricFamilies(strings.NewReader(in)) {
+			if err != nil {
+				errs++
+				if expected := `text format parsing error in line 3: second TYPE line for metric name "a", or TYPE reported after samples`; err.Error() != expected {
+					t.Errorf("Expected error %q, got %q", expected, err)
+				}
+				continue
+			}
+			names = append(names, mf.GetName())
+		}
+		if errs != 1 || strings.Join(names, ",") != "a,b" {
+			t.Errorf("Expected families a,b followed by one error, got %v and %d errors", names, errs)
+		}
+	})
+
+	t.Run("Break", func(t *testing.T) {
+		for mf := range p.MetricFamilies(strings.NewReader("a 1\nb 2\n")) {
+			if mf.GetName() != "a" {
+				t.Errorf("Unexpected metric name: got %v, expected %v", mf.GetName(), "a")
+			}
+			break
+		}
+	})
+}
+
 func TestTextParserStartOfLine(t *testing.T) {
 	t.Run("EOF", func(t *testing.T) {
 		p := NewTextParser(model.UTF8Validation)